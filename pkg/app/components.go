@@ -0,0 +1,177 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/QubelyLabs/bedrock/pkg/config"
+	"github.com/QubelyLabs/bedrock/pkg/contract"
+	"github.com/QubelyLabs/bedrock/pkg/db"
+	"github.com/QubelyLabs/bedrock/pkg/event"
+	"github.com/QubelyLabs/bedrock/pkg/integration"
+	"github.com/QubelyLabs/bedrock/pkg/logger"
+	"github.com/QubelyLabs/bedrock/pkg/migration"
+	"github.com/QubelyLabs/bedrock/pkg/queue"
+	"github.com/QubelyLabs/bedrock/pkg/scheduler"
+	"github.com/QubelyLabs/bedrock/pkg/tracing"
+	"github.com/getsentry/sentry-go"
+)
+
+type component struct {
+	start func(ctx context.Context) error
+	stop  func(ctx context.Context) error
+}
+
+func (c *component) Start(ctx context.Context) error {
+	if c.start == nil {
+		return nil
+	}
+
+	return c.start(ctx)
+}
+
+func (c *component) Stop(ctx context.Context) error {
+	if c.stop == nil {
+		return nil
+	}
+
+	return c.stop(ctx)
+}
+
+// NewComponent creates a component from start and stop functions, either may be nil
+func NewComponent(start, stop func(ctx context.Context) error) contract.Component {
+	return &component{start, stop}
+}
+
+// Logger configures structured logging
+func Logger(cf *config.Config) contract.Component {
+	return NewComponent(func(ctx context.Context) error {
+		logger.Init(cf)
+		return nil
+	}, nil)
+}
+
+// SQL opens the database on start and closes its pool on stop
+func SQL(cf *config.Config) contract.Component {
+	return NewComponent(func(ctx context.Context) error {
+		return db.InitSQL(cf)
+	}, func(ctx context.Context) error {
+		return db.CloseSQL()
+	})
+}
+
+// Migrations applies the registered migrations once the database is open, see migration.Run
+func Migrations(cf *config.Config) contract.Component {
+	return NewComponent(func(ctx context.Context) error {
+		return migration.Run(ctx, cf)
+	}, nil)
+}
+
+// KV connects to redis on start and closes its pool on stop
+func KV(cf *config.Config) contract.Component {
+	return NewComponent(func(ctx context.Context) error {
+		return db.InitKV(cf)
+	}, func(ctx context.Context) error {
+		return db.CloseKV()
+	})
+}
+
+// Events configures the event transport and runs the listener, on stop the events in flight are dispatched before the deadline
+func Events(cf *config.Config) contract.Component {
+	return NewComponent(func(ctx context.Context) error {
+		if err := event.Configure(cf); err != nil {
+			return err
+		}
+
+		go func() {
+			if err := event.StartListener(); err != nil {
+				logger.For("app").Error("event listener stopped", "error", err)
+			}
+		}()
+		return nil
+	}, func(ctx context.Context) error {
+		return event.StopListener(ctx)
+	})
+}
+
+// Scheduler runs the jobs of the default scheduler, on stop the runs in progress complete before the deadline
+func Scheduler(cf *config.Config) contract.Component {
+	return NewComponent(func(ctx context.Context) error {
+		if err := scheduler.Configure(cf); err != nil {
+			return err
+		}
+
+		scheduler.Default.Start()
+		return nil
+	}, func(ctx context.Context) error {
+		return scheduler.Default.Stop(ctx)
+	})
+}
+
+// Queue configures the job store and runs a worker pool, on stop the running jobs complete before the deadline.
+// It is not part of the defaults, register it after the events and add queue.Migration when using the sql backend.
+func Queue(cf *config.Config) contract.Component {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	return NewComponent(func(context.Context) error {
+		if err := queue.Configure(cf); err != nil {
+			return err
+		}
+
+		go func() {
+			defer close(done)
+			queue.NewDefaultPool(cf).Run(ctx)
+		}()
+		return nil
+	}, func(stop context.Context) error {
+		cancel()
+
+		select {
+		case <-done:
+			return nil
+		case <-stop.Done():
+			return fmt.Errorf("queue: jobs still running: %w", stop.Err())
+		}
+	})
+}
+
+// Sentry initializes sentry on start and flushes the pending events on stop
+func Sentry(cf *config.Config) contract.Component {
+	return NewComponent(func(ctx context.Context) error {
+		return integration.SentryInit(cf)
+	}, func(ctx context.Context) error {
+		timeout := 2 * time.Second
+		if deadline, ok := ctx.Deadline(); ok {
+			timeout = time.Until(deadline)
+		}
+
+		sentry.Flush(timeout)
+		return nil
+	})
+}
+
+// Tracing installs the tracer provider on start and flushes the pending spans on stop
+func Tracing(cf *config.Config) contract.Component {
+	var shutdown func(context.Context) error
+
+	return NewComponent(func(ctx context.Context) error {
+		var err error
+		shutdown, err = tracing.Init(cf)
+		return err
+	}, func(ctx context.Context) error {
+		if shutdown == nil {
+			return nil
+		}
+
+		return shutdown(ctx)
+	})
+}
+
+// Closer stops a client that flushes on close, e.g. the rudderstack service
+func Closer(closer interface{ Close() error }) contract.Component {
+	return NewComponent(nil, func(ctx context.Context) error {
+		return closer.Close()
+	})
+}
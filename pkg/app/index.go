@@ -0,0 +1,195 @@
+// Package app bootstraps a service: it starts registered components in order, serves http,
+// runs background workers and shuts everything down gracefully on SIGINT or SIGTERM.
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/QubelyLabs/bedrock/pkg/config"
+	"github.com/QubelyLabs/bedrock/pkg/contract"
+	"github.com/QubelyLabs/bedrock/pkg/health"
+	"github.com/QubelyLabs/bedrock/pkg/logger"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	READ_TIMEOUT     = 30 * time.Second
+	WRITE_TIMEOUT    = 30 * time.Second
+	IDLE_TIMEOUT     = 120 * time.Second
+	SHUTDOWN_TIMEOUT = 30 * time.Second
+)
+
+type namedComponent struct {
+	name      string
+	component contract.Component
+}
+
+type worker struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+type App struct {
+	Config *config.Config
+	Router *gin.Engine
+
+	server     *http.Server
+	components []namedComponent
+	workers    []worker
+}
+
+// Register adds a component, components start in registration order and stop in reverse order
+func (a *App) Register(name string, component contract.Component) {
+	a.components = append(a.components, namedComponent{name, component})
+}
+
+// RegisterDefaults adds the logger, sentry, tracing, database, migration, redis, event and scheduler components.
+// Events and jobs are registered last, so on shutdown they are drained while the databases are still open.
+func (a *App) RegisterDefaults() {
+	a.Register("logger", Logger(a.Config))
+	a.Register("sentry", Sentry(a.Config))
+	a.Register("tracing", Tracing(a.Config))
+	a.Register("sql", SQL(a.Config))
+	a.Register("migrations", Migrations(a.Config))
+	a.Register("kv", KV(a.Config))
+	a.Register("events", Events(a.Config))
+	a.Register("scheduler", Scheduler(a.Config))
+}
+
+// Go adds a background worker, its context is cancelled on shutdown and it is waited for before components stop
+func (a *App) Go(name string, fn func(ctx context.Context) error) {
+	a.workers = append(a.workers, worker{name, fn})
+}
+
+// Run starts the components, workers and http server, then blocks until a shutdown signal is received
+func (a *App) Run() error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	return a.RunContext(ctx)
+}
+
+// RunContext works like Run, shutting down when ctx is done instead of on a signal
+func (a *App) RunContext(ctx context.Context) error {
+	log := logger.For("app")
+
+	started := 0
+	for _, c := range a.components {
+		log.Info("starting component", "component", c.name)
+		if err := c.component.Start(ctx); err != nil {
+			a.stopComponents(started)
+			return fmt.Errorf("app: cannot start %v: %w", c.name, err)
+		}
+		started++
+	}
+
+	workerCtx, cancelWorkers := context.WithCancel(context.Background())
+	defer cancelWorkers()
+
+	var workers sync.WaitGroup
+	for _, w := range a.workers {
+		workers.Add(1)
+		go func(w worker) {
+			defer workers.Done()
+			if err := w.fn(workerCtx); err != nil && !errors.Is(err, context.Canceled) {
+				log.Error("worker stopped with an error", "worker", w.name, "error", err)
+			}
+		}(w)
+	}
+
+	serverErr := make(chan error, 1)
+	go func() {
+		log.Info("server listening", "addr", a.server.Addr)
+		if err := a.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
+		}
+	}()
+
+	var err error
+	select {
+	case <-ctx.Done():
+		log.Info("shutting down")
+	case err = <-serverErr:
+		log.Error("server stopped", "error", err)
+	}
+
+	// fail readiness first, so load balancers stop routing before intake stops
+	health.SetShuttingDown(true)
+	if delay := time.Duration(a.Config.ShutdownDelay) * time.Second; delay > 0 && err == nil {
+		time.Sleep(delay)
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), seconds(a.Config.ShutdownTimeout, SHUTDOWN_TIMEOUT))
+	defer cancel()
+
+	if e := a.server.Shutdown(shutdownCtx); e != nil {
+		log.Error("cannot drain http requests", "error", e)
+	}
+
+	cancelWorkers()
+	done := make(chan struct{})
+	go func() {
+		workers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-shutdownCtx.Done():
+		log.Error("workers did not stop before the deadline")
+	}
+
+	a.stopComponentsContext(shutdownCtx, started)
+	log.Info("shutdown complete")
+
+	return err
+}
+
+func (a *App) stopComponents(started int) {
+	ctx, cancel := context.WithTimeout(context.Background(), seconds(a.Config.ShutdownTimeout, SHUTDOWN_TIMEOUT))
+	defer cancel()
+
+	a.stopComponentsContext(ctx, started)
+}
+
+// stopComponentsContext stops the first started components in reverse order
+func (a *App) stopComponentsContext(ctx context.Context, started int) {
+	for i := started - 1; i >= 0; i-- {
+		c := a.components[i]
+		if err := c.component.Stop(ctx); err != nil {
+			logger.For("app").Error("cannot stop component", "component", c.name, "error", err)
+		}
+	}
+}
+
+// New creates an app serving a gin router on the configured port and timeouts
+func New(cf *config.Config) *App {
+	router := gin.New()
+
+	return &App{
+		Config: cf,
+		Router: router,
+		server: &http.Server{
+			Addr:         ":" + cf.Port,
+			Handler:      router,
+			ReadTimeout:  seconds(cf.ReadTimeout, READ_TIMEOUT),
+			WriteTimeout: seconds(cf.WriteTimeout, WRITE_TIMEOUT),
+			IdleTimeout:  seconds(cf.IdleTimeout, IDLE_TIMEOUT),
+		},
+	}
+}
+
+func seconds(value int, fallback time.Duration) time.Duration {
+	if value <= 0 {
+		return fallback
+	}
+
+	return time.Duration(value) * time.Second
+}
@@ -3,6 +3,8 @@
 package cache
 
 import (
+	"context"
+	"encoding/json"
 	"time"
 
 	"github.com/QubelyLabs/bedrock/pkg/contract"
@@ -16,3 +18,31 @@ func Cache(key string, fn func() (any, error), exp time.Duration) (any, error) {
 	cache := NewDefaultCache()
 	return cache.Cache(key, fn, exp)
 }
+
+// CacheWithContext works like Cache, with lookups traced as part of ctx
+func CacheWithContext(ctx context.Context, key string, fn func() (any, error), exp time.Duration) (any, error) {
+	cache := NewRedisCache().WithContext(ctx)
+	return cache.Cache(key, fn, exp)
+}
+
+// CacheAs works like Cache but returns the value as T.
+// Values read back from the cache are decoded json, they are converted to T through a json round trip.
+func CacheAs[T any](cacher contract.Cacher, key string, fn func() (T, error), exp time.Duration) (T, error) {
+	var result T
+	value, err := cacher.Cache(key, func() (any, error) { return fn() }, exp)
+	if err != nil {
+		return result, err
+	}
+
+	if v, ok := value.(T); ok {
+		return v, nil
+	}
+
+	buf, err := json.Marshal(value)
+	if err != nil {
+		return result, err
+	}
+
+	err = json.Unmarshal(buf, &result)
+	return result, err
+}
@@ -6,19 +6,20 @@ import (
 	"time"
 
 	"github.com/QubelyLabs/bedrock/pkg/db"
+	"github.com/QubelyLabs/bedrock/pkg/metrics"
 	"github.com/redis/go-redis/v9"
 )
 
 // redisCache represents a Redis cache instance
 type redisCache struct {
-	client *redis.Client
+	client redis.UniversalClient
 	ctx    context.Context
 }
 
 // Get retrieves data from the cache based on the provided key.
 // It returns the retrieved data and an error, if any.
 func (r *redisCache) Get(key string) (any, error) {
-	str, err := r.client.Get(r.ctx, key).Result()
+	str, err := r.kv().Get(r.ctx, key).Result()
 	if err != nil {
 		return nil, err
 	}
@@ -39,7 +40,7 @@ func (r *redisCache) Set(key string, value any, exp time.Duration) error {
 		return err
 	}
 
-	_, err = r.client.Set(r.ctx, key, string(buf), exp).Result()
+	_, err = r.kv().Set(r.ctx, key, string(buf), exp).Result()
 	if err != nil {
 		return err
 	}
@@ -53,13 +54,17 @@ func (r *redisCache) Set(key string, value any, exp time.Duration) error {
 func (r *redisCache) Cache(key string, fn func() (any, error), exp time.Duration) (any, error) {
 	existing, err := r.Get(key)
 	if err != nil && err != redis.Nil {
+		metrics.CacheRequests.WithLabelValues("error").Inc()
 		return nil, err
 	}
 
 	if err == nil {
+		metrics.CacheRequests.WithLabelValues("hit").Inc()
 		return existing, nil
 	}
 
+	metrics.CacheRequests.WithLabelValues("miss").Inc()
+
 	result, err := fn()
 	if err != nil {
 		return nil, err
@@ -73,6 +78,21 @@ func (r *redisCache) Cache(key string, fn func() (any, error), exp time.Duration
 	return result, nil
 }
 
+// WithContext returns a copy of the cache bound to ctx, so lookups are traced as part of the request
+func (r *redisCache) WithContext(ctx context.Context) *redisCache {
+	return &redisCache{r.client, ctx}
+}
+
+// kv returns the client of the cache, db.KV() is resolved on use so a cache created before InitKV works
+func (r *redisCache) kv() redis.UniversalClient {
+	if r.client != nil {
+		return r.client
+	}
+
+	return db.KV()
+}
+
+// NewRedisCache creates a cache on db.KV(), resolved at run time
 func NewRedisCache() *redisCache {
-	return &redisCache{db.KV(), context.Background()}
+	return &redisCache{nil, context.Background()}
 }
@@ -6,14 +6,35 @@ type Config struct {
 	GoEnv       string `mapstructure:"GO_ENV"`
 
 	// Database configuration
-	DBDialect  string `mapstructure:"DB_DIALECT"`
-	DBHost     string `mapstructure:"DB_HOST"`
-	DBPort     int    `mapstructure:"DB_PORT"`
-	DBUser     string `mapstructure:"DB_USER"`
-	DBPassword string `mapstructure:"DB_PASS"`
-	DBName     string `mapstructure:"DB_NAME"`
-	DBSync     bool   `mapstructure:"DB_SYNC"`
-	DBLog      bool   `mapstructure:"DB_LOG"`
+	DBDialect         string `mapstructure:"DB_DIALECT"`
+	DBHost            string `mapstructure:"DB_HOST"`
+	DBPort            int    `mapstructure:"DB_PORT"`
+	DBUser            string `mapstructure:"DB_USER"`
+	DBPassword        string `mapstructure:"DB_PASS"`
+	DBName            string `mapstructure:"DB_NAME"`
+	DBSync            bool   `mapstructure:"DB_SYNC"`
+	DBMigrationDryRun bool   `mapstructure:"DB_MIGRATION_DRY_RUN"`
+	DBLog             bool   `mapstructure:"DB_LOG"`
+	DBLogLevel        string `mapstructure:"DB_LOG_LEVEL"`
+	DBTimezone        string `mapstructure:"DB_TIMEZONE"`
+
+	// Database TLS, DB_TLS is true, skip-verify or preferred, postgres also takes its sslmode values
+	DBTLS   string `mapstructure:"DB_TLS"`
+	DBTLSCA string `mapstructure:"DB_TLS_CA"`
+
+	// Database pool settings, durations are in seconds except the slow threshold in milliseconds
+	DBMaxOpenConns    int `mapstructure:"DB_MAX_OPEN_CONNS"`
+	DBMaxIdleConns    int `mapstructure:"DB_MAX_IDLE_CONNS"`
+	DBConnMaxLifetime int `mapstructure:"DB_CONN_MAX_LIFETIME"`
+	DBConnMaxIdleTime int `mapstructure:"DB_CONN_MAX_IDLE_TIME"`
+	DBSlowThreshold   int `mapstructure:"DB_SLOW_THRESHOLD"`
+	DBConnectRetries  int `mapstructure:"DB_CONNECT_RETRIES"`
+	DBConnectBackoff  int `mapstructure:"DB_CONNECT_BACKOFF"`
+
+	// Read replicas, DB_REPLICAS is a comma separated list of host or host:port sharing the primary credentials
+	DBReplicas             string `mapstructure:"DB_REPLICAS"`
+	DBReplicaCheckInterval int    `mapstructure:"DB_REPLICA_CHECK_INTERVAL"`
+	DBStickyWindow         int    `mapstructure:"DB_STICKY_WINDOW"`
 
 	// Throttle settings
 	TTL   int `mapstructure:"THROTTLE_TTL"`
@@ -23,12 +44,113 @@ type Config struct {
 	Timeout      int `mapstructure:"HTTP_TIMEOUT"`
 	MaxRedirects int `mapstructure:"HTTP_MAX_REDIRECTS"`
 
+	// Server settings, durations are in seconds
+	ReadTimeout     int `mapstructure:"SERVER_READ_TIMEOUT"`
+	WriteTimeout    int `mapstructure:"SERVER_WRITE_TIMEOUT"`
+	IdleTimeout     int `mapstructure:"SERVER_IDLE_TIMEOUT"`
+	ShutdownTimeout int `mapstructure:"SHUTDOWN_TIMEOUT"`
+	ShutdownDelay   int `mapstructure:"SHUTDOWN_DELAY"`
+
 	// Redis configuration
 	RedisDB       int    `mapstructure:"REDIS_DB"`
 	RedisHost     string `mapstructure:"REDIS_HOST"`
 	RedisPassword string `mapstructure:"REDIS_PASS"`
 	RedisPort     int    `mapstructure:"REDIS_PORT"`
 
+	// Redis topology, REDIS_MODE is standalone, sentinel or cluster.
+	// REDIS_ADDRS lists the sentinels or cluster seeds, REDIS_HOST:REDIS_PORT is used when empty.
+	RedisMode             string `mapstructure:"REDIS_MODE"`
+	RedisAddrs            string `mapstructure:"REDIS_ADDRS"`
+	RedisUser             string `mapstructure:"REDIS_USER"`
+	RedisMasterName       string `mapstructure:"REDIS_MASTER_NAME"`
+	RedisSentinelPassword string `mapstructure:"REDIS_SENTINEL_PASS"`
+	RedisTLS              bool   `mapstructure:"REDIS_TLS"`
+	RedisTLSCA            string `mapstructure:"REDIS_TLS_CA"`
+	RedisTLSSkipVerify    bool   `mapstructure:"REDIS_TLS_SKIP_VERIFY"`
+
+	// Redis pool settings, durations are in seconds
+	RedisPoolSize        int `mapstructure:"REDIS_POOL_SIZE"`
+	RedisMinIdleConns    int `mapstructure:"REDIS_MIN_IDLE_CONNS"`
+	RedisMaxIdleConns    int `mapstructure:"REDIS_MAX_IDLE_CONNS"`
+	RedisConnMaxIdleTime int `mapstructure:"REDIS_CONN_MAX_IDLE_TIME"`
+	RedisConnMaxLifetime int `mapstructure:"REDIS_CONN_MAX_LIFETIME"`
+	RedisPoolTimeout     int `mapstructure:"REDIS_POOL_TIMEOUT"`
+	RedisDialTimeout     int `mapstructure:"REDIS_DIAL_TIMEOUT"`
+	RedisReadTimeout     int `mapstructure:"REDIS_READ_TIMEOUT"`
+	RedisWriteTimeout    int `mapstructure:"REDIS_WRITE_TIMEOUT"`
+	RedisConnectRetries  int `mapstructure:"REDIS_CONNECT_RETRIES"`
+	RedisConnectBackoff  int `mapstructure:"REDIS_CONNECT_BACKOFF"`
+
+	// OAuth2 client credentials for service to service calls
+	OAuthTokenUrl     string `mapstructure:"OAUTH_TOKEN_URL"`
+	OAuthClientId     string `mapstructure:"OAUTH_CLIENT_ID"`
+	OAuthClientSecret string `mapstructure:"OAUTH_CLIENT_SECRET"`
+	OAuthScopes       string `mapstructure:"OAUTH_SCOPES"`
+	OAuthHosts        string `mapstructure:"OAUTH_HOSTS"`
+
+	// Downstream services
+	IdentityBaseUrl  string `mapstructure:"IDENTITY_BASE_URL"`
+	IdentityCacheTTL int    `mapstructure:"IDENTITY_CACHE_TTL"`
+	PricingBaseUrl   string `mapstructure:"PRICING_BASE_URL"`
+	PricingCacheTTL  int    `mapstructure:"PRICING_CACHE_TTL"`
+
+	// Usage metering
+	UsageBatchSize     int `mapstructure:"USAGE_BATCH_SIZE"`
+	UsageFlushInterval int `mapstructure:"USAGE_FLUSH_INTERVAL"`
+
+	// Event transport, EVENT_TRANSPORT is memory, sync or redis, the consumer group defaults to the service name
+	EventTransport    string `mapstructure:"EVENT_TRANSPORT"`
+	EventStream       string `mapstructure:"EVENT_STREAM"`
+	EventGroup        string `mapstructure:"EVENT_GROUP"`
+	EventStreamMaxLen int64  `mapstructure:"EVENT_STREAM_MAX_LEN"`
+	EventClaimIdle    int    `mapstructure:"EVENT_CLAIM_IDLE"`
+
+	// Number of dispatcher workers running listeners
+	EventWorkers int `mapstructure:"EVENT_WORKERS"`
+
+	// Store of the delayed events, memory or redis, polled every EVENT_SCHEDULE_POLL_INTERVAL seconds
+	EventSchedule             string `mapstructure:"EVENT_SCHEDULE"`
+	EventSchedulePollInterval int    `mapstructure:"EVENT_SCHEDULE_POLL_INTERVAL"`
+
+	// Dead letter store of events failing their listeners, memory, sql, redis or none
+	EventDeadLetter string `mapstructure:"EVENT_DEAD_LETTER"`
+
+	// Scheduled jobs, SCHEDULER_HISTORY is memory, sql or none, the sql runs are kept SCHEDULER_HISTORY_RETENTION seconds
+	SchedulerHistory          string `mapstructure:"SCHEDULER_HISTORY"`
+	SchedulerHistoryRetention int    `mapstructure:"SCHEDULER_HISTORY_RETENTION"`
+
+	// Background job queue, QUEUE_BACKEND is sql or redis, durations are in seconds.
+	// QUEUE_NAMES lists the queues taken by the workers, comma separated, by priority
+	QueueBackend           string `mapstructure:"QUEUE_BACKEND"`
+	QueueNames             string `mapstructure:"QUEUE_NAMES"`
+	QueueConcurrency       int    `mapstructure:"QUEUE_CONCURRENCY"`
+	QueueVisibilityTimeout int    `mapstructure:"QUEUE_VISIBILITY_TIMEOUT"`
+	QueuePollInterval      int    `mapstructure:"QUEUE_POLL_INTERVAL"`
+
+	// Outbox relay, durations are in seconds
+	OutboxBatchSize    int `mapstructure:"OUTBOX_BATCH_SIZE"`
+	OutboxPollInterval int `mapstructure:"OUTBOX_POLL_INTERVAL"`
+	OutboxMaxAttempts  int `mapstructure:"OUTBOX_MAX_ATTEMPTS"`
+	OutboxRetention    int `mapstructure:"OUTBOX_RETENTION"`
+
+	// Sentry configuration
+	SentryDsn              string  `mapstructure:"SENTRY_DSN"`
+	SentryRelease          string  `mapstructure:"SENTRY_RELEASE"`
+	SentrySampleRate       float64 `mapstructure:"SENTRY_SAMPLE_RATE"`
+	SentryTracesSampleRate float64 `mapstructure:"SENTRY_TRACES_SAMPLE_RATE"`
+
+	// Tracing configuration
+	TracingExporter   string  `mapstructure:"TRACING_EXPORTER"`
+	TracingEndpoint   string  `mapstructure:"TRACING_ENDPOINT"`
+	TracingInsecure   bool    `mapstructure:"TRACING_INSECURE"`
+	TracingSampleRate float64 `mapstructure:"TRACING_SAMPLE_RATE"`
+
+	// Logging configuration
+	LogLevel       string `mapstructure:"LOG_LEVEL"`
+	LogLevels      string `mapstructure:"LOG_LEVELS"`
+	LogRedact      string `mapstructure:"LOG_REDACT"`
+	TrafficLogBody bool   `mapstructure:"TRAFFIC_LOG_BODY"`
+
 	// Switch settings
 	TrafficLog string `mapstructure:"TRAFFIC_LOG_SWITCH"`
 	Shutdown   string `mapstructure:"SHUTDOWN_SWITCH"`
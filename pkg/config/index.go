@@ -48,3 +48,13 @@ func Set[T any]() *T {
 func Get[T any]() *T {
 	return cf.(*T)
 }
+
+// Enabled reports whether a switch setting is turned on
+func Enabled(value string) bool {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "on", "true", "1", "yes", "enabled":
+		return true
+	default:
+		return false
+	}
+}
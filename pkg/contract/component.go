@@ -0,0 +1,8 @@
+package contract
+
+import "context"
+
+type Component interface {
+	Start(ctx context.Context) error
+	Stop(ctx context.Context) error
+}
@@ -21,3 +21,8 @@ type Repository[E any] interface {
 	DeleteMany(*gin.Context, any, ...any) error
 	Count(*gin.Context, any, ...any) (int64, error)
 }
+
+// Searcher is a repository finding entities where any of the columns contains a term
+type Searcher[E any] interface {
+	Search(*gin.Context, []string, string, int, int) ([]E, error)
+}
@@ -0,0 +1,8 @@
+package contract
+
+import "context"
+
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+	Invalidate()
+}
@@ -2,9 +2,11 @@ package controller
 
 import (
 	"errors"
-	"log"
+	"log/slog"
 
 	"github.com/QubelyLabs/bedrock/pkg/injection"
+	"github.com/QubelyLabs/bedrock/pkg/integration"
+	"github.com/QubelyLabs/bedrock/pkg/logger"
 	"github.com/QubelyLabs/bedrock/pkg/util"
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
@@ -18,7 +20,7 @@ func (ctrl *BaseController) SQL(c *gin.Context) *gorm.DB {
 	return injection.GetSQL(c)
 }
 
-func (ctrl *BaseController) KV(c *gin.Context) *redis.Client {
+func (ctrl *BaseController) KV(c *gin.Context) redis.UniversalClient {
 	return injection.GetKV(c)
 }
 
@@ -46,22 +48,33 @@ func (ctrl *BaseController) Validate(c *gin.Context, payload any) (any, bool) {
 	err := c.ShouldBindJSON(payload)
 	if err != nil {
 		if _, ok := err.(*validator.InvalidValidationError); ok {
-			log.Println(err)
+			ctrl.Logger(c).Error("invalid validation target", "error", err)
 			return nil, false
 		}
 
 		if data, ok := err.(validator.ValidationErrors); ok {
-			log.Println(err)
+			ctrl.Logger(c).Debug("request failed validation", "error", err)
 			return data, false
 		}
 
-		log.Println(err)
+		ctrl.Logger(c).Debug("unable to bind request", "error", err)
 		return nil, false
 	}
 
 	return nil, true
 }
 
+// Logger returns the request scoped logger
+func (ctrl *BaseController) Logger(c *gin.Context) *slog.Logger {
+	return injection.GetLogger(c).With(logger.PackageKey, "controller")
+}
+
+// ReportError logs an unexpected error and reports it to sentry
+func (ctrl *BaseController) ReportError(c *gin.Context, err error) {
+	ctrl.Logger(c).Error("request failed", "error", err)
+	integration.CaptureError(c, err)
+}
+
 func (ctrl *BaseController) Success(c *gin.Context, message string, data any) {
 	c.JSON(200, gin.H{
 		"status":  true,
@@ -0,0 +1,148 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/QubelyLabs/bedrock/pkg/event"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	REPLAY_LIMIT = 100
+)
+
+// DeadLetterController exposes the event dead letters for inspection, replay and discard.
+// Mount it behind admin authentication, replaying runs listeners with the payload of the request.
+type DeadLetterController struct {
+	*BaseController
+}
+
+func NewDeadLetterController() *DeadLetterController {
+	return &DeadLetterController{&BaseController{}}
+}
+
+// Register mounts the endpoints on the router, e.g. an admin group at /admin/dead-letters
+func (ctrl *DeadLetterController) Register(router gin.IRouter) {
+	router.GET("", ctrl.FindMany)
+	router.GET("/:id", ctrl.FindOne)
+	router.POST("/replay", ctrl.ReplayMany)
+	router.POST("/:id/replay", ctrl.ReplayOne)
+	router.DELETE("", ctrl.DeleteMany)
+	router.DELETE("/:id", ctrl.DeleteOne)
+}
+
+// FindMany lists the dead letters, filtered by the event and listener query parameters
+func (ctrl *DeadLetterController) FindMany(c *gin.Context) {
+	page, err := strconv.Atoi(c.Query("page"))
+	if err != nil || page <= 0 {
+		page = 1
+	}
+
+	perPage, err := strconv.Atoi(c.Query("perPage"))
+	if err != nil || perPage <= 0 {
+		perPage = 12
+	}
+
+	letters, err := event.ListDeadLetters(c.Request.Context(), ctrl.filter(c), perPage, (page-1)*perPage)
+	if err != nil {
+		ctrl.ReportError(c, err)
+		ctrl.ErrorWithCode(c, "Unable to retrieve dead letters, try again in a bit", 500)
+		return
+	}
+
+	ctrl.Success(c, "dead letters retrieved successfully", letters)
+}
+
+func (ctrl *DeadLetterController) FindOne(c *gin.Context) {
+	letter, err := event.GetDeadLetter(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		ctrl.fail(c, err, "Unable to retrieve dead letter, try again in a bit")
+		return
+	}
+
+	ctrl.Success(c, "dead letter retrieved successfully", letter)
+}
+
+// ReplayOne delivers a dead letter to its listener again, it is removed when handled
+func (ctrl *DeadLetterController) ReplayOne(c *gin.Context) {
+	err := event.Replay(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		if errors.Is(err, event.ErrDeadLetterNotFound) || errors.Is(err, event.ErrListenerNotFound) {
+			ctrl.fail(c, err, "")
+			return
+		}
+
+		ctrl.Logger(c).Warn("dead letter replay failed", "id", c.Param("id"), "error", err)
+		ctrl.ErrorWithData(c, "Dead letter replay failed, the attempt was recorded", gin.H{"error": err.Error()})
+		return
+	}
+
+	ctrl.Success(c, "dead letter replayed successfully", nil)
+}
+
+// ReplayMany replays the oldest dead letters matching the event and listener query parameters,
+// up to the limit query parameter capped at REPLAY_LIMIT, so a large backlog is replayed over several requests
+func (ctrl *DeadLetterController) ReplayMany(c *gin.Context) {
+	limit, err := strconv.Atoi(c.Query("limit"))
+	if err != nil || limit <= 0 || limit > REPLAY_LIMIT {
+		limit = REPLAY_LIMIT
+	}
+
+	replayed, failed, err := event.ReplayAll(c.Request.Context(), ctrl.filter(c), limit)
+	if err != nil {
+		ctrl.ReportError(c, err)
+		ctrl.ErrorWithCode(c, "Unable to replay dead letters, try again in a bit", 500)
+		return
+	}
+
+	ctrl.Success(c, fmt.Sprintf("%v dead letters replayed, %v failed again", replayed, failed), gin.H{
+		"replayed": replayed,
+		"failed":   failed,
+	})
+}
+
+// DeleteOne discards a dead letter without replaying it
+func (ctrl *DeadLetterController) DeleteOne(c *gin.Context) {
+	if err := event.Discard(c.Request.Context(), c.Param("id")); err != nil {
+		ctrl.fail(c, err, "Unable to remove dead letter, try again in a bit")
+		return
+	}
+
+	ctrl.Success(c, "dead letter removed successfully", nil)
+}
+
+// DeleteMany discards the dead letters matching the event and listener query parameters
+func (ctrl *DeadLetterController) DeleteMany(c *gin.Context) {
+	deleted, err := event.DiscardAll(c.Request.Context(), ctrl.filter(c))
+	if err != nil {
+		ctrl.ReportError(c, err)
+		ctrl.ErrorWithCode(c, "Unable to remove dead letters, try again in a bit", 500)
+		return
+	}
+
+	ctrl.Success(c, fmt.Sprintf("%v dead letters removed successfully", deleted), gin.H{"deleted": deleted})
+}
+
+func (ctrl *DeadLetterController) filter(c *gin.Context) event.DeadLetterFilter {
+	return event.DeadLetterFilter{Event: c.Query("event"), Listener: c.Query("listener")}
+}
+
+// fail answers not found errors with a 404, a listener missing from this service with a 422 and reports the others
+func (ctrl *DeadLetterController) fail(c *gin.Context, err error, message string) {
+	if errors.Is(err, event.ErrDeadLetterNotFound) {
+		ctrl.Logger(c).Debug("dead letter not found", "id", c.Param("id"), "error", err)
+		ctrl.ErrorWithDataAndCode(c, "Invalid request, record not found", gin.H{"error": err.Error()}, 404)
+		return
+	}
+
+	if errors.Is(err, event.ErrListenerNotFound) {
+		ctrl.Logger(c).Warn("dead letter listener not registered", "id", c.Param("id"), "error", err)
+		ctrl.ErrorWithDataAndCode(c, "Unable to replay dead letter, its listener is not registered", gin.H{"error": err.Error()}, 422)
+		return
+	}
+
+	ctrl.ReportError(c, err)
+	ctrl.ErrorWithCode(c, message, 500)
+}
@@ -2,7 +2,6 @@ package controller
 
 import (
 	"fmt"
-	"log"
 	"strconv"
 	"strings"
 
@@ -60,7 +59,7 @@ func (ctrl *Controller[E]) UpsertOne(c *gin.Context) {
 
 	err := ctrl.repository.UpsertOne(c, entity)
 	if err != nil {
-		log.Println(err)
+		ctrl.ReportError(c, err)
 		ctrl.ErrorWithCode(c, fmt.Sprintf("Unable to save %v record, try again in a bit", ctrl.name), 500)
 		return
 	}
@@ -91,7 +90,7 @@ func (ctrl *Controller[E]) UpsertMany(c *gin.Context) {
 
 	err := ctrl.repository.UpsertMany(c, entities...)
 	if err != nil {
-		log.Println(err)
+		ctrl.ReportError(c, err)
 		ctrl.ErrorWithCode(c, fmt.Sprintf("Unable to save %v records, try again in a bit", ctrl.name), 500)
 		return
 	}
@@ -112,8 +111,6 @@ func (ctrl *Controller[E]) CreateOne(c *gin.Context) {
 		return
 	}
 
-	fmt.Println(entity)
-
 	if ctrl.morph != nil {
 		ctrl.morph(entity)
 	}
@@ -122,13 +119,12 @@ func (ctrl *Controller[E]) CreateOne(c *gin.Context) {
 		query, args := ctrl.unique(entity)
 		existing, err := ctrl.repository.Count(c, query, args...)
 		if err != nil {
-			log.Println(err)
+			ctrl.ReportError(c, err)
 			ctrl.Error(c, "Something went wrong, check and try again")
 			return
 		}
 
 		if existing > 0 {
-			log.Println(err)
 			ctrl.ErrorWithData(c, fmt.Sprintf("A similar %v record exist, check and try again", ctrl.name), entity)
 			return
 		}
@@ -140,7 +136,7 @@ func (ctrl *Controller[E]) CreateOne(c *gin.Context) {
 
 	err := ctrl.repository.CreateOne(c, entity)
 	if err != nil {
-		log.Println(err)
+		ctrl.ReportError(c, err)
 		ctrl.ErrorWithCode(c, fmt.Sprintf("Unable to save %v record, try again in a bit", ctrl.name), 500)
 		return
 	}
@@ -168,13 +164,12 @@ func (ctrl *Controller[E]) CreateMany(c *gin.Context) {
 			query, args := ctrl.unique(&entity)
 			existing, err := ctrl.repository.Count(c, query, args...)
 			if err != nil {
-				log.Println(err)
+				ctrl.ReportError(c, err)
 				ctrl.Error(c, "Something went wrong, check and try again")
 				return
 			}
 
 			if existing > 0 {
-				log.Println(err)
 				ctrl.ErrorWithData(c, fmt.Sprintf("A similar %v record exist, check and try again", ctrl.name), entity)
 				return
 			}
@@ -189,7 +184,7 @@ func (ctrl *Controller[E]) CreateMany(c *gin.Context) {
 
 	err := ctrl.repository.CreateMany(c, entities...)
 	if err != nil {
-		log.Println(err)
+		ctrl.ReportError(c, err)
 		ctrl.ErrorWithCode(c, fmt.Sprintf("Unable to save %v records, try again in a bit", ctrl.name), 500)
 		return
 	}
@@ -214,12 +209,12 @@ func (ctrl *Controller[E]) UpdateOne(c *gin.Context) {
 	existingEntity, err := ctrl.repository.FindOne(c, id)
 	if err != nil {
 		if err == gorm.ErrRecordNotFound {
-			log.Println(err)
+			ctrl.Logger(c).Debug("record not found", "entity", ctrl.name, "error", err)
 			ctrl.ErrorWithCode(c, "Invalid request, record not found", 404)
 			return
 		}
 
-		log.Println(err)
+		ctrl.ReportError(c, err)
 		ctrl.ErrorWithCode(c, fmt.Sprintf("Unable to retrieve %v record, try again in a bit", ctrl.name), 500)
 		return
 	}
@@ -233,13 +228,12 @@ func (ctrl *Controller[E]) UpdateOne(c *gin.Context) {
 		var existing int64
 		err := ctrl.repository.SQL(c).WithContext(c).Where(query, args...).Where("id != ?", id).Model(entity).Count(&existing).Error
 		if err != nil {
-			log.Println(err)
+			ctrl.ReportError(c, err)
 			ctrl.Error(c, "Something went wrong, check and try again")
 			return
 		}
 
 		if existing > 0 {
-			log.Println(err)
 			ctrl.ErrorWithData(c, fmt.Sprintf("A similar %v record exist, check and try again", ctrl.name), entity)
 			return
 		}
@@ -251,7 +245,7 @@ func (ctrl *Controller[E]) UpdateOne(c *gin.Context) {
 
 	err = ctrl.repository.UpdateOne(c, id, entity)
 	if err != nil {
-		log.Println(err)
+		ctrl.ReportError(c, err)
 		ctrl.ErrorWithCode(c, fmt.Sprintf("Unable to update %v record, try again in a bit", ctrl.name), 500)
 		return
 	}
@@ -277,12 +271,12 @@ func (ctrl *Controller[E]) UpdateMany(c *gin.Context) {
 		existingEntity, err := ctrl.repository.FindOne(c, id)
 		if err != nil {
 			if err == gorm.ErrRecordNotFound {
-				log.Println(err)
+				ctrl.Logger(c).Debug("record not found", "entity", ctrl.name, "error", err)
 				ctrl.ErrorWithDataAndCode(c, "Invalid request, record not found", gin.H{"id": id}, 404)
 				return
 			}
 
-			log.Println(err)
+			ctrl.ReportError(c, err)
 			ctrl.ErrorWithDataAndCode(c, fmt.Sprintf("Unable to retrieve %v record, try again in a bit", ctrl.name), gin.H{"id": id}, 500)
 			return
 		}
@@ -298,13 +292,12 @@ func (ctrl *Controller[E]) UpdateMany(c *gin.Context) {
 			var existing int64
 			err := ctrl.repository.SQL(c).WithContext(c).Where(query, args...).Where("id != ?", id).Model(entity).Count(&existing).Error
 			if err != nil {
-				log.Println(err)
+				ctrl.ReportError(c, err)
 				ctrl.Error(c, "Something went wrong, check and try again")
 				return
 			}
 
 			if existing > 0 {
-				log.Println(err)
 				ctrl.ErrorWithData(c, fmt.Sprintf("A similar %v record exist, check and try again", ctrl.name), entity)
 				return
 			}
@@ -319,7 +312,7 @@ func (ctrl *Controller[E]) UpdateMany(c *gin.Context) {
 
 	err := ctrl.repository.UpdateMany(c, entity, "id IN ?", ids)
 	if err != nil {
-		log.Println(err)
+		ctrl.ReportError(c, err)
 		ctrl.ErrorWithCode(c, fmt.Sprintf("Unable to update %v record, try again in a bit", ctrl.name), 500)
 		return
 	}
@@ -338,12 +331,12 @@ func (ctrl *Controller[E]) FindOne(c *gin.Context) {
 	entity, err := ctrl.repository.FindOne(c, id)
 	if err != nil {
 		if err == gorm.ErrRecordNotFound {
-			log.Println(err)
+			ctrl.Logger(c).Debug("record not found", "entity", ctrl.name, "error", err)
 			ctrl.ErrorWithCode(c, "Invalid request, record not found", 404)
 			return
 		}
 
-		log.Println(err)
+		ctrl.ReportError(c, err)
 		ctrl.ErrorWithCode(c, fmt.Sprintf("Unable to retrieve %v record, try again in a bit", ctrl.name), 500)
 		return
 	}
@@ -365,9 +358,18 @@ func (ctrl *Controller[E]) FindMany(c *gin.Context) {
 	}
 
 	offset := (page - 1) * perPage
-	entities, err := ctrl.repository.FindManyWithLimit(c, perPage, offset, nil)
+
+	// searching needs a repository implementing contract.Searcher, others list every record
+	var entities []E
+	searcher, ok := ctrl.repository.(contract.Searcher[E])
+	if search := c.Query("search"); ok && search != "" && len(ctrl.searchable) > 0 {
+		entities, err = searcher.Search(c, ctrl.searchable, search, perPage, offset)
+	} else {
+		entities, err = ctrl.repository.FindManyWithLimit(c, perPage, offset, nil)
+	}
+
 	if err != nil {
-		log.Println(err)
+		ctrl.ReportError(c, err)
 		ctrl.ErrorWithCode(c, fmt.Sprintf("Unable to retrieve %v record, try again in a bit", ctrl.name), 500)
 		return
 	}
@@ -381,12 +383,12 @@ func (ctrl *Controller[E]) DeleteOne(c *gin.Context) {
 	entity, err := ctrl.repository.FindOne(c, id)
 	if err != nil {
 		if err == gorm.ErrRecordNotFound {
-			log.Println(err)
+			ctrl.Logger(c).Debug("record not found", "entity", ctrl.name, "error", err)
 			ctrl.ErrorWithCode(c, "Invalid request, record not found", 404)
 			return
 		}
 
-		log.Println(err)
+		ctrl.ReportError(c, err)
 		ctrl.ErrorWithCode(c, fmt.Sprintf("Unable to retrieve %v record, try again in a bit", ctrl.name), 500)
 		return
 	}
@@ -397,7 +399,7 @@ func (ctrl *Controller[E]) DeleteOne(c *gin.Context) {
 
 	err = ctrl.repository.DeleteOne(c, id)
 	if err != nil {
-		log.Println(err)
+		ctrl.ReportError(c, err)
 		ctrl.ErrorWithCode(c, fmt.Sprintf("Unable to remove %v record, try again in a bit", ctrl.name), 500)
 		return
 	}
@@ -418,12 +420,12 @@ func (ctrl *Controller[E]) DeleteMany(c *gin.Context) {
 		entity, err := ctrl.repository.FindOne(c, id)
 		if err != nil {
 			if err == gorm.ErrRecordNotFound {
-				log.Println(err)
+				ctrl.Logger(c).Debug("record not found", "entity", ctrl.name, "error", err)
 				ctrl.ErrorWithDataAndCode(c, "Invalid request, record not found", gin.H{"id": id}, 404)
 				return
 			}
 
-			log.Println(err)
+			ctrl.ReportError(c, err)
 			ctrl.ErrorWithDataAndCode(c, fmt.Sprintf("Unable to retrieve %v record, try again in a bit", ctrl.name), gin.H{"id": id}, 500)
 			return
 		}
@@ -439,7 +441,7 @@ func (ctrl *Controller[E]) DeleteMany(c *gin.Context) {
 
 	err := ctrl.repository.DeleteMany(c, "id IN ?", ids)
 	if err != nil {
-		log.Println(err)
+		ctrl.ReportError(c, err)
 		ctrl.ErrorWithCode(c, fmt.Sprintf("Unable to remove %v record, try again in a bit", ctrl.name), 500)
 		return
 	}
@@ -0,0 +1,68 @@
+package db
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	MySQL    = "mysql"
+	Postgres = "postgres"
+	SQLite   = "sqlite"
+)
+
+var (
+	identifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)*$`)
+)
+
+// Like returns a case insensitive pattern match condition on the column, for use with Where.
+// Postgres needs ILIKE, mysql and sqlite LIKE are case insensitive already.
+func Like(tx *gorm.DB, column string) string {
+	switch tx.Dialector.Name() {
+	case Postgres:
+		return fmt.Sprintf("%v ILIKE ?", column)
+	case SQLite:
+		// sqlite has no default escape character
+		return fmt.Sprintf("%v LIKE ? ESCAPE '\\'", column)
+	default:
+		return fmt.Sprintf("%v LIKE ?", column)
+	}
+}
+
+// Contains returns the LIKE argument matching values containing term, wildcards in term are escaped
+func Contains(term string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return "%" + replacer.Replace(term) + "%"
+}
+
+// JSONExtract returns an expression reading the text value at a dotted path of a json column,
+// e.g. JSONExtract(tx, "metadata", "billing.plan"). Column and path must be plain identifiers.
+func JSONExtract(tx *gorm.DB, column, path string) (string, error) {
+	if !identifier.MatchString(column) || !identifier.MatchString(path) {
+		return "", fmt.Errorf("db: invalid json column %v or path %v", column, path)
+	}
+
+	switch tx.Dialector.Name() {
+	case Postgres:
+		return fmt.Sprintf("%v #>> '{%v}'", column, strings.ReplaceAll(path, ".", ",")), nil
+	case SQLite:
+		return fmt.Sprintf("json_extract(%v, '$.%v')", column, path), nil
+	default:
+		return fmt.Sprintf("JSON_UNQUOTE(JSON_EXTRACT(%v, '$.%v'))", column, path), nil
+	}
+}
+
+// Upsert returns the conflict clause updating every column when a row with the same keys exists.
+// Without columns the primary key is the conflict target, gorm renders the clause for each dialect.
+func Upsert(columns ...string) clause.OnConflict {
+	conflict := clause.OnConflict{UpdateAll: true}
+	for _, column := range columns {
+		conflict.Columns = append(conflict.Columns, clause.Column{Name: column})
+	}
+
+	return conflict
+}
@@ -0,0 +1,32 @@
+package db
+
+import (
+	"errors"
+	"strings"
+
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// IsRetryable reports whether err is a deadlock, lock wait timeout or serialization failure,
+// the transaction was rolled back and running it again may succeed
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var mysqlErr *mysqldriver.MySQLError
+	if errors.As(err, &mysqlErr) {
+		// 1213 deadlock found, 1205 lock wait timeout exceeded
+		return mysqlErr.Number == 1213 || mysqlErr.Number == 1205
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		// 40001 serialization failure, 40P01 deadlock detected
+		return pgErr.Code == "40001" || pgErr.Code == "40P01"
+	}
+
+	message := err.Error()
+	return strings.Contains(message, "database is locked") || strings.Contains(message, "SQLITE_BUSY")
+}
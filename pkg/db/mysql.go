@@ -1,51 +1,90 @@
 package db
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
-	"log"
 	"os"
 	"time"
 
+	"github.com/QubelyLabs/bedrock/pkg/config"
+	mysqldriver "github.com/go-sql-driver/mysql"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
-	"gorm.io/gorm/logger"
 )
 
-var sql *gorm.DB
-
-func InitSQL(host string, port int, user, password, name string) error {
-	m, err := gorm.Open(mysql.New(mysql.Config{
-		DSN: fmt.Sprintf(
-			"%v:%v@tcp(%v:%v)/%v?charset=utf8&parseTime=True&loc=Local",
-			user, password, host, port, name,
-		), // data source name
-		DefaultStringSize:         256,   // default size for string fields
-		DisableDatetimePrecision:  false, // disable datetime precision, which not supported before MySQL 5.6
-		DontSupportRenameIndex:    false, // drop & create when rename index, rename index not supported before MySQL 5.7, MariaDB
-		DontSupportRenameColumn:   false, // `change` when rename column, rename column not supported before MySQL 8, MariaDB
-		SkipInitializeWithVersion: false, // auto configure based on currently MySQL version
-	}), &gorm.Config{
-		Logger: logger.New(
-			log.New(os.Stdout, "\r\n", log.LstdFlags), // io writer
-			logger.Config{
-				SlowThreshold:             time.Second, // Slow SQL threshold
-				LogLevel:                  logger.Info, // Log level
-				IgnoreRecordNotFoundError: false,       // Ignore ErrRecordNotFound error for logger
-				ParameterizedQueries:      false,       // Don't include params in the SQL log
-				Colorful:                  true,        // Disable color
-			},
-		),
-	})
+const (
+	MYSQL_TLS_CONFIG = "bedrock" // Prefix of the tls configs registered per host, e.g. bedrock-db.internal
+)
 
-	if err != nil {
-		return err
+func mysqlDialector(cf *config.Config) (gorm.Dialector, error) {
+	dsn := mysqldriver.NewConfig()
+	dsn.User = cf.DBUser
+	dsn.Passwd = cf.DBPassword
+	dsn.Net = "tcp"
+	dsn.Addr = fmt.Sprintf("%v:%v", cf.DBHost, cf.DBPort)
+	dsn.DBName = cf.DBName
+	dsn.ParseTime = true
+	dsn.Collation = "utf8mb4_unicode_ci"
+	dsn.Params = map[string]string{"charset": "utf8mb4"}
+
+	// times are read in DB_TIMEZONE, the local zone of the process by default
+	dsn.Loc = time.Local
+	if cf.DBTimezone != "" {
+		loc, err := time.LoadLocation(cf.DBTimezone)
+		if err != nil {
+			return nil, fmt.Errorf("db: invalid timezone %v: %w", cf.DBTimezone, err)
+		}
+		dsn.Loc = loc
 	}
 
-	sql = m
+	tlsConfig, err := mysqlTLS(cf)
+	if err != nil {
+		return nil, err
+	}
+	dsn.TLSConfig = tlsConfig
 
-	return nil
+	return mysql.New(mysql.Config{
+		DSN:                       dsn.FormatDSN(), // data source name
+		DefaultStringSize:         256,             // default size for string fields
+		DisableDatetimePrecision:  false,           // disable datetime precision, which not supported before MySQL 5.6
+		DontSupportRenameIndex:    false,           // drop & create when rename index, rename index not supported before MySQL 5.7, MariaDB
+		DontSupportRenameColumn:   false,           // `change` when rename column, rename column not supported before MySQL 8, MariaDB
+		SkipInitializeWithVersion: false,           // auto configure based on currently MySQL version
+	}), nil
 }
 
-func SQL() *gorm.DB {
-	return sql
+// mysqlTLS returns the tls param of the DSN, a DB_TLS_CA registers a config verifying the server against it.
+// The config is registered under the name of the host, so the primary and each replica verify their own server name.
+func mysqlTLS(cf *config.Config) (string, error) {
+	if cf.DBTLSCA == "" {
+		if cf.DBTLS == "" {
+			return "false", nil
+		}
+
+		return cf.DBTLS, nil
+	}
+
+	pem, err := os.ReadFile(cf.DBTLSCA)
+	if err != nil {
+		return "", fmt.Errorf("db: cannot read ca %v: %w", cf.DBTLSCA, err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return "", fmt.Errorf("db: no certificate found in ca %v", cf.DBTLSCA)
+	}
+
+	name := MYSQL_TLS_CONFIG + "-" + cf.DBHost
+	err = mysqldriver.RegisterTLSConfig(name, &tls.Config{
+		RootCAs:            pool,
+		ServerName:         cf.DBHost,
+		InsecureSkipVerify: cf.DBTLS == "skip-verify",
+		MinVersion:         tls.VersionTLS12,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return name, nil
 }
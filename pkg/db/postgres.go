@@ -0,0 +1,63 @@
+package db
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+
+	"github.com/QubelyLabs/bedrock/pkg/config"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func postgresDialector(cf *config.Config) (gorm.Dialector, error) {
+	sslmode, err := postgresSSLMode(cf.DBTLS)
+	if err != nil {
+		return nil, err
+	}
+
+	timezone := cf.DBTimezone
+	if timezone == "" {
+		timezone = "UTC"
+	}
+
+	params := url.Values{}
+	params.Set("sslmode", sslmode)
+	params.Set("TimeZone", timezone)
+	if cf.DBTLSCA != "" {
+		params.Set("sslrootcert", cf.DBTLSCA)
+	}
+
+	// a url escapes the credentials, so passwords may hold spaces, quotes or @
+	dsn := &url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(cf.DBUser, cf.DBPassword),
+		Host:     net.JoinHostPort(cf.DBHost, strconv.Itoa(cf.DBPort)),
+		Path:     "/" + cf.DBName,
+		RawQuery: params.Encode(),
+	}
+
+	return postgres.New(postgres.Config{
+		DSN:                  dsn.String(), // data source name
+		PreferSimpleProtocol: false,        // use the extended protocol, so prepared statements are cached
+	}), nil
+}
+
+// postgresSSLMode maps DB_TLS onto the sslmode, it takes the mysql values as well as the postgres ones
+func postgresSSLMode(value string) (string, error) {
+	switch value {
+	case "", "false":
+		return "disable", nil
+	case "true":
+		return "verify-full", nil
+	case "skip-verify":
+		return "require", nil
+	case "preferred":
+		return "prefer", nil
+	case "disable", "allow", "prefer", "require", "verify-ca", "verify-full":
+		return value, nil
+	default:
+		return "", fmt.Errorf("db: unsupported DB_TLS %v on postgres", value)
+	}
+}
@@ -1,23 +1,155 @@
 package db
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"os"
+	"strings"
+	"time"
 
+	"github.com/QubelyLabs/bedrock/pkg/config"
+	bedrocklogger "github.com/QubelyLabs/bedrock/pkg/logger"
+	"github.com/QubelyLabs/bedrock/pkg/tracing"
 	"github.com/redis/go-redis/v9"
 )
 
-var kv *redis.Client
+const (
+	REDIS_STANDALONE = "standalone"
+	REDIS_SENTINEL   = "sentinel"
+	REDIS_CLUSTER    = "cluster"
+	PING_TIMEOUT     = 5 * time.Second
+)
+
+var kv redis.UniversalClient
+
+// InitKV connects to redis in the REDIS_MODE and pings it, retrying REDIS_CONNECT_RETRIES times with an exponential backoff
+func InitKV(cf *config.Config) error {
+	options, err := redisOptions(cf)
+	if err != nil {
+		return err
+	}
 
-func InitKV(host string, port int, password string, db int) {
-	r := redis.NewClient(&redis.Options{
-		Addr:     fmt.Sprintf("%v:%v", host, port),
-		Password: password,
-		DB:       db,
-	})
+	var r redis.UniversalClient
+	switch strings.ToLower(cf.RedisMode) {
+	case "", REDIS_STANDALONE:
+		r = redis.NewClient(options.Simple())
+	case REDIS_SENTINEL:
+		if options.MasterName == "" {
+			return fmt.Errorf("db: REDIS_MASTER_NAME is required in sentinel mode")
+		}
+		r = redis.NewFailoverClient(options.Failover())
+	case REDIS_CLUSTER:
+		r = redis.NewClusterClient(options.Cluster())
+	default:
+		return fmt.Errorf("db: unsupported redis mode %v", cf.RedisMode)
+	}
+	r.AddHook(tracing.NewRedisHook())
+
+	if err := ping(cf, r); err != nil {
+		r.Close()
+		return err
+	}
 
 	kv = r
+
+	return nil
 }
 
-func KV() *redis.Client {
+func redisOptions(cf *config.Config) (*redis.UniversalOptions, error) {
+	addrs := []string{fmt.Sprintf("%v:%v", cf.RedisHost, cf.RedisPort)}
+	if cf.RedisAddrs != "" {
+		addrs = nil
+		for _, addr := range strings.Split(cf.RedisAddrs, ",") {
+			if addr = strings.TrimSpace(addr); addr != "" {
+				addrs = append(addrs, addr)
+			}
+		}
+	}
+
+	options := &redis.UniversalOptions{
+		Addrs:            addrs,
+		DB:               cf.RedisDB,
+		Username:         cf.RedisUser,
+		Password:         cf.RedisPassword,
+		SentinelPassword: cf.RedisSentinelPassword,
+		MasterName:       cf.RedisMasterName,
+		PoolSize:         cf.RedisPoolSize,
+		MinIdleConns:     cf.RedisMinIdleConns,
+		MaxIdleConns:     cf.RedisMaxIdleConns,
+		ConnMaxIdleTime:  time.Duration(cf.RedisConnMaxIdleTime) * time.Second,
+		ConnMaxLifetime:  time.Duration(cf.RedisConnMaxLifetime) * time.Second,
+		PoolTimeout:      time.Duration(cf.RedisPoolTimeout) * time.Second,
+		DialTimeout:      time.Duration(cf.RedisDialTimeout) * time.Second,
+		ReadTimeout:      time.Duration(cf.RedisReadTimeout) * time.Second,
+		WriteTimeout:     time.Duration(cf.RedisWriteTimeout) * time.Second,
+	}
+
+	if cf.RedisTLS {
+		tlsConfig := &tls.Config{
+			InsecureSkipVerify: cf.RedisTLSSkipVerify,
+			MinVersion:         tls.VersionTLS12,
+		}
+
+		if cf.RedisTLSCA != "" {
+			pem, err := os.ReadFile(cf.RedisTLSCA)
+			if err != nil {
+				return nil, fmt.Errorf("db: cannot read redis ca %v: %w", cf.RedisTLSCA, err)
+			}
+
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("db: no certificate found in redis ca %v", cf.RedisTLSCA)
+			}
+			tlsConfig.RootCAs = pool
+		}
+
+		options.TLSConfig = tlsConfig
+	}
+
+	return options, nil
+}
+
+// ping checks the connection, retrying while redis is unreachable
+func ping(cf *config.Config, r redis.UniversalClient) error {
+	backoff := CONNECT_BACKOFF
+	if cf.RedisConnectBackoff > 0 {
+		backoff = time.Duration(cf.RedisConnectBackoff) * time.Second
+	}
+
+	for attempt := 0; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), PING_TIMEOUT)
+		err := r.Ping(ctx).Err()
+		cancel()
+
+		if err == nil {
+			return nil
+		}
+
+		if attempt >= cf.RedisConnectRetries {
+			return fmt.Errorf("db: cannot connect to redis after %v attempts: %w", attempt+1, err)
+		}
+
+		bedrocklogger.For("db").Warn("cannot connect to redis, retrying", "attempt", attempt+1, "backoff", backoff, "error", err)
+		time.Sleep(backoff)
+
+		backoff *= 2
+		if backoff > MAX_CONNECT_BACKOFF {
+			backoff = MAX_CONNECT_BACKOFF
+		}
+	}
+}
+
+func KV() redis.UniversalClient {
 	return kv
 }
+
+// CloseKV closes the connection pool
+func CloseKV() error {
+	if kv == nil {
+		return nil
+	}
+
+	return kv.Close()
+}
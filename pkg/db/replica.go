@@ -0,0 +1,222 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/QubelyLabs/bedrock/pkg/config"
+	bedrocklogger "github.com/QubelyLabs/bedrock/pkg/logger"
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+const (
+	REPLICA_CHECK_INTERVAL = 10 * time.Second
+	REPLICA_CHECK_TIMEOUT  = 2 * time.Second
+	STICKY_WINDOW          = 5 * time.Second
+)
+
+var (
+	replicas     *replicaPolicy
+	stickyWindow = STICKY_WINDOW
+)
+
+// replicaPolicy spreads reads over the healthy replicas and falls back to the primary when none is healthy
+type replicaPolicy struct {
+	primary gorm.ConnPool
+	counter atomic.Uint64
+
+	mutex   sync.RWMutex
+	pools   []gorm.ConnPool
+	healthy map[gorm.ConnPool]bool
+
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+func (p *replicaPolicy) Resolve(connPools []gorm.ConnPool) gorm.ConnPool {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	candidates := make([]gorm.ConnPool, 0, len(connPools))
+	for _, pool := range connPools {
+		if pool == p.primary {
+			continue
+		}
+
+		if healthy, ok := p.healthy[pool]; !ok || healthy {
+			candidates = append(candidates, pool)
+		}
+	}
+
+	if len(candidates) == 0 {
+		return p.primary
+	}
+
+	return candidates[p.counter.Add(1)%uint64(len(candidates))]
+}
+
+// check pings every replica and records whether it can serve reads
+func (p *replicaPolicy) check() {
+	for i, pool := range p.pools {
+		pinger, ok := pool.(interface{ PingContext(context.Context) error })
+		if !ok {
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), REPLICA_CHECK_TIMEOUT)
+		err := pinger.PingContext(ctx)
+		cancel()
+
+		p.mutex.Lock()
+		was, known := p.healthy[pool]
+		p.healthy[pool] = err == nil
+		p.mutex.Unlock()
+
+		if err != nil && (was || !known) {
+			bedrocklogger.For("db").Warn("replica is unhealthy, reads fall back to the other replicas or the primary", "replica", i, "error", err)
+		} else if err == nil && known && !was {
+			bedrocklogger.For("db").Info("replica is healthy again", "replica", i)
+		}
+	}
+}
+
+func (p *replicaPolicy) watch(interval time.Duration) {
+	defer close(p.stopped)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			p.check()
+		case <-p.stop:
+			return
+		}
+	}
+}
+
+// close stops the health checks and closes the replica pools
+func (p *replicaPolicy) close() error {
+	close(p.stop)
+	<-p.stopped
+
+	var errs []error
+	for _, pool := range p.pools {
+		if closer, ok := pool.(interface{ Close() error }); ok {
+			errs = append(errs, closer.Close())
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// useReplicas routes reads outside transactions to the DB_REPLICAS, writes and reads in a transaction use the primary
+func useReplicas(cf *config.Config, m *gorm.DB, name string) error {
+	if cf.DBStickyWindow > 0 {
+		stickyWindow = time.Duration(cf.DBStickyWindow) * time.Second
+	}
+
+	hosts := strings.Split(cf.DBReplicas, ",")
+	dialectors := make([]gorm.Dialector, 0, len(hosts))
+	for _, host := range hosts {
+		host = strings.TrimSpace(host)
+		if host == "" {
+			continue
+		}
+
+		replica := *cf
+		replica.DBHost = host
+		if h, port, err := net.SplitHostPort(host); err == nil {
+			replica.DBHost = h
+			if replica.DBPort, err = strconv.Atoi(port); err != nil {
+				return fmt.Errorf("db: invalid replica port %v", host)
+			}
+		}
+
+		dialector, err := dialectorFor(&replica, name)
+		if err != nil {
+			return err
+		}
+		dialectors = append(dialectors, dialector)
+	}
+
+	if len(dialectors) == 0 {
+		return nil
+	}
+
+	primary, err := m.DB()
+	if err != nil {
+		return err
+	}
+
+	// the primary is the last replica, so the policy always sees it as a fallback,
+	// dbresolver skips the policy when a single replica is configured otherwise
+	fallback, err := dialectorFromConn(name, primary)
+	if err != nil {
+		return err
+	}
+	dialectors = append(dialectors, fallback)
+
+	policy := &replicaPolicy{
+		primary: primary,
+		healthy: map[gorm.ConnPool]bool{},
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+
+	resolver := dbresolver.Register(dbresolver.Config{
+		Replicas: dialectors,
+		Policy:   policy,
+	})
+	if err := m.Use(resolver); err != nil {
+		return err
+	}
+
+	err = resolver.Call(func(pool gorm.ConnPool) error {
+		if pool == gorm.ConnPool(primary) {
+			return nil
+		}
+
+		policy.pools = append(policy.pools, pool)
+		return configurePool(cf, pool)
+	})
+	if err != nil {
+		return err
+	}
+
+	interval := REPLICA_CHECK_INTERVAL
+	if cf.DBReplicaCheckInterval > 0 {
+		interval = time.Duration(cf.DBReplicaCheckInterval) * time.Second
+	}
+
+	policy.check()
+	go policy.watch(interval)
+
+	replicas = policy
+
+	return nil
+}
+
+// HasReplicas reports whether reads are routed to read replicas
+func HasReplicas() bool {
+	return replicas != nil
+}
+
+// StickyWindow returns how long reads stay on the primary after a write, see DB_STICKY_WINDOW
+func StickyWindow() time.Duration {
+	return stickyWindow
+}
+
+// Primary pins the reads of tx to the primary, e.g. to read a row right after writing it
+func Primary(tx *gorm.DB) *gorm.DB {
+	return tx.Clauses(dbresolver.Write)
+}
@@ -0,0 +1,249 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/QubelyLabs/bedrock/pkg/config"
+	bedrocklogger "github.com/QubelyLabs/bedrock/pkg/logger"
+	"github.com/QubelyLabs/bedrock/pkg/metrics"
+	"github.com/QubelyLabs/bedrock/pkg/tracing"
+	"github.com/glebarez/sqlite"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const (
+	SLOW_THRESHOLD      = time.Second
+	CONNECT_BACKOFF     = time.Second
+	MAX_CONNECT_BACKOFF = 30 * time.Second
+)
+
+var (
+	sql     *gorm.DB
+	dialect string
+)
+
+// InitSQL opens the database of the DB_DIALECT, mysql is used when no dialect is configured.
+// Opening is retried DB_CONNECT_RETRIES times with an exponential backoff, so a service can start before its database.
+func InitSQL(cf *config.Config) error {
+	name := strings.ToLower(cf.DBDialect)
+	switch name {
+	case "":
+		name = MySQL
+	case "postgresql":
+		name = Postgres
+	}
+
+	dialector, err := dialectorFor(cf, name)
+	if err != nil {
+		return err
+	}
+
+	level, err := logLevel(cf)
+	if err != nil {
+		return err
+	}
+
+	slowThreshold := SLOW_THRESHOLD
+	if cf.DBSlowThreshold > 0 {
+		slowThreshold = time.Duration(cf.DBSlowThreshold) * time.Millisecond
+	}
+
+	m, err := open(cf, dialector, &gorm.Config{
+		Logger: logger.New(
+			log.New(os.Stdout, "\r\n", log.LstdFlags), // io writer
+			logger.Config{
+				SlowThreshold:             slowThreshold, // Slow SQL threshold
+				LogLevel:                  level,         // Log level
+				IgnoreRecordNotFoundError: false,         // Ignore ErrRecordNotFound error for logger
+				ParameterizedQueries:      false,         // Don't include params in the SQL log
+				Colorful:                  true,          // Disable color
+			},
+		),
+	})
+
+	if err != nil {
+		return err
+	}
+
+	sqlDB, err := m.DB()
+	if err != nil {
+		return err
+	}
+
+	if err := configurePool(cf, sqlDB); err != nil {
+		return err
+	}
+
+	if err := m.Use(tracing.NewGormPlugin()); err != nil {
+		return err
+	}
+
+	if err := m.Use(metrics.NewGormPlugin()); err != nil {
+		return err
+	}
+
+	if err := metrics.RegisterDB(m, "primary"); err != nil {
+		return err
+	}
+
+	if cf.DBReplicas != "" {
+		if err := useReplicas(cf, m, name); err != nil {
+			return err
+		}
+	}
+
+	sql = m
+	dialect = name
+
+	return nil
+}
+
+// dialectorFor returns the dialector of the named dialect
+func dialectorFor(cf *config.Config, name string) (gorm.Dialector, error) {
+	switch name {
+	case MySQL:
+		return mysqlDialector(cf)
+	case Postgres:
+		return postgresDialector(cf)
+	case SQLite:
+		return sqliteDialector(cf)
+	default:
+		return nil, fmt.Errorf("db: unsupported dialect %v", cf.DBDialect)
+	}
+}
+
+// dialectorFromConn returns a dialector of the named dialect reusing an opened pool
+func dialectorFromConn(name string, conn gorm.ConnPool) (gorm.Dialector, error) {
+	switch name {
+	case MySQL:
+		return mysql.New(mysql.Config{Conn: conn}), nil
+	case Postgres:
+		return postgres.New(postgres.Config{Conn: conn}), nil
+	case SQLite:
+		return &sqlite.Dialector{Conn: conn}, nil
+	default:
+		return nil, fmt.Errorf("db: unsupported dialect %v", name)
+	}
+}
+
+// open connects to the database, retrying while it is unreachable
+func open(cf *config.Config, dialector gorm.Dialector, gormConfig *gorm.Config) (*gorm.DB, error) {
+	backoff := CONNECT_BACKOFF
+	if cf.DBConnectBackoff > 0 {
+		backoff = time.Duration(cf.DBConnectBackoff) * time.Second
+	}
+
+	for attempt := 0; ; attempt++ {
+		m, err := gorm.Open(dialector, gormConfig)
+		if err == nil {
+			return m, nil
+		}
+
+		if attempt >= cf.DBConnectRetries {
+			return nil, fmt.Errorf("db: cannot connect after %v attempts: %w", attempt+1, err)
+		}
+
+		bedrocklogger.For("db").Warn("cannot connect to the database, retrying", "attempt", attempt+1, "backoff", backoff, "error", err)
+		time.Sleep(backoff)
+
+		backoff *= 2
+		if backoff > MAX_CONNECT_BACKOFF {
+			backoff = MAX_CONNECT_BACKOFF
+		}
+	}
+}
+
+// configurePool applies the DB_MAX_* and DB_CONN_* settings, zero values keep the database/sql defaults
+func configurePool(cf *config.Config, connPool gorm.ConnPool) error {
+	pool, ok := connPool.(interface {
+		SetMaxOpenConns(int)
+		SetMaxIdleConns(int)
+		SetConnMaxLifetime(time.Duration)
+		SetConnMaxIdleTime(time.Duration)
+	})
+	if !ok {
+		return fmt.Errorf("db: cannot configure pool %T", connPool)
+	}
+
+	if cf.DBMaxOpenConns > 0 {
+		pool.SetMaxOpenConns(cf.DBMaxOpenConns)
+	}
+
+	if cf.DBMaxIdleConns > 0 {
+		pool.SetMaxIdleConns(cf.DBMaxIdleConns)
+	}
+
+	if cf.DBConnMaxLifetime > 0 {
+		pool.SetConnMaxLifetime(time.Duration(cf.DBConnMaxLifetime) * time.Second)
+	}
+
+	if cf.DBConnMaxIdleTime > 0 {
+		pool.SetConnMaxIdleTime(time.Duration(cf.DBConnMaxIdleTime) * time.Second)
+	}
+
+	return nil
+}
+
+// logLevel returns the DB_LOG_LEVEL, without it every query is logged when DB_LOG is on
+// and only errors and slow queries otherwise
+func logLevel(cf *config.Config) (logger.LogLevel, error) {
+	switch strings.ToLower(cf.DBLogLevel) {
+	case "":
+		if cf.DBLog {
+			return logger.Info, nil
+		}
+		return logger.Warn, nil
+	case "silent":
+		return logger.Silent, nil
+	case "error":
+		return logger.Error, nil
+	case "warn", "warning":
+		return logger.Warn, nil
+	case "info":
+		return logger.Info, nil
+	default:
+		return logger.Silent, fmt.Errorf("db: invalid log level %v", cf.DBLogLevel)
+	}
+}
+
+func SQL() *gorm.DB {
+	return sql
+}
+
+// Dialect returns the dialect of the opened database
+func Dialect() string {
+	return dialect
+}
+
+// CloseSQL closes the connection pools, queries in flight are allowed to finish
+func CloseSQL() error {
+	if sql == nil {
+		return nil
+	}
+
+	var errs []error
+	if replicas != nil {
+		errs = append(errs, replicas.close())
+		replicas = nil
+	}
+
+	sqlDB, err := sql.DB()
+	if err != nil {
+		return errors.Join(append(errs, err)...)
+	}
+
+	return errors.Join(append(errs, sqlDB.Close())...)
+}
+
+// Close closes the sql and redis connection pools
+func Close() error {
+	return errors.Join(CloseSQL(), CloseKV())
+}
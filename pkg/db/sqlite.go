@@ -0,0 +1,19 @@
+package db
+
+import (
+	"github.com/QubelyLabs/bedrock/pkg/config"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+// sqliteDialector opens the DB_NAME file, use ":memory:" for a throwaway database in tests.
+// The driver is pure go, so no cgo toolchain is needed.
+func sqliteDialector(cf *config.Config) (gorm.Dialector, error) {
+	name := cf.DBName
+	if name == "" || name == ":memory:" {
+		// a shared cache keeps the in memory database alive across pooled connections
+		name = "file::memory:?cache=shared"
+	}
+
+	return sqlite.Open(name), nil
+}
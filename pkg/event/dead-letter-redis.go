@@ -0,0 +1,134 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	DEAD_LETTER_KEY = "{bedrock:dead-letters}"
+)
+
+// redisDeadLetters keeps dead letters as json in a hash, indexed by creation time in a sorted set
+type redisDeadLetters struct {
+	client redis.UniversalClient
+	key    string
+}
+
+func (s *redisDeadLetters) Save(ctx context.Context, letter DeadLetter) error {
+	buf, err := json.Marshal(letter)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, s.key, letter.ID, string(buf))
+		pipe.ZAddNX(ctx, s.index(), redis.Z{Score: float64(letter.CreatedAt.UnixNano()), Member: letter.ID})
+		return nil
+	})
+
+	return err
+}
+
+func (s *redisDeadLetters) Get(ctx context.Context, id string) (DeadLetter, error) {
+	var letter DeadLetter
+
+	raw, err := s.client.HGet(ctx, s.key, id).Result()
+	if errors.Is(err, redis.Nil) {
+		return letter, ErrDeadLetterNotFound
+	} else if err != nil {
+		return letter, err
+	}
+
+	err = json.Unmarshal([]byte(raw), &letter)
+	return letter, err
+}
+
+// List pages the index directly without a filter, filtering reads every dead letter
+func (s *redisDeadLetters) List(ctx context.Context, filter DeadLetterFilter, limit, offset int) ([]DeadLetter, error) {
+	filtered := filter.Event != "" || filter.Listener != ""
+
+	start, stop := int64(0), int64(-1)
+	if !filtered {
+		start = int64(offset)
+		if limit >= 0 {
+			stop = start + int64(limit) - 1
+		}
+	}
+
+	ids, err := s.client.ZRange(ctx, s.index(), start, stop).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	letters := []DeadLetter{}
+	if len(ids) == 0 {
+		return letters, nil
+	}
+
+	values, err := s.client.HMGet(ctx, s.key, ids...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, value := range values {
+		raw, ok := value.(string)
+		if !ok {
+			// deleted between the two reads
+			continue
+		}
+
+		var letter DeadLetter
+		if err := json.Unmarshal([]byte(raw), &letter); err != nil {
+			return nil, err
+		}
+
+		if filter.matches(letter) {
+			letters = append(letters, letter)
+		}
+	}
+
+	if filtered {
+		letters = page(letters, limit, offset)
+	}
+
+	return letters, nil
+}
+
+func (s *redisDeadLetters) Delete(ctx context.Context, id string) error {
+	var deleted *redis.IntCmd
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		deleted = pipe.HDel(ctx, s.key, id)
+		pipe.ZRem(ctx, s.index(), id)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if deleted.Val() == 0 {
+		return ErrDeadLetterNotFound
+	}
+
+	return nil
+}
+
+func (s *redisDeadLetters) index() string {
+	return s.key + ":index"
+}
+
+// NewRedisDeadLetters creates a store under the key, DEAD_LETTER_KEY when empty.
+// The key is wrapped in a hash tag, so the hash and its index share a cluster slot.
+func NewRedisDeadLetters(client redis.UniversalClient, key string) *redisDeadLetters {
+	if key == "" {
+		key = DEAD_LETTER_KEY
+	} else if !strings.HasPrefix(key, "{") {
+		key = "{" + key + "}"
+	}
+
+	return &redisDeadLetters{client, key}
+}
@@ -0,0 +1,159 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/QubelyLabs/bedrock/pkg/db"
+	"github.com/QubelyLabs/bedrock/pkg/migration"
+	"gorm.io/gorm"
+)
+
+const (
+	DEAD_LETTER_TABLE = "event_dead_letters"
+)
+
+// deadLetterRecord is a row of the dead letter table, the event and attempts are stored as json
+type deadLetterRecord struct {
+	ID        string    `gorm:"primaryKey;size:36"`
+	EventName string    `gorm:"size:255;index"`
+	Listener  string    `gorm:"size:255;index"`
+	Event     string    `gorm:"type:text"`
+	Error     string    `gorm:"type:text"`
+	Attempts  string    `gorm:"type:text"`
+	CreatedAt time.Time `gorm:"index"`
+	UpdatedAt time.Time
+}
+
+func (deadLetterRecord) TableName() string {
+	return DEAD_LETTER_TABLE
+}
+
+func (r *deadLetterRecord) letter() (DeadLetter, error) {
+	letter := DeadLetter{
+		ID:        r.ID,
+		Listener:  r.Listener,
+		Error:     r.Error,
+		CreatedAt: r.CreatedAt,
+		UpdatedAt: r.UpdatedAt,
+	}
+
+	if err := json.Unmarshal([]byte(r.Event), &letter.Event); err != nil {
+		return letter, err
+	}
+
+	if err := json.Unmarshal([]byte(r.Attempts), &letter.Attempts); err != nil {
+		return letter, err
+	}
+
+	return letter, nil
+}
+
+// sqlDeadLetters keeps dead letters in the event_dead_letters table
+type sqlDeadLetters struct {
+	db *gorm.DB
+}
+
+func (s *sqlDeadLetters) Save(ctx context.Context, letter DeadLetter) error {
+	event, err := json.Marshal(letter.Event)
+	if err != nil {
+		return err
+	}
+
+	attempts, err := json.Marshal(letter.Attempts)
+	if err != nil {
+		return err
+	}
+
+	return s.sql(ctx).Save(&deadLetterRecord{
+		ID:        letter.ID,
+		EventName: letter.Event.Name,
+		Listener:  letter.Listener,
+		Event:     string(event),
+		Error:     letter.Error,
+		Attempts:  string(attempts),
+		CreatedAt: letter.CreatedAt,
+		UpdatedAt: letter.UpdatedAt,
+	}).Error
+}
+
+func (s *sqlDeadLetters) Get(ctx context.Context, id string) (DeadLetter, error) {
+	record := &deadLetterRecord{}
+	err := db.Primary(s.sql(ctx)).Where("id = ?", id).First(record).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return DeadLetter{}, ErrDeadLetterNotFound
+	} else if err != nil {
+		return DeadLetter{}, err
+	}
+
+	return record.letter()
+}
+
+func (s *sqlDeadLetters) List(ctx context.Context, filter DeadLetterFilter, limit, offset int) ([]DeadLetter, error) {
+	query := s.sql(ctx)
+	if filter.Event != "" {
+		query = query.Where("event_name = ?", filter.Event)
+	}
+	if filter.Listener != "" {
+		query = query.Where("listener = ?", filter.Listener)
+	}
+
+	var records []deadLetterRecord
+	err := query.Order("created_at").Limit(limit).Offset(offset).Find(&records).Error
+	if err != nil {
+		return nil, err
+	}
+
+	letters := make([]DeadLetter, 0, len(records))
+	for _, record := range records {
+		letter, err := record.letter()
+		if err != nil {
+			return nil, err
+		}
+		letters = append(letters, letter)
+	}
+
+	return letters, nil
+}
+
+func (s *sqlDeadLetters) Delete(ctx context.Context, id string) error {
+	result := s.sql(ctx).Where("id = ?", id).Delete(&deadLetterRecord{})
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrDeadLetterNotFound
+	}
+
+	return nil
+}
+
+func (s *sqlDeadLetters) sql(ctx context.Context) *gorm.DB {
+	if s.db != nil {
+		return s.db.WithContext(ctx)
+	}
+
+	return db.SQL().WithContext(ctx)
+}
+
+// NewSQLDeadLetters creates a store on the event_dead_letters table of the database, nil uses db.SQL() at run time
+func NewSQLDeadLetters(d *gorm.DB) *sqlDeadLetters {
+	return &sqlDeadLetters{d}
+}
+
+// DeadLetterMigration creates the dead letter table of the sql store
+func DeadLetterMigration(version string) *migration.Migration {
+	return &migration.Migration{
+		Version: version,
+		Name:    "create event dead letters",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&deadLetterRecord{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&deadLetterRecord{})
+		},
+	}
+}
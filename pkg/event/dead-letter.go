@@ -0,0 +1,300 @@
+package event
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/QubelyLabs/bedrock/pkg/logger"
+	"github.com/google/uuid"
+)
+
+const (
+	DEAD_LETTER_LIMIT = 1000
+)
+
+var (
+	ErrDeadLetterNotFound = errors.New("event: dead letter not found")
+	ErrListenerNotFound   = errors.New("event: listener not found")
+)
+
+// Attempt is a failed delivery of an event to a listener
+type Attempt struct {
+	At    time.Time `json:"at"`
+	Error string    `json:"error"`
+}
+
+// DeadLetter is an event a listener failed to handle within the attempts of its retry policy
+type DeadLetter struct {
+	ID        string    `json:"id"`
+	Event     Event     `json:"event"`
+	Listener  string    `json:"listener"`
+	Error     string    `json:"error"`
+	Attempts  []Attempt `json:"attempts"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// DeadLetterFilter selects dead letters by event and listener name, empty fields match everything
+type DeadLetterFilter struct {
+	Event    string
+	Listener string
+}
+
+func (f DeadLetterFilter) matches(letter DeadLetter) bool {
+	return (f.Event == "" || f.Event == letter.Event.Name) && (f.Listener == "" || f.Listener == letter.Listener)
+}
+
+// DeadLetterStore keeps dead letters until they are replayed or discarded
+type DeadLetterStore interface {
+	// Save adds the dead letter or replaces the one with the same ID
+	Save(ctx context.Context, letter DeadLetter) error
+	Get(ctx context.Context, id string) (DeadLetter, error)
+	// List returns the dead letters matching the filter, oldest first, a negative limit returns all
+	List(ctx context.Context, filter DeadLetterFilter, limit, offset int) ([]DeadLetter, error)
+	Delete(ctx context.Context, id string) error
+}
+
+var (
+	deadLetters DeadLetterStore = NewMemoryDeadLetters(DEAD_LETTER_LIMIT)
+)
+
+// SetDeadLetters replaces the dead letter store, nil drops failed events after logging them
+func SetDeadLetters(store DeadLetterStore) {
+	deadLetters = store
+}
+
+// DeadLetters returns the dead letter store
+func DeadLetters() DeadLetterStore {
+	return deadLetters
+}
+
+// ListDeadLetters lists the dead letters matching the filter, oldest first
+func ListDeadLetters(ctx context.Context, filter DeadLetterFilter, limit, offset int) ([]DeadLetter, error) {
+	if deadLetters == nil {
+		return []DeadLetter{}, nil
+	}
+
+	return deadLetters.List(ctx, filter, limit, offset)
+}
+
+// GetDeadLetter returns a dead letter
+func GetDeadLetter(ctx context.Context, id string) (DeadLetter, error) {
+	if deadLetters == nil {
+		return DeadLetter{}, ErrDeadLetterNotFound
+	}
+
+	return deadLetters.Get(ctx, id)
+}
+
+// bury records the failed delivery of an event
+func bury(e Event, listener string, attempts []Attempt) {
+	if deadLetters == nil || len(attempts) == 0 {
+		return
+	}
+
+	now := time.Now()
+	letter := DeadLetter{
+		ID:        uuid.New().String(),
+		Event:     e,
+		Listener:  listener,
+		Error:     attempts[len(attempts)-1].Error,
+		Attempts:  attempts,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	if err := deadLetters.Save(context.Background(), letter); err != nil {
+		logger.For("event").Error("cannot save dead letter, event dropped", "event", e.Name, "listener", listener, "error", err)
+	}
+}
+
+// Replay delivers a dead letter to its listener once, it is deleted when handled and keeps the new attempt otherwise
+func Replay(ctx context.Context, id string) error {
+	if deadLetters == nil {
+		return ErrDeadLetterNotFound
+	}
+
+	letter, err := deadLetters.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	listener, ok := findListener(letter.Listener)
+	if !ok {
+		return fmt.Errorf("%w: %v", ErrListenerNotFound, letter.Listener)
+	}
+
+	if err := handle(listener, letter.Event); err != nil {
+		letter.Error = err.Error()
+		letter.Attempts = append(letter.Attempts, Attempt{At: time.Now(), Error: err.Error()})
+		letter.UpdatedAt = time.Now()
+
+		if e := deadLetters.Save(ctx, letter); e != nil {
+			return errors.Join(err, e)
+		}
+		return err
+	}
+
+	return deadLetters.Delete(ctx, id)
+}
+
+// ReplayAll replays up to limit of the oldest dead letters matching the filter, a negative limit replays all.
+// It returns how many were handled and how many failed again.
+func ReplayAll(ctx context.Context, filter DeadLetterFilter, limit int) (int, int, error) {
+	if deadLetters == nil {
+		return 0, 0, nil
+	}
+
+	letters, err := deadLetters.List(ctx, filter, limit, 0)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	replayed, failed := 0, 0
+	for _, letter := range letters {
+		if ctx.Err() != nil {
+			return replayed, failed, ctx.Err()
+		}
+
+		if err := Replay(ctx, letter.ID); err != nil {
+			logger.For("event").Warn("dead letter replay failed", "id", letter.ID, "event", letter.Event.Name, "listener", letter.Listener, "error", err)
+			failed++
+			continue
+		}
+		replayed++
+	}
+
+	return replayed, failed, nil
+}
+
+// Discard deletes a dead letter without replaying it
+func Discard(ctx context.Context, id string) error {
+	if deadLetters == nil {
+		return ErrDeadLetterNotFound
+	}
+
+	return deadLetters.Delete(ctx, id)
+}
+
+// DiscardAll deletes the dead letters matching the filter and returns how many were deleted
+func DiscardAll(ctx context.Context, filter DeadLetterFilter) (int, error) {
+	if deadLetters == nil {
+		return 0, nil
+	}
+
+	letters, err := deadLetters.List(ctx, filter, -1, 0)
+	if err != nil {
+		return 0, err
+	}
+
+	for i, letter := range letters {
+		if err := deadLetters.Delete(ctx, letter.ID); err != nil {
+			return i, err
+		}
+	}
+
+	return len(letters), nil
+}
+
+// memoryDeadLetters keeps the latest dead letters in the process, the oldest are dropped over the limit
+type memoryDeadLetters struct {
+	mutex   sync.RWMutex
+	limit   int
+	letters map[string]DeadLetter
+}
+
+func (s *memoryDeadLetters) Save(ctx context.Context, letter DeadLetter) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.letters[letter.ID] = letter
+
+	if len(s.letters) > s.limit {
+		oldest := s.sorted()[0]
+		delete(s.letters, oldest.ID)
+		logger.For("event").Warn("dead letter limit reached, oldest dropped", "id", oldest.ID, "event", oldest.Event.Name)
+	}
+
+	return nil
+}
+
+func (s *memoryDeadLetters) Get(ctx context.Context, id string) (DeadLetter, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	letter, ok := s.letters[id]
+	if !ok {
+		return letter, ErrDeadLetterNotFound
+	}
+
+	return letter, nil
+}
+
+func (s *memoryDeadLetters) List(ctx context.Context, filter DeadLetterFilter, limit, offset int) ([]DeadLetter, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	letters := []DeadLetter{}
+	for _, letter := range s.sorted() {
+		if filter.matches(letter) {
+			letters = append(letters, letter)
+		}
+	}
+
+	return page(letters, limit, offset), nil
+}
+
+func (s *memoryDeadLetters) Delete(ctx context.Context, id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.letters[id]; !ok {
+		return ErrDeadLetterNotFound
+	}
+
+	delete(s.letters, id)
+	return nil
+}
+
+func (s *memoryDeadLetters) sorted() []DeadLetter {
+	letters := make([]DeadLetter, 0, len(s.letters))
+	for _, letter := range s.letters {
+		letters = append(letters, letter)
+	}
+
+	sort.Slice(letters, func(i, j int) bool {
+		return letters[i].CreatedAt.Before(letters[j].CreatedAt)
+	})
+
+	return letters
+}
+
+// NewMemoryDeadLetters creates an in process store keeping up to limit dead letters
+func NewMemoryDeadLetters(limit int) *memoryDeadLetters {
+	if limit <= 0 {
+		limit = DEAD_LETTER_LIMIT
+	}
+
+	return &memoryDeadLetters{limit: limit, letters: map[string]DeadLetter{}}
+}
+
+func page(letters []DeadLetter, limit, offset int) []DeadLetter {
+	if offset < 0 {
+		offset = 0
+	}
+
+	if offset > len(letters) {
+		return []DeadLetter{}
+	}
+
+	letters = letters[offset:]
+	if limit >= 0 && limit < len(letters) {
+		letters = letters[:limit]
+	}
+
+	return letters
+}
@@ -0,0 +1,136 @@
+package event
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func newDeadLetter(id, event, listener string, created time.Time) DeadLetter {
+	return DeadLetter{ID: id, Event: Event{Name: event}, Listener: listener, CreatedAt: created}
+}
+
+func letterIDs(letters []DeadLetter) []string {
+	ids := []string{}
+	for _, letter := range letters {
+		ids = append(ids, letter.ID)
+	}
+
+	return ids
+}
+
+func TestMemoryDeadLettersList(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	s := NewMemoryDeadLetters(10)
+	s.Save(ctx, newDeadLetter("c", "invoice.paid", "mailer", now.Add(3*time.Second)))
+	s.Save(ctx, newDeadLetter("a", "invoice.paid", "ledger", now.Add(time.Second)))
+	s.Save(ctx, newDeadLetter("b", "invoice.failed", "mailer", now.Add(2*time.Second)))
+
+	tests := []struct {
+		name          string
+		filter        DeadLetterFilter
+		limit, offset int
+		want          []string
+	}{
+		{"all, oldest first", DeadLetterFilter{}, -1, 0, []string{"a", "b", "c"}},
+		{"by event", DeadLetterFilter{Event: "invoice.paid"}, -1, 0, []string{"a", "c"}},
+		{"by listener", DeadLetterFilter{Listener: "mailer"}, -1, 0, []string{"b", "c"}},
+		{"by event and listener", DeadLetterFilter{Event: "invoice.paid", Listener: "mailer"}, -1, 0, []string{"c"}},
+		{"no match", DeadLetterFilter{Listener: "audit"}, -1, 0, []string{}},
+		{"limit", DeadLetterFilter{}, 2, 0, []string{"a", "b"}},
+		{"limit and offset", DeadLetterFilter{}, 1, 1, []string{"b"}},
+		{"offset past the end", DeadLetterFilter{}, 10, 5, []string{}},
+		{"negative offset", DeadLetterFilter{}, 2, -3, []string{"a", "b"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			letters, err := s.List(ctx, tt.filter, tt.limit, tt.offset)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := letterIDs(letters); !equalIDs(got, tt.want) {
+				t.Errorf("List(%+v, %v, %v) = %v, want %v", tt.filter, tt.limit, tt.offset, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoryDeadLettersSaveGetDelete(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	s := NewMemoryDeadLetters(10)
+	letter := newDeadLetter("a", "invoice.paid", "mailer", now)
+	s.Save(ctx, letter)
+
+	letter.Error = "smtp down"
+	s.Save(ctx, letter)
+
+	got, err := s.Get(ctx, "a")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got.Error != "smtp down" {
+		t.Errorf("Save of the same id did not replace the letter: %+v", got)
+	}
+
+	if letters, _ := s.List(ctx, DeadLetterFilter{}, -1, 0); len(letters) != 1 {
+		t.Errorf("Save of the same id left %v letters, want 1", len(letters))
+	}
+
+	if err := s.Delete(ctx, "a"); err != nil {
+		t.Errorf("Delete: %v", err)
+	}
+	if _, err := s.Get(ctx, "a"); !errors.Is(err, ErrDeadLetterNotFound) {
+		t.Errorf("Get after Delete: got %v, want ErrDeadLetterNotFound", err)
+	}
+	if err := s.Delete(ctx, "a"); !errors.Is(err, ErrDeadLetterNotFound) {
+		t.Errorf("second Delete: got %v, want ErrDeadLetterNotFound", err)
+	}
+}
+
+func TestMemoryDeadLettersLimit(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	s := NewMemoryDeadLetters(2)
+	s.Save(ctx, newDeadLetter("a", "invoice.paid", "mailer", now))
+	s.Save(ctx, newDeadLetter("b", "invoice.paid", "mailer", now.Add(time.Second)))
+	s.Save(ctx, newDeadLetter("c", "invoice.paid", "mailer", now.Add(2*time.Second)))
+
+	letters, _ := s.List(ctx, DeadLetterFilter{}, -1, 0)
+	if got := letterIDs(letters); !equalIDs(got, []string{"b", "c"}) {
+		t.Errorf("List over the limit = %v, want the oldest dropped", got)
+	}
+}
+
+func TestReplayAllLimit(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	previous := deadLetters
+	s := NewMemoryDeadLetters(10)
+	SetDeadLetters(s)
+	t.Cleanup(func() { SetDeadLetters(previous) })
+
+	// no listener is registered under the name, so every replay fails and the letters stay
+	s.Save(ctx, newDeadLetter("a", "invoice.paid", "unregistered", now))
+	s.Save(ctx, newDeadLetter("b", "invoice.paid", "unregistered", now.Add(time.Second)))
+	s.Save(ctx, newDeadLetter("c", "invoice.paid", "unregistered", now.Add(2*time.Second)))
+
+	replayed, failed, err := ReplayAll(ctx, DeadLetterFilter{}, 2)
+	if err != nil {
+		t.Fatalf("ReplayAll: %v", err)
+	}
+	if replayed != 0 || failed != 2 {
+		t.Errorf("ReplayAll with a limit of 2 = %v replayed, %v failed, want 2 failed", replayed, failed)
+	}
+
+	if err := Replay(ctx, "a"); !errors.Is(err, ErrListenerNotFound) {
+		t.Errorf("Replay of an unregistered listener: got %v, want ErrListenerNotFound", err)
+	}
+}
@@ -0,0 +1,276 @@
+package event
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/QubelyLabs/bedrock/pkg/logger"
+	"github.com/QubelyLabs/bedrock/pkg/metrics"
+)
+
+const (
+	WORKERS = 16
+)
+
+// delivery is an event on its way to a listener
+type delivery struct {
+	listener Listener
+	event    Event
+	attempts []Attempt
+	done     func()
+}
+
+func (d *delivery) keyed() bool {
+	return d.event.Key != ""
+}
+
+// lane tracks the running deliveries of a listener with a concurrency limit,
+// deliveries over the limit wait in the backlog in arrival order
+type lane struct {
+	running int
+	backlog []*ticket
+}
+
+// ticket is a delivery waiting for a slot, keyed deliveries wait on wake in their worker to keep their order
+type ticket struct {
+	delivery *delivery
+	wake     chan struct{}
+}
+
+// dispatcher runs listeners on a pool of workers.
+// Deliveries without a partition key go to any worker and are retried after their backoff without holding one.
+// Deliveries with a key always go to the same worker per listener and key, which handles them one at a time,
+// retries included, so a listener sees the events of a key in order.
+type dispatcher struct {
+	shared chan *delivery
+	shards []chan *delivery
+	quit   chan struct{}
+	stop   sync.Once
+
+	mutex sync.Mutex
+	lanes map[string]*lane
+
+	inflight sync.WaitGroup
+}
+
+// dispatch hands the event to its listeners, done is called once every listener handled it or gave up.
+// It only blocks while the queues are full.
+func (d *dispatcher) dispatch(e Event, done func()) {
+	matched := matching(e.Name)
+	if len(matched) == 0 {
+		done()
+		return
+	}
+
+	remaining := int64(len(matched))
+	finish := func() {
+		if atomic.AddInt64(&remaining, -1) == 0 {
+			done()
+		}
+	}
+
+	for _, listener := range matched {
+		d.inflight.Add(1)
+		metrics.EventDeliveriesInFlight.Inc()
+		d.enqueue(&delivery{listener: listener, event: e, done: finish})
+	}
+}
+
+// enqueue queues the delivery, after shutdown it becomes a dead letter
+func (d *dispatcher) enqueue(dl *delivery) {
+	queue := d.shared
+	if dl.keyed() {
+		h := fnv.New32a()
+		h.Write([]byte(dl.listener.ID + "\x00" + dl.event.Key))
+		queue = d.shards[h.Sum32()%uint32(len(d.shards))]
+	}
+
+	// checked first, a retry firing after shutdown could land in a queue with room which no worker reads anymore
+	select {
+	case <-d.quit:
+		d.drop(dl)
+		return
+	default:
+	}
+
+	select {
+	case queue <- dl:
+	case <-d.quit:
+		d.drop(dl)
+	}
+}
+
+// drop turns a delivery queued after shutdown into a dead letter
+func (d *dispatcher) drop(dl *delivery) {
+	logger.For("event").Error("dispatcher stopped, event dropped", "event", dl.event.Name, "id", dl.event.ID, "listener", dl.listener.ID)
+	bury(dl.event, dl.listener.ID, append(dl.attempts, Attempt{At: time.Now(), Error: "dispatcher stopped"}))
+	d.finish(dl)
+}
+
+func (d *dispatcher) work(shard chan *delivery) {
+	for {
+		select {
+		case dl := <-shard:
+			d.run(dl)
+		case dl := <-d.shared:
+			d.run(dl)
+		case <-d.quit:
+			return
+		}
+	}
+}
+
+// run delivers the event, then the deliveries the listener backlogged meanwhile
+func (d *dispatcher) run(dl *delivery) {
+	if !d.acquire(dl) {
+		return
+	}
+
+	for dl != nil {
+		d.deliver(dl)
+		dl = d.release(dl.listener)
+	}
+}
+
+// acquire takes a slot of the listener. Without one a keyed delivery waits for it,
+// others are backlogged and run by the worker releasing a slot, so no worker waits on a busy listener.
+func (d *dispatcher) acquire(dl *delivery) bool {
+	if dl.listener.Concurrency <= 0 {
+		return true
+	}
+
+	d.mutex.Lock()
+	l := d.lanes[dl.listener.ID]
+	if l == nil {
+		l = &lane{}
+		d.lanes[dl.listener.ID] = l
+	}
+
+	if l.running < dl.listener.Concurrency && len(l.backlog) == 0 {
+		l.running++
+		d.mutex.Unlock()
+		return true
+	}
+
+	if !dl.keyed() {
+		l.backlog = append(l.backlog, &ticket{delivery: dl})
+		d.mutex.Unlock()
+		return false
+	}
+
+	t := &ticket{delivery: dl, wake: make(chan struct{})}
+	l.backlog = append(l.backlog, t)
+	d.mutex.Unlock()
+
+	// the slot is handed over by release
+	<-t.wake
+	return true
+}
+
+// release frees the slot of the listener, or hands it to the first backlogged delivery.
+// It returns that delivery when the caller should run it.
+func (d *dispatcher) release(listener Listener) *delivery {
+	if listener.Concurrency <= 0 {
+		return nil
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	l := d.lanes[listener.ID]
+	if len(l.backlog) == 0 {
+		l.running--
+		return nil
+	}
+
+	t := l.backlog[0]
+	l.backlog = l.backlog[1:]
+	if t.wake != nil {
+		close(t.wake)
+		return nil
+	}
+
+	return t.delivery
+}
+
+// deliver makes an attempt, keyed deliveries are retried in place and the others re-queued after the backoff
+func (d *dispatcher) deliver(dl *delivery) {
+	for {
+		err := handle(dl.listener, dl.event)
+		if err == nil {
+			metrics.EventsHandled.WithLabelValues(dl.event.Name, dl.listener.ID, "ok").Inc()
+			d.finish(dl)
+			return
+		}
+
+		dl.attempts = append(dl.attempts, Attempt{At: time.Now(), Error: err.Error()})
+		metrics.EventListenerFailures.WithLabelValues(dl.event.Name, dl.listener.ID).Inc()
+		logger.For("event").Warn("error processing event", "event", dl.event.Name, "id", dl.event.ID, "listener", dl.listener.ID, "attempt", len(dl.attempts), "error", err)
+
+		if len(dl.attempts) >= dl.listener.Retry.attempts() {
+			metrics.EventsHandled.WithLabelValues(dl.event.Name, dl.listener.ID, "failed").Inc()
+			logger.For("event").Error("failed to process event", "event", dl.event.Name, "id", dl.event.ID, "listener", dl.listener.ID, "attempts", len(dl.attempts), "error", err)
+			bury(dl.event, dl.listener.ID, dl.attempts)
+			d.finish(dl)
+			return
+		}
+
+		delay := dl.listener.Retry.delay(len(dl.attempts))
+		if !dl.keyed() {
+			time.AfterFunc(delay, func() { d.enqueue(dl) })
+			return
+		}
+		time.Sleep(delay)
+	}
+}
+
+func (d *dispatcher) finish(dl *delivery) {
+	metrics.EventDeliveriesInFlight.Dec()
+	dl.done()
+	d.inflight.Done()
+}
+
+// drain waits for the queued, running and retrying deliveries, then stops the workers.
+// Deliveries still retrying when ctx is done become dead letters.
+func (d *dispatcher) drain(ctx context.Context) error {
+	drained := make(chan struct{})
+	go func() {
+		d.inflight.Wait()
+		close(drained)
+	}()
+
+	var err error
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		err = fmt.Errorf("event deliveries not drained: %w", ctx.Err())
+	}
+
+	d.stop.Do(func() { close(d.quit) })
+	return err
+}
+
+// newDispatcher starts a dispatcher with the number of workers, WORKERS when zero
+func newDispatcher(workers int) *dispatcher {
+	if workers <= 0 {
+		workers = WORKERS
+	}
+
+	d := &dispatcher{
+		shared: make(chan *delivery, BUFFER_LIMIT),
+		shards: make([]chan *delivery, workers),
+		quit:   make(chan struct{}),
+		lanes:  map[string]*lane{},
+	}
+
+	for i := range d.shards {
+		d.shards[i] = make(chan *delivery, BUFFER_LIMIT/workers+1)
+		go d.work(d.shards[i])
+	}
+
+	return d
+}
@@ -0,0 +1,66 @@
+package event
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/QubelyLabs/bedrock/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestEnqueueAfterShutdown(t *testing.T) {
+	previous := deadLetters
+	store := NewMemoryDeadLetters(10)
+	SetDeadLetters(store)
+	t.Cleanup(func() { SetDeadLetters(previous) })
+
+	d := newDispatcher(1)
+	if err := d.drain(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	done := false
+	d.inflight.Add(1)
+	metrics.EventDeliveriesInFlight.Inc()
+	d.enqueue(&delivery{listener: Listener{ID: "mailer"}, event: Event{ID: "1", Name: "invoice.paid"}, done: func() { done = true }})
+
+	if !done {
+		t.Error("a delivery queued after shutdown was not finished")
+	}
+
+	letters, _ := store.List(context.Background(), DeadLetterFilter{Listener: "mailer"}, -1, 0)
+	if len(letters) != 1 || letters[0].Error != "dispatcher stopped" {
+		t.Errorf("dead letters = %+v, want the delivery queued after shutdown", letters)
+	}
+}
+
+func TestHandleAbandonsTimedOutListeners(t *testing.T) {
+	returned := make(chan struct{})
+	l := Listener{ID: "slow-listener", Timeout: 10 * time.Millisecond, Handler: func(...any) error {
+		defer close(returned)
+		time.Sleep(50 * time.Millisecond)
+		return nil
+	}}
+	abandoned := metrics.EventListenersAbandoned.WithLabelValues(l.ID)
+
+	err := handle(l, Event{ID: "1", Name: "invoice.paid"})
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("handle of a slow listener: got %v, want a timeout", err)
+	}
+
+	if got := testutil.ToFloat64(abandoned); got != 1 {
+		t.Errorf("abandoned listeners while running = %v, want 1", got)
+	}
+
+	<-returned
+	deadline := time.Now().Add(time.Second)
+	for testutil.ToFloat64(abandoned) != 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	if got := testutil.ToFloat64(abandoned); got != 0 {
+		t.Errorf("abandoned listeners once returned = %v, want 0", got)
+	}
+}
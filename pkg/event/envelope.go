@@ -0,0 +1,100 @@
+package event
+
+import (
+	"context"
+	"time"
+
+	"github.com/QubelyLabs/bedrock/pkg/injection"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	META_USER_ID      = "user_id"
+	META_WORKSPACE_ID = "workspace_id"
+)
+
+type envelopeContextKey struct{}
+
+var (
+	source string
+)
+
+// FromContext returns the event being handled, listeners get it in the context passed to them
+func FromContext(ctx context.Context) (Event, bool) {
+	e, ok := ctx.Value(envelopeContextKey{}).(Event)
+	return e, ok
+}
+
+// withEvent returns a context carrying the event being handled
+func withEvent(ctx context.Context, e Event) context.Context {
+	return context.WithValue(ctx, envelopeContextKey{}, e)
+}
+
+// Envelop fills the missing envelope fields of the event.
+// Inside a listener the correlation id and metadata of the event being handled are carried over,
+// under a *gin.Context, or a context derived from one, the request id becomes the correlation id and the user and workspace ids are added.
+func Envelop(ctx context.Context, e Event) Event {
+	if e.ID == "" {
+		e.ID = uuid.New().String()
+	}
+
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+
+	if e.Source == "" {
+		e.Source = source
+	}
+
+	metadata := map[string]string{}
+	for k, v := range e.Metadata {
+		metadata[k] = v
+	}
+
+	if parent, ok := FromContext(ctx); ok {
+		if e.CorrelationID == "" {
+			e.CorrelationID = parent.CorrelationID
+		}
+
+		if e.CausationID == "" {
+			e.CausationID = parent.ID
+		}
+
+		for k, v := range parent.Metadata {
+			if _, ok := metadata[k]; !ok {
+				metadata[k] = v
+			}
+		}
+	}
+
+	// looked up as a value, so the request is still found under the spans and units of work wrapping its context
+	if c, ok := ctx.Value(gin.ContextKey).(*gin.Context); ok {
+		if e.CorrelationID == "" {
+			e.CorrelationID = injection.GetRequestID(c)
+		}
+
+		if user, ok := injection.LookupUser(c); ok {
+			if id, ok := user["id"].(string); ok && metadata[META_USER_ID] == "" {
+				metadata[META_USER_ID] = id
+			}
+		}
+
+		if workspace, ok := injection.LookupWorkspace(c); ok {
+			if id, ok := workspace["id"].(string); ok && metadata[META_WORKSPACE_ID] == "" {
+				metadata[META_WORKSPACE_ID] = id
+			}
+		}
+	}
+
+	// the first event of a chain starts its correlation
+	if e.CorrelationID == "" {
+		e.CorrelationID = e.ID
+	}
+
+	if len(metadata) > 0 {
+		e.Metadata = metadata
+	}
+
+	return e
+}
@@ -0,0 +1,48 @@
+package event
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/QubelyLabs/bedrock/pkg/injection"
+	"github.com/QubelyLabs/bedrock/pkg/tracing"
+	"github.com/QubelyLabs/bedrock/pkg/util"
+	"github.com/gin-gonic/gin"
+)
+
+type testContextKey struct{}
+
+func TestEnvelopFindsTheRequest(t *testing.T) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("POST", "/", nil)
+	injection.SetRequestID(c, "request-1")
+	injection.SetUser(c, util.Object{"id": "user-1"})
+	injection.SetWorkspace(c, util.Object{"id": "workspace-1"})
+
+	spanned, span := tracing.Tracer().Start(c, "test")
+	defer span.End()
+
+	contexts := map[string]context.Context{
+		"gin context": c,
+		"span":        spanned,
+		"value":       context.WithValue(c, testContextKey{}, "value"),
+	}
+
+	for name, ctx := range contexts {
+		t.Run(name, func(t *testing.T) {
+			e := Envelop(ctx, Event{Name: "invoice.paid"})
+
+			if e.CorrelationID != "request-1" {
+				t.Errorf("CorrelationID = %q, want the request id", e.CorrelationID)
+			}
+			if e.Metadata[META_USER_ID] != "user-1" || e.Metadata[META_WORKSPACE_ID] != "workspace-1" {
+				t.Errorf("Metadata = %v, want the user and workspace ids", e.Metadata)
+			}
+		})
+	}
+
+	if e := Envelop(context.Background(), Event{Name: "invoice.paid"}); e.CorrelationID != e.ID || len(e.Metadata) != 0 {
+		t.Errorf("Envelop without a request = %+v, want a new correlation and no metadata", e)
+	}
+}
@@ -1,8 +1,12 @@
 package event
 
 import (
-	"fmt"
-	"time"
+	"context"
+
+	"github.com/QubelyLabs/bedrock/pkg/logger"
+	"github.com/QubelyLabs/bedrock/pkg/tracing"
+	"github.com/QubelyLabs/bedrock/pkg/uow"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
@@ -10,30 +14,45 @@ const (
 	EVENT_TIMEOUT        = 10
 )
 
-// Emit adds an event to the event channel
-// Ensures the event is eventually delivered, keeps retrying
+// Emit publishes an event on the transport
+// The memory transport waits while its buffer is full
 func Emit(event Event) error {
-	for {
-		select {
-		case events <- event:
-			return nil
-		case <-time.After(time.Second * EVENT_TIMEOUT):
-			return fmt.Errorf("event queue full after timeout, event dropped: %v", event)
-		default:
-			// Channel full, wait and retry
-			time.Sleep(time.Second * EVENT_BACK_OFF_DELAY)
+	return publish(context.Background(), Envelop(context.Background(), event))
+}
+
+// EmitWithContext publishes an event carrying the trace context of ctx,
+// so listener spans are linked to the span that emitted the event
+func EmitWithContext(ctx context.Context, event Event) error {
+	event = Envelop(ctx, event)
+
+	ctx, span := tracing.Tracer().Start(ctx, "event emit "+event.Name, trace.WithSpanKind(trace.SpanKindProducer))
+	defer span.End()
+
+	event.Trace = map[string]string{}
+	tracing.Inject(ctx, event.Trace)
+
+	return publish(ctx, event)
+}
+
+// EmitAfterCommit emits the event once the unit of work of ctx commits, so listeners only see durable data.
+// The event is dropped when the transaction rolls back, without a transaction it is emitted right away.
+func EmitAfterCommit(ctx context.Context, event Event) {
+	uow.AfterCommit(ctx, func() {
+		if err := EmitWithContext(ctx, event); err != nil {
+			logger.For("event").Error("cannot emit event after commit", "event", event.Name, "error", err)
 		}
-	}
+	})
 }
 
 // LazyEmitLazy adds an event to the event channel
-// It throws an error when the channel is full, does not retry
+// It throws an error when the channel is full, does not retry.
+// Transports without a buffer publish as usual.
 func LazyEmit(event Event) error {
-	select {
-	case events <- event:
-		return nil
-	default:
-		// Channel full, handle buffer overflow (optional)
-		return fmt.Errorf("event queue full, event dropped: %v", event)
+	if t, ok := transport.(interface{ TryPublish(Event) error }); ok {
+		return publish(context.Background(), Envelop(context.Background(), event), func(ctx context.Context, e Event) error {
+			return t.TryPublish(e)
+		})
 	}
+
+	return publish(context.Background(), Envelop(context.Background(), event))
 }
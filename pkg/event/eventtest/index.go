@@ -0,0 +1,162 @@
+// Package eventtest records the events published during a test, instead of or on top of delivering them.
+package eventtest
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/QubelyLabs/bedrock/pkg/event"
+)
+
+// Recorder is a transport keeping the published events, with assertion helpers
+type Recorder struct {
+	mutex   sync.Mutex
+	events  []event.Event
+	deliver event.Transport
+	quit    chan struct{}
+	stop    sync.Once
+}
+
+// Record installs a recorder as the transport until the end of the test, listeners are not called
+func Record(t testing.TB) *Recorder {
+	return install(t, &Recorder{quit: make(chan struct{})})
+}
+
+// RecordAndDeliver installs a recorder which also delivers the events inline, see event.NewSyncTransport
+func RecordAndDeliver(t testing.TB) *Recorder {
+	return install(t, &Recorder{deliver: event.NewSyncTransport(), quit: make(chan struct{})})
+}
+
+func install(t testing.TB, r *Recorder) *Recorder {
+	previous := event.CurrentTransport()
+	event.SetTransport(r)
+	t.Cleanup(func() {
+		event.SetTransport(previous)
+	})
+
+	return r
+}
+
+func (r *Recorder) Publish(ctx context.Context, e event.Event) error {
+	r.mutex.Lock()
+	r.events = append(r.events, e)
+	r.mutex.Unlock()
+
+	if r.deliver != nil {
+		return r.deliver.Publish(ctx, e)
+	}
+
+	return nil
+}
+
+func (r *Recorder) Start(handle func(e event.Event, done func())) error {
+	<-r.quit
+	return nil
+}
+
+func (r *Recorder) Stop(ctx context.Context) error {
+	r.stop.Do(func() { close(r.quit) })
+	return nil
+}
+
+// Events returns the recorded events in publishing order
+func (r *Recorder) Events() []event.Event {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return append([]event.Event{}, r.events...)
+}
+
+// Named returns the recorded events matching the name or pattern
+func (r *Recorder) Named(name string) []event.Event {
+	named := []event.Event{}
+	for _, e := range r.Events() {
+		if event.Match(name, e.Name) {
+			named = append(named, e)
+		}
+	}
+
+	return named
+}
+
+// Reset forgets the recorded events
+func (r *Recorder) Reset() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.events = nil
+}
+
+// AssertEmitted fails the test unless the event was published, it returns the last one
+func (r *Recorder) AssertEmitted(t testing.TB, name string) event.Event {
+	t.Helper()
+
+	named := r.Named(name)
+	if len(named) == 0 {
+		t.Errorf("expected event %v to be published, got %v", name, r.names())
+		return event.Event{}
+	}
+
+	return named[len(named)-1]
+}
+
+// AssertNotEmitted fails the test if the event was published
+func (r *Recorder) AssertNotEmitted(t testing.TB, name string) {
+	t.Helper()
+
+	if named := r.Named(name); len(named) > 0 {
+		t.Errorf("expected event %v not to be published, got it %v times", name, len(named))
+	}
+}
+
+// AssertCount fails the test unless the event was published count times
+func (r *Recorder) AssertCount(t testing.TB, name string, count int) {
+	t.Helper()
+
+	if named := r.Named(name); len(named) != count {
+		t.Errorf("expected event %v to be published %v times, got it %v times", name, count, len(named))
+	}
+}
+
+// AssertPublished fails the test unless an event of the name was published with the typed payload
+func AssertPublished[T any](t testing.TB, r *Recorder, name string, want T) {
+	t.Helper()
+
+	named := r.Named(name)
+	for _, e := range named {
+		if got, err := event.Decode[T](e); err == nil && reflect.DeepEqual(got, want) {
+			return
+		}
+	}
+
+	t.Errorf("expected event %v to be published with %+v, got %v events of that name", name, want, len(named))
+}
+
+// Payload decodes the typed payload of the last event of the name, failing the test when there is none
+func Payload[T any](t testing.TB, r *Recorder, name string) T {
+	t.Helper()
+
+	var payload T
+	e := r.AssertEmitted(t, name)
+	if e.Name == "" {
+		return payload
+	}
+
+	payload, err := event.Decode[T](e)
+	if err != nil {
+		t.Errorf("cannot decode event %v: %v", name, err)
+	}
+
+	return payload
+}
+
+func (r *Recorder) names() []string {
+	names := []string{}
+	for _, e := range r.Events() {
+		names = append(names, e.Name)
+	}
+
+	return names
+}
@@ -0,0 +1,129 @@
+package eventtest
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/QubelyLabs/bedrock/pkg/event"
+)
+
+type order struct {
+	ID    string `json:"id"`
+	Total int    `json:"total"`
+}
+
+// failures records the failed assertions instead of failing the test
+type failures struct {
+	testing.TB
+	errors []string
+}
+
+func (f *failures) Errorf(format string, args ...any) {
+	f.errors = append(f.errors, format)
+}
+
+// subscribe registers a listener for the test and returns how often it was called
+func subscribe(t *testing.T, name string, fn func(o order) error) *int {
+	t.Helper()
+
+	calls := 0
+	err := event.Subscribe(name, func(ctx context.Context, o order) error {
+		calls++
+		return fn(o)
+	}, &event.Options{Retry: event.NoRetryPolicy})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { event.UnregisterListener(name) })
+
+	return &calls
+}
+
+func TestRecord(t *testing.T) {
+	ctx := context.Background()
+	calls := subscribe(t, "test.record.placed", func(o order) error { return nil })
+
+	r := Record(t)
+	if err := event.Publish(ctx, "test.record.placed", order{ID: "1", Total: 10}); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	event.Publish(ctx, "test.record.placed", order{ID: "2", Total: 20})
+
+	if *calls != 0 {
+		t.Errorf("Record called the listener %v times, want none", *calls)
+	}
+
+	r.AssertCount(t, "test.record.placed", 2)
+	r.AssertNotEmitted(t, "test.record.cancelled")
+	if got := r.AssertEmitted(t, "test.record.*"); got.Name != "test.record.placed" {
+		t.Errorf("AssertEmitted of a pattern = %v, want the placed event", got.Name)
+	}
+
+	r.Reset()
+	if events := r.Events(); len(events) != 0 {
+		t.Errorf("Events after Reset = %v, want none", len(events))
+	}
+}
+
+func TestRecordAndDeliver(t *testing.T) {
+	ctx := context.Background()
+	calls := subscribe(t, "test.deliver.placed", func(o order) error {
+		if o.Total < 0 {
+			return errors.New("negative total")
+		}
+
+		return nil
+	})
+
+	r := RecordAndDeliver(t)
+	if err := event.Publish(ctx, "test.deliver.placed", order{ID: "1", Total: 10}); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	if *calls != 1 {
+		t.Errorf("RecordAndDeliver called the listener %v times before Publish returned, want 1", *calls)
+	}
+
+	err := event.Emit(event.Event{Name: "test.deliver.placed", Data: []byte(`{"id":"2","total":-1}`)})
+	if err == nil || !strings.Contains(err.Error(), "negative total") {
+		t.Errorf("Emit = %v, want the error of the listener", err)
+	}
+
+	r.AssertCount(t, "test.deliver.placed", 2)
+}
+
+func TestPayload(t *testing.T) {
+	ctx := context.Background()
+
+	r := Record(t)
+	event.Publish(ctx, "test.payload.placed", order{ID: "1", Total: 10})
+	event.Publish(ctx, "test.payload.placed", order{ID: "2", Total: 20})
+
+	AssertPublished(t, r, "test.payload.placed", order{ID: "1", Total: 10})
+
+	if got := Payload[order](t, r, "test.payload.placed"); got != (order{ID: "2", Total: 20}) {
+		t.Errorf("Payload = %+v, want the last order", got)
+	}
+
+	tests := []struct {
+		name   string
+		assert func(tb testing.TB)
+	}{
+		{"other payload", func(tb testing.TB) { AssertPublished(tb, r, "test.payload.placed", order{ID: "3"}) }},
+		{"missing event", func(tb testing.TB) { Payload[order](tb, r, "test.payload.cancelled") }},
+		{"undecodable payload", func(tb testing.TB) { Payload[[]string](tb, r, "test.payload.placed") }},
+		{"not emitted", func(tb testing.TB) { r.AssertEmitted(tb, "test.payload.cancelled") }},
+		{"emitted", func(tb testing.TB) { r.AssertNotEmitted(tb, "test.payload.placed") }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &failures{TB: t}
+			tt.assert(f)
+			if len(f.errors) == 0 {
+				t.Error("the assertion did not fail")
+			}
+		})
+	}
+}
@@ -1,63 +1,289 @@
 package event
 
 import (
-	"log"
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
 	"sync"
 	"time"
+
+	"github.com/QubelyLabs/bedrock/pkg/config"
+	"github.com/QubelyLabs/bedrock/pkg/db"
+	"github.com/QubelyLabs/bedrock/pkg/metrics"
+	"github.com/QubelyLabs/bedrock/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
 	BUFFER_LIMIT = 1000
-	RETRY_COUNT  = 3               // Number of retries for failed listener calls
-	RETRY_DELAY  = 5 * time.Second // Delay between retries
+	RETRY_COUNT  = 3               // Attempts of the default retry policy
+	RETRY_DELAY  = 5 * time.Second // Delay between the attempts of the default retry policy
 )
 
 var (
-	events    = make(chan Event, BUFFER_LIMIT)
-	listeners = []Listener{}
-	mutex     = &sync.Mutex{}
+	transport Transport = NewMemoryTransport(BUFFER_LIMIT)
+	listeners           = []Listener{}
+	mutex               = &sync.Mutex{}
+	current   *dispatcher
+	workers   = WORKERS
 )
 
-// Start start a for ever loop that listen to Event via the events channel
-// It uses the Event.Name property to determine the handler to call
+// SetTransport replaces the transport, call it before emitting events and starting the listener
+func SetTransport(t Transport) {
+	transport = t
+}
+
+// CurrentTransport returns the transport, e.g. to restore it after a test
+func CurrentTransport() Transport {
+	return transport
+}
+
+// Configure sets the transport of EVENT_TRANSPORT, memory by default, sync or a redis stream on db.KV(),
+// the dead letter store of EVENT_DEAD_LETTER, the schedule of EVENT_SCHEDULE and the EVENT_WORKERS of the dispatcher
+func Configure(cf *config.Config) error {
+	source = cf.ServiceName
+	if cf.EventWorkers > 0 {
+		workers = cf.EventWorkers
+	}
+
+	if err := configureDeadLetters(cf); err != nil {
+		return err
+	}
+
+	if err := configureSchedule(cf); err != nil {
+		return err
+	}
+
+	switch strings.ToLower(cf.EventTransport) {
+	case "", "memory":
+		return nil
+	case "sync":
+		SetTransport(NewSyncTransport())
+		return nil
+	case "redis":
+		if db.KV() == nil {
+			return fmt.Errorf("event: the redis transport needs redis to be initialized")
+		}
+
+		group := cf.EventGroup
+		if group == "" {
+			group = cf.ServiceName
+		}
+
+		SetTransport(NewRedisTransport(db.KV(), cf.EventStream, group, cf.EventStreamMaxLen, time.Duration(cf.EventClaimIdle)*time.Second))
+		return nil
+	default:
+		return fmt.Errorf("event: unsupported transport %v", cf.EventTransport)
+	}
+}
+
+// configureDeadLetters sets the dead letter store of EVENT_DEAD_LETTER, memory by default
+func configureDeadLetters(cf *config.Config) error {
+	switch strings.ToLower(cf.EventDeadLetter) {
+	case "", "memory":
+		return nil
+	case "none":
+		SetDeadLetters(nil)
+		return nil
+	case "sql":
+		SetDeadLetters(NewSQLDeadLetters(nil))
+		return nil
+	case "redis":
+		if db.KV() == nil {
+			return fmt.Errorf("event: the redis dead letter store needs redis to be initialized")
+		}
+
+		SetDeadLetters(NewRedisDeadLetters(db.KV(), ""))
+		return nil
+	default:
+		return fmt.Errorf("event: unsupported dead letter store %v", cf.EventDeadLetter)
+	}
+}
+
+// configureSchedule sets the schedule of EVENT_SCHEDULE, memory by default
+func configureSchedule(cf *config.Config) error {
+	if cf.EventSchedulePollInterval > 0 {
+		pollInterval = time.Duration(cf.EventSchedulePollInterval) * time.Second
+	}
+
+	switch strings.ToLower(cf.EventSchedule) {
+	case "", "memory":
+		return nil
+	case "redis":
+		if db.KV() == nil {
+			return fmt.Errorf("event: the redis schedule needs redis to be initialized")
+		}
+
+		SetSchedule(NewRedisSchedule(db.KV(), ""))
+		return nil
+	default:
+		return fmt.Errorf("event: unsupported schedule %v", cf.EventSchedule)
+	}
+}
+
+// Start start a for ever loop that receives events from the transport
+// It hands them to the listeners whose name pattern matches Event.Name, see Listener
+// It returns once StopListener is called and the transport stopped receiving
+// It also polls the schedule for the due events of EmitAt
 func StartListener() error {
-	for {
-		// Receive an event from the channel
-		event, ok := <-events
-		if !ok {
-			return nil
+	d := newDispatcher(workers)
+
+	mutex.Lock()
+	current = d
+	poller = startPoller(pollInterval)
+	mutex.Unlock()
+
+	return transport.Start(d.dispatch)
+}
+
+// StopListener stops polling the schedule and receiving events, then waits for the deliveries in flight, retries included
+// It returns when they are done or the context is done, whichever comes first
+func StopListener(ctx context.Context) error {
+	mutex.Lock()
+	d, p := current, poller
+	mutex.Unlock()
+
+	var err error
+	if p != nil {
+		err = p.Stop(ctx)
+	}
+
+	err = errors.Join(err, transport.Stop(ctx))
+	if d != nil {
+		err = errors.Join(err, d.drain(ctx))
+	}
+
+	return err
+}
+
+// handle calls the listener once in a span linked to the span that emitted the event,
+// giving up after its timeout and turning panics into errors.
+// A call outliving its timeout is abandoned, it frees the slot of the listener while it keeps running
+// and is counted by the listeners_abandoned metric until it returns.
+func handle(l Listener, e Event) error {
+	emitter := trace.SpanContextFromContext(tracing.Extract(context.Background(), e.Trace))
+	ctx, span := tracing.Tracer().Start(context.Background(), "event handle "+e.Name,
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithLinks(trace.Link{SpanContext: emitter}),
+		trace.WithAttributes(attribute.String("event.listener", l.ID), attribute.String("event.id", e.ID)),
+	)
+	defer span.End()
+
+	var err error
+	if l.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, l.Timeout)
+		defer cancel()
+
+		result := make(chan error, 1)
+		go func() { result <- attempt(ctx, l, e) }()
+
+		select {
+		case err = <-result:
+		case <-ctx.Done():
+			err = fmt.Errorf("event: listener %v timed out after %v", l.ID, l.Timeout)
+
+			// the listener keeps running in the background when it ignores the context
+			abandoned := metrics.EventListenersAbandoned.WithLabelValues(l.ID)
+			abandoned.Inc()
+			go func() {
+				<-result
+				abandoned.Dec()
+			}()
 		}
+	} else {
+		err = attempt(ctx, l, e)
+	}
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	return err
+}
 
-		var wg sync.WaitGroup
-		wg.Add(len(listeners))
-		for _, listener := range listeners {
-			if listener.Name == event.Name {
-				go func(l Listener, e Event) {
-					defer wg.Done()
-					var err error
-					for i := 0; i < RETRY_COUNT; i++ {
-						err = l.Handler(e.Payload...)
-						if err == nil {
-							break
-						}
-						log.Printf("Error processing event %s (listener %s, attempt %d): %v", event.Name, l.Name, i+1, err)
-						time.Sleep(RETRY_DELAY)
-					}
-					if err != nil {
-						log.Printf("Failed to process event %s for listener %s after %d retries: %v", event.Name, l.Name, RETRY_COUNT, err)
-					}
-				}(listener, event)
-			}
+// attempt runs the listener within the handle middlewares, a panic in either is returned as an error
+func attempt(ctx context.Context, l Listener, e Event) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("event: listener %v panicked: %v", l.ID, r)
 		}
-		wg.Wait()
+	}()
+
+	return withHandleMiddlewares(call)(ctx, l, e)
+}
+
+func call(ctx context.Context, l Listener, e Event) error {
+	if l.handler != nil {
+		return l.handler(withEvent(ctx, e), e)
 	}
+
+	return l.Handler(e.Payload...)
+}
+
+// matching returns the listeners of the event name
+func matching(name string) []Listener {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	matched := []Listener{}
+	for _, listener := range listeners {
+		if Match(listener.Name, name) {
+			matched = append(matched, listener)
+		}
+	}
+
+	return matched
+}
+
+// findListener returns the listener with the id
+func findListener(id string) (Listener, bool) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	return findListenerLocked(id)
+}
+
+func findListenerLocked(id string) (Listener, bool) {
+	for _, listener := range listeners {
+		if listener.ID == id {
+			return listener, true
+		}
+	}
+
+	return Listener{}, false
 }
 
 // RegisterListener registers a listener function for a specific event name
 func RegisterListener(listener Listener) error {
+	return register(listener, listener.Handler)
+}
+
+// register adds the listener, its default id is made of the event name and the name of fn
+func register(listener Listener, fn any) error {
 	mutex.Lock()
 	defer mutex.Unlock()
 
+	if listener.ID == "" {
+		listener.ID = listener.Name + ":" + runtime.FuncForPC(reflect.ValueOf(fn).Pointer()).Name()
+
+		// closures created in a loop share a function name, number them in registration order
+		id, n := listener.ID, 1
+		for _, ok := findListenerLocked(id); ok; _, ok = findListenerLocked(id) {
+			n++
+			id = fmt.Sprintf("%v#%v", listener.ID, n)
+		}
+		listener.ID = id
+	} else if _, ok := findListenerLocked(listener.ID); ok {
+		return fmt.Errorf("event: listener %v is registered twice", listener.ID)
+	}
+
 	listeners = append(listeners, listener)
 	return nil
 }
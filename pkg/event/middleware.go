@@ -0,0 +1,80 @@
+package event
+
+import (
+	"context"
+	"sync"
+)
+
+// PublishFunc hands an event to the transport
+type PublishFunc func(ctx context.Context, e Event) error
+
+// HandleFunc makes one delivery attempt of an event to a listener
+type HandleFunc func(ctx context.Context, l Listener, e Event) error
+
+// PublishMiddleware wraps publishing, e.g. to log, enrich or filter events
+type PublishMiddleware func(next PublishFunc) PublishFunc
+
+// HandleMiddleware wraps every delivery attempt, inside the handling span and the listener timeout,
+// e.g. to log or to restore the tenant of the event metadata in the context of the listener
+type HandleMiddleware func(next HandleFunc) HandleFunc
+
+var (
+	publishMiddlewares = []PublishMiddleware{}
+	handleMiddlewares  = []HandleMiddleware{}
+	middlewareMutex    = &sync.RWMutex{}
+)
+
+// UsePublish adds publish middlewares, the first added runs first
+func UsePublish(middlewares ...PublishMiddleware) {
+	middlewareMutex.Lock()
+	defer middlewareMutex.Unlock()
+
+	publishMiddlewares = append(publishMiddlewares, middlewares...)
+}
+
+// UseHandle adds handle middlewares, the first added runs first
+func UseHandle(middlewares ...HandleMiddleware) {
+	middlewareMutex.Lock()
+	defer middlewareMutex.Unlock()
+
+	handleMiddlewares = append(handleMiddlewares, middlewares...)
+}
+
+// ResetMiddlewares removes the publish and handle middlewares
+func ResetMiddlewares() {
+	middlewareMutex.Lock()
+	defer middlewareMutex.Unlock()
+
+	publishMiddlewares = []PublishMiddleware{}
+	handleMiddlewares = []HandleMiddleware{}
+}
+
+// publish runs the publish middlewares around the publishing function, the transport by default
+func publish(ctx context.Context, e Event, terminal ...PublishFunc) error {
+	next := PublishFunc(func(ctx context.Context, e Event) error {
+		return transport.Publish(ctx, e)
+	})
+	if len(terminal) > 0 {
+		next = terminal[0]
+	}
+
+	middlewareMutex.RLock()
+	for i := len(publishMiddlewares) - 1; i >= 0; i-- {
+		next = publishMiddlewares[i](next)
+	}
+	middlewareMutex.RUnlock()
+
+	return next(ctx, e)
+}
+
+// withHandleMiddlewares runs the handle middlewares around the call of the listener
+func withHandleMiddlewares(next HandleFunc) HandleFunc {
+	middlewareMutex.RLock()
+	defer middlewareMutex.RUnlock()
+
+	for i := len(handleMiddlewares) - 1; i >= 0; i-- {
+		next = handleMiddlewares[i](next)
+	}
+
+	return next
+}
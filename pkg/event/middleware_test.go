@@ -0,0 +1,72 @@
+package event
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestHandleRecoversPanics(t *testing.T) {
+	t.Cleanup(ResetMiddlewares)
+
+	failing := errors.New("failing")
+	tests := []struct {
+		name       string
+		middleware HandleMiddleware
+		handler    func(...any) error
+		want       string
+	}{
+		{"listener", nil, func(...any) error { panic("boom") }, "panicked: boom"},
+		{"middleware", func(next HandleFunc) HandleFunc {
+			return func(ctx context.Context, l Listener, e Event) error {
+				panic("middleware boom")
+			}
+		}, func(...any) error { return nil }, "panicked: middleware boom"},
+		{"error", nil, func(...any) error { return failing }, "failing"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ResetMiddlewares()
+			if tt.middleware != nil {
+				UseHandle(tt.middleware)
+			}
+
+			err := handle(Listener{ID: "mailer", Handler: tt.handler}, Event{ID: "1", Name: "invoice.paid"})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("handle = %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestPublishMiddlewareOrder(t *testing.T) {
+	t.Cleanup(ResetMiddlewares)
+	ResetMiddlewares()
+
+	calls := []string{}
+	named := func(name string) PublishMiddleware {
+		return func(next PublishFunc) PublishFunc {
+			return func(ctx context.Context, e Event) error {
+				calls = append(calls, name)
+				return next(ctx, e)
+			}
+		}
+	}
+
+	UsePublish(named("first"), named("second"))
+	UsePublish(named("third"))
+
+	err := publish(context.Background(), Event{Name: "invoice.paid"}, func(ctx context.Context, e Event) error {
+		calls = append(calls, "transport")
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+
+	if want := []string{"first", "second", "third", "transport"}; !equalIDs(calls, want) {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+}
@@ -0,0 +1,111 @@
+package event
+
+import (
+	"math"
+	"strings"
+	"time"
+)
+
+// RetryPolicy decides how often and when a failed delivery is retried
+type RetryPolicy struct {
+	Attempts   int           // Deliveries before the event becomes a dead letter, RETRY_COUNT when zero
+	Delay      time.Duration // Before the first retry, RETRY_DELAY when zero
+	MaxDelay   time.Duration // Caps the delay, unlimited when zero
+	Multiplier float64       // Applied to the delay after each retry, below 1 keeps it constant
+}
+
+var (
+	DefaultRetryPolicy = &RetryPolicy{Attempts: RETRY_COUNT, Delay: RETRY_DELAY}
+	NoRetryPolicy      = &RetryPolicy{Attempts: 1}
+)
+
+// ExponentialRetryPolicy doubles the delay after each retry, up to maxDelay
+func ExponentialRetryPolicy(attempts int, delay, maxDelay time.Duration) *RetryPolicy {
+	return &RetryPolicy{Attempts: attempts, Delay: delay, MaxDelay: maxDelay, Multiplier: 2}
+}
+
+func (p *RetryPolicy) attempts() int {
+	if p == nil || p.Attempts <= 0 {
+		return RETRY_COUNT
+	}
+
+	return p.Attempts
+}
+
+// delay returns the wait before the retry following the failed attempt, counted from 1
+func (p *RetryPolicy) delay(attempt int) time.Duration {
+	if p == nil {
+		p = DefaultRetryPolicy
+	}
+
+	delay := p.Delay
+	if delay <= 0 {
+		delay = RETRY_DELAY
+	}
+
+	if p.Multiplier > 1 {
+		delay = time.Duration(float64(delay) * math.Pow(p.Multiplier, float64(attempt-1)))
+	}
+
+	if p.MaxDelay > 0 && (delay > p.MaxDelay || delay < 0) {
+		delay = p.MaxDelay
+	}
+
+	return delay
+}
+
+// Options configures a listener registered by Subscribe, see Listener for the fields
+type Options struct {
+	ID          string
+	Concurrency int
+	Timeout     time.Duration
+	Retry       *RetryPolicy
+}
+
+// Partitioned is implemented by payloads with a partition key, see Event.Key
+type Partitioned interface {
+	EventKey() string
+}
+
+func keyOf(payload any) string {
+	if p, ok := payload.(Partitioned); ok {
+		return p.EventKey()
+	}
+
+	return ""
+}
+
+// Match reports whether the event name matches the listener pattern.
+// Names are dot separated, * matches one segment and ** any number of them, e.g. invoice.* or **.failed
+func Match(pattern, name string) bool {
+	if pattern == name {
+		return true
+	}
+
+	if !strings.Contains(pattern, "*") {
+		return false
+	}
+
+	return matchSegments(strings.Split(pattern, "."), strings.Split(name, "."))
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(name) == 0 || (pattern[0] != "*" && pattern[0] != name[0]) {
+			return false
+		}
+
+		pattern, name = pattern[1:], name[1:]
+	}
+
+	return len(name) == 0
+}
@@ -0,0 +1,41 @@
+package event
+
+import "testing"
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"invoice.paid", "invoice.paid", true},
+		{"invoice.paid", "invoice.failed", false},
+		{"invoice.*", "invoice.paid", true},
+		{"invoice.*", "invoice", false},
+		{"invoice.*", "invoice.payment.failed", false},
+		{"*.paid", "invoice.paid", true},
+		{"*.paid", "paid", false},
+		{"*", "invoice", true},
+		{"*", "invoice.paid", false},
+		{"**", "invoice", true},
+		{"**", "invoice.payment.failed", true},
+		{"**.failed", "failed", true},
+		{"**.failed", "invoice.payment.failed", true},
+		{"**.failed", "invoice.failed.retried", false},
+		{"invoice.**", "invoice", true},
+		{"invoice.**", "invoice.payment.failed", true},
+		{"invoice.**", "order.paid", false},
+		{"invoice.**.failed", "invoice.failed", true},
+		{"invoice.**.failed", "invoice.payment.card.failed", true},
+		{"invoice.**.failed", "invoice.payment.paid", false},
+		{"*.*.failed", "invoice.payment.failed", true},
+		{"*.*.failed", "invoice.failed", false},
+		{"invoice*", "invoice.paid", false},
+	}
+
+	for _, tt := range tests {
+		if got := Match(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("Match(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/QubelyLabs/bedrock/pkg/logger"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	STREAM          = "bedrock:events"
+	GROUP           = "bedrock"
+	STREAM_MAX_LEN  = 100000
+	CLAIM_IDLE      = time.Minute
+	READ_BLOCK      = 2 * time.Second
+	READ_COUNT      = 10
+	STREAM_FIELD    = "event"
+	CLAIM_FREQUENCY = 30 * time.Second
+)
+
+// redisTransport is a redis stream read by a consumer group, every event is handled once per group.
+// Events are acknowledged once handled, the entries of crashed consumers are claimed by the others after CLAIM_IDLE,
+// which must outlast the attempts and retry delays of the slowest listener.
+type redisTransport struct {
+	client    redis.UniversalClient
+	stream    string
+	group     string
+	consumer  string
+	maxLen    int64
+	claimIdle time.Duration
+
+	ctx     context.Context
+	cancel  context.CancelFunc
+	stopped chan struct{}
+	stop    sync.Once
+}
+
+// Publish adds the event to the stream, trimming it to about maxLen entries
+func (t *redisTransport) Publish(ctx context.Context, event Event) error {
+	buf, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	return t.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: t.stream,
+		MaxLen: t.maxLen,
+		Approx: true,
+		Values: map[string]any{STREAM_FIELD: string(buf)},
+	}).Err()
+}
+
+func (t *redisTransport) Start(handle func(event Event, done func())) error {
+	defer close(t.stopped)
+
+	err := t.client.XGroupCreateMkStream(t.ctx, t.stream, t.group, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return fmt.Errorf("event: cannot create consumer group %v: %w", t.group, err)
+	}
+
+	lastClaim := time.Time{}
+	for t.ctx.Err() == nil {
+		if time.Since(lastClaim) > CLAIM_FREQUENCY {
+			t.claim(handle)
+			lastClaim = time.Now()
+		}
+
+		streams, err := t.client.XReadGroup(t.ctx, &redis.XReadGroupArgs{
+			Group:    t.group,
+			Consumer: t.consumer,
+			Streams:  []string{t.stream, ">"},
+			Count:    READ_COUNT,
+			Block:    READ_BLOCK,
+		}).Result()
+		if err != nil {
+			if errors.Is(err, redis.Nil) || t.ctx.Err() != nil {
+				continue
+			}
+
+			logger.For("event").Error("cannot read event stream", "stream", t.stream, "error", err)
+
+			// back off before reading again, Stop cancels the wait
+			backoff := time.NewTimer(time.Second * EVENT_BACK_OFF_DELAY)
+			select {
+			case <-backoff.C:
+			case <-t.ctx.Done():
+				backoff.Stop()
+			}
+			continue
+		}
+
+		for _, stream := range streams {
+			for _, message := range stream.Messages {
+				t.handle(message, handle)
+			}
+		}
+	}
+
+	return nil
+}
+
+// claim takes over the entries other consumers read but did not acknowledge within claimIdle
+func (t *redisTransport) claim(handle func(event Event, done func())) {
+	start := "0-0"
+	for t.ctx.Err() == nil {
+		messages, next, err := t.client.XAutoClaim(t.ctx, &redis.XAutoClaimArgs{
+			Stream:   t.stream,
+			Group:    t.group,
+			Consumer: t.consumer,
+			MinIdle:  t.claimIdle,
+			Start:    start,
+			Count:    READ_COUNT,
+		}).Result()
+		if err != nil {
+			if t.ctx.Err() == nil {
+				logger.For("event").Error("cannot claim pending events", "stream", t.stream, "error", err)
+			}
+			return
+		}
+
+		for _, message := range messages {
+			logger.For("event").Warn("claimed event of an unresponsive consumer", "stream", t.stream, "id", message.ID)
+			t.handle(message, handle)
+		}
+
+		if next == "0-0" || len(messages) == 0 {
+			return
+		}
+		start = next
+	}
+}
+
+// handle decodes a stream entry and hands it over, it is acknowledged once its listeners are through with it.
+// Entries that cannot be decoded are acknowledged right away, so they are not redelivered forever.
+func (t *redisTransport) handle(message redis.XMessage, handle func(event Event, done func())) {
+	ack := func() {
+		// acknowledge even when stopping, the event was handled
+		if err := t.client.XAck(context.Background(), t.stream, t.group, message.ID).Err(); err != nil {
+			logger.For("event").Error("cannot acknowledge event", "stream", t.stream, "id", message.ID, "error", err)
+		}
+	}
+
+	var event Event
+	raw, _ := message.Values[STREAM_FIELD].(string)
+	if err := json.Unmarshal([]byte(raw), &event); err != nil {
+		logger.For("event").Error("cannot decode event, dropping it", "stream", t.stream, "id", message.ID, "error", err)
+		ack()
+		return
+	}
+
+	handle(event, ack)
+}
+
+// Stop ends the read loop within READ_BLOCK, the entries handed over are acknowledged once handled and unread entries stay in the stream
+func (t *redisTransport) Stop(ctx context.Context) error {
+	t.stop.Do(t.cancel)
+
+	select {
+	case <-t.stopped:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("event stream consumer not stopped: %w", ctx.Err())
+	}
+}
+
+// NewRedisTransport creates a transport on a redis stream, consumers of the same group share its events.
+// Zero values use the defaults, a negative maxLen disables trimming.
+func NewRedisTransport(client redis.UniversalClient, stream, group string, maxLen int64, claimIdle time.Duration) *redisTransport {
+	if stream == "" {
+		stream = STREAM
+	}
+
+	if group == "" {
+		group = GROUP
+	}
+
+	if maxLen == 0 {
+		maxLen = STREAM_MAX_LEN
+	} else if maxLen < 0 {
+		maxLen = 0
+	}
+
+	if claimIdle <= 0 {
+		claimIdle = CLAIM_IDLE
+	}
+
+	hostname, _ := os.Hostname()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &redisTransport{
+		client:    client,
+		stream:    stream,
+		group:     group,
+		consumer:  fmt.Sprintf("%v-%v", hostname, os.Getpid()),
+		maxLen:    maxLen,
+		claimIdle: claimIdle,
+		ctx:       ctx,
+		cancel:    cancel,
+		stopped:   make(chan struct{}),
+	}
+}
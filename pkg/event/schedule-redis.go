@@ -0,0 +1,178 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/QubelyLabs/bedrock/pkg/logger"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	SCHEDULE_KEY = "{bedrock:scheduled}"
+)
+
+// claimDue moves the due events and the expired claims to the claimed set with a deadline in one step,
+// so replicas polling at the same time never get the same event. The events stay in the hash until acknowledged.
+var claimDue = redis.NewScript(`
+local limit = tonumber(ARGV[2])
+local ids = redis.call('ZRANGEBYSCORE', KEYS[3], '-inf', ARGV[1], 'LIMIT', 0, limit)
+if #ids < limit then
+	for _, id in ipairs(redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, limit - #ids)) do
+		table.insert(ids, id)
+	end
+end
+local due = {}
+for _, id in ipairs(ids) do
+	local value = redis.call('HGET', KEYS[2], id)
+	redis.call('ZREM', KEYS[1], id)
+	if value then
+		redis.call('ZADD', KEYS[3], ARGV[3], id)
+		table.insert(due, id)
+		table.insert(due, value)
+	else
+		redis.call('ZREM', KEYS[3], id)
+	end
+end
+return due
+`)
+
+// cancelPending removes the event only while it is pending, a claimed event is being published
+var cancelPending = redis.NewScript(`
+if redis.call('ZREM', KEYS[1], ARGV[1]) == 0 then
+	return 0
+end
+redis.call('HDEL', KEYS[2], ARGV[1])
+return 1
+`)
+
+// redisSchedule keeps the scheduled events as json in a hash, indexed by due time in a sorted set
+// and by claim deadline in another one while they are published
+type redisSchedule struct {
+	client redis.UniversalClient
+	key    string
+}
+
+func (s *redisSchedule) Add(ctx context.Context, scheduled Scheduled) error {
+	buf, err := json.Marshal(scheduled)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, s.events(), scheduled.Event.ID, string(buf))
+		pipe.ZAdd(ctx, s.key, redis.Z{Score: float64(scheduled.At.UnixMilli()), Member: scheduled.Event.ID})
+		pipe.ZRem(ctx, s.claimed(), scheduled.Event.ID)
+		return nil
+	})
+
+	return err
+}
+
+func (s *redisSchedule) Cancel(ctx context.Context, id string) error {
+	removed, err := cancelPending.Run(ctx, s.client, []string{s.key, s.events()}, id).Int()
+	if err != nil {
+		return err
+	}
+
+	if removed == 0 {
+		return ErrScheduledNotFound
+	}
+
+	return nil
+}
+
+func (s *redisSchedule) Pending(ctx context.Context, limit, offset int) ([]Scheduled, error) {
+	stop := int64(-1)
+	if limit >= 0 {
+		stop = int64(offset) + int64(limit) - 1
+	}
+
+	pending := []Scheduled{}
+	if limit == 0 {
+		return pending, nil
+	}
+
+	ids, err := s.client.ZRange(ctx, s.key, int64(offset), stop).Result()
+	if err != nil || len(ids) == 0 {
+		return pending, err
+	}
+
+	values, err := s.client.HMGet(ctx, s.events(), ids...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, value := range values {
+		raw, ok := value.(string)
+		if !ok {
+			// published or cancelled between the two reads
+			continue
+		}
+
+		var scheduled Scheduled
+		if err := json.Unmarshal([]byte(raw), &scheduled); err != nil {
+			return nil, err
+		}
+		pending = append(pending, scheduled)
+	}
+
+	return pending, nil
+}
+
+func (s *redisSchedule) Due(ctx context.Context, now time.Time, limit int) ([]Scheduled, error) {
+	keys := []string{s.key, s.events(), s.claimed()}
+	deadline := now.Add(SCHEDULE_CLAIM_TIMEOUT)
+
+	values, err := claimDue.Run(ctx, s.client, keys, strconv.FormatInt(now.UnixMilli(), 10), limit, strconv.FormatInt(deadline.UnixMilli(), 10)).StringSlice()
+	if err != nil {
+		return nil, err
+	}
+
+	// the script returns the ids and the events in pairs
+	due := make([]Scheduled, 0, len(values)/2)
+	for i := 0; i+1 < len(values); i += 2 {
+		var scheduled Scheduled
+		if err := json.Unmarshal([]byte(values[i+1]), &scheduled); err != nil {
+			logger.For("event").Error("cannot decode scheduled event, dropping it", "id", values[i], "error", err)
+			s.Ack(ctx, values[i])
+			continue
+		}
+		due = append(due, scheduled)
+	}
+
+	return due, nil
+}
+
+func (s *redisSchedule) Ack(ctx context.Context, id string) error {
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZRem(ctx, s.claimed(), id)
+		pipe.HDel(ctx, s.events(), id)
+		return nil
+	})
+
+	return err
+}
+
+func (s *redisSchedule) events() string {
+	return s.key + ":events"
+}
+
+func (s *redisSchedule) claimed() string {
+	return s.key + ":claimed"
+}
+
+// NewRedisSchedule creates a schedule shared by the replicas under the key, SCHEDULE_KEY when empty.
+// The key is wrapped in a hash tag, so the index, the claims and the events share a cluster slot.
+func NewRedisSchedule(client redis.UniversalClient, key string) *redisSchedule {
+	if key == "" {
+		key = SCHEDULE_KEY
+	} else if !strings.HasPrefix(key, "{") {
+		key = "{" + key + "}"
+	}
+
+	return &redisSchedule{client, key}
+}
@@ -0,0 +1,292 @@
+package event
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/QubelyLabs/bedrock/pkg/logger"
+	"github.com/QubelyLabs/bedrock/pkg/tracing"
+)
+
+const (
+	SCHEDULE_POLL_INTERVAL = time.Second
+	SCHEDULE_BATCH_SIZE    = 100
+	SCHEDULE_CLAIM_TIMEOUT = time.Minute
+)
+
+var (
+	ErrScheduledNotFound = errors.New("event: scheduled event not found")
+)
+
+// Scheduled is an event waiting for its time
+type Scheduled struct {
+	Event Event     `json:"event"`
+	At    time.Time `json:"at"`
+}
+
+// ScheduleStore keeps the scheduled events until they are published
+type ScheduleStore interface {
+	// Add schedules the event, replacing a pending or claimed event with the same id
+	Add(ctx context.Context, scheduled Scheduled) error
+	Cancel(ctx context.Context, id string) error
+	// Pending returns the scheduled events, soonest first, a negative limit returns all
+	Pending(ctx context.Context, limit, offset int) ([]Scheduled, error)
+	// Due claims up to limit events due at now until now + SCHEDULE_CLAIM_TIMEOUT, an event is returned to one caller only.
+	// Claimed events not acknowledged by then, e.g. as their replica died, are returned again.
+	Due(ctx context.Context, now time.Time, limit int) ([]Scheduled, error)
+	// Ack removes a claimed event once published
+	Ack(ctx context.Context, id string) error
+}
+
+var (
+	schedule     ScheduleStore = NewMemorySchedule()
+	pollInterval               = SCHEDULE_POLL_INTERVAL
+	poller       *schedulePoller
+)
+
+// SetSchedule replaces the store of the scheduled events, call it before scheduling events and starting the listener
+func SetSchedule(store ScheduleStore) {
+	schedule = store
+}
+
+// EmitAt publishes the event at the time, the poller of StartListener moves it onto the bus once due.
+// It returns the event id to cancel it with.
+func EmitAt(ctx context.Context, e Event, at time.Time) (string, error) {
+	e = Envelop(ctx, e)
+	if e.Trace == nil {
+		e.Trace = map[string]string{}
+		tracing.Inject(ctx, e.Trace)
+	}
+
+	if err := schedule.Add(ctx, Scheduled{Event: e, At: at}); err != nil {
+		return "", err
+	}
+
+	return e.ID, nil
+}
+
+// EmitAfter publishes the event once the delay elapsed, see EmitAt
+func EmitAfter(ctx context.Context, e Event, delay time.Duration) (string, error) {
+	return EmitAt(ctx, e, time.Now().Add(delay))
+}
+
+// PublishAt publishes the typed payload at the time, see Publish and EmitAt
+func PublishAt[T any](ctx context.Context, name string, payload T, at time.Time) (string, error) {
+	e, err := New(ctx, name, payload)
+	if err != nil {
+		return "", err
+	}
+
+	return EmitAt(ctx, e, at)
+}
+
+// CancelScheduled removes a scheduled event before it is due
+func CancelScheduled(ctx context.Context, id string) error {
+	return schedule.Cancel(ctx, id)
+}
+
+// PendingScheduled lists the scheduled events, soonest first
+func PendingScheduled(ctx context.Context, limit, offset int) ([]Scheduled, error) {
+	return schedule.Pending(ctx, limit, offset)
+}
+
+// schedulePoller moves the due events onto the transport every interval
+type schedulePoller struct {
+	interval time.Duration
+	quit     chan struct{}
+	stopped  chan struct{}
+	stop     sync.Once
+}
+
+func (p *schedulePoller) run() {
+	defer close(p.stopped)
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			p.poll()
+		case <-p.quit:
+			return
+		}
+	}
+}
+
+// poll publishes the due events in batches until none is left or the poller stops, an event is removed only once published.
+// On a failure to publish the poll stops, as the transport is likely down: the failed event is put back
+// in the schedule an interval later, so it does not block the others, and the rest of the batch as it was.
+func (p *schedulePoller) poll() {
+	ctx := context.Background()
+	log := logger.For("event")
+
+	for {
+		select {
+		case <-p.quit:
+			return
+		default:
+		}
+
+		due, err := schedule.Due(ctx, time.Now(), SCHEDULE_BATCH_SIZE)
+		if err != nil {
+			log.Error("cannot read due scheduled events", "error", err)
+			return
+		}
+
+		for i, scheduled := range due {
+			if err := publish(ctx, scheduled.Event); err != nil {
+				log.Error("cannot publish scheduled event, rescheduling it", "event", scheduled.Event.Name, "id", scheduled.Event.ID, "error", err)
+
+				due[i].At = time.Now().Add(p.interval)
+				p.reschedule(ctx, due[i:])
+				return
+			}
+
+			// an event published but not acknowledged is published again once its claim expired
+			if err := schedule.Ack(ctx, scheduled.Event.ID); err != nil {
+				log.Error("cannot remove published scheduled event", "event", scheduled.Event.Name, "id", scheduled.Event.ID, "error", err)
+			}
+		}
+
+		if len(due) < SCHEDULE_BATCH_SIZE {
+			return
+		}
+	}
+}
+
+// reschedule puts the events back in the schedule
+func (p *schedulePoller) reschedule(ctx context.Context, events []Scheduled) {
+	for _, scheduled := range events {
+		if err := schedule.Add(ctx, scheduled); err != nil {
+			logger.For("event").Error("cannot reschedule event, event dropped", "event", scheduled.Event.Name, "id", scheduled.Event.ID, "error", err)
+		}
+	}
+}
+
+// Stop waits for the poll in progress
+func (p *schedulePoller) Stop(ctx context.Context) error {
+	p.stop.Do(func() { close(p.quit) })
+
+	select {
+	case <-p.stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func startPoller(interval time.Duration) *schedulePoller {
+	p := &schedulePoller{
+		interval: interval,
+		quit:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+
+	go p.run()
+	return p
+}
+
+// memorySchedule keeps the scheduled events in the process, they are lost on exit
+type memorySchedule struct {
+	mutex   sync.Mutex
+	events  map[string]Scheduled
+	claimed map[string]Scheduled // At is the claim deadline
+}
+
+func (s *memorySchedule) Add(ctx context.Context, scheduled Scheduled) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.claimed, scheduled.Event.ID)
+	s.events[scheduled.Event.ID] = scheduled
+	return nil
+}
+
+func (s *memorySchedule) Cancel(ctx context.Context, id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.events[id]; !ok {
+		return ErrScheduledNotFound
+	}
+
+	delete(s.events, id)
+	return nil
+}
+
+func (s *memorySchedule) Pending(ctx context.Context, limit, offset int) ([]Scheduled, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	pending := sorted(s.events)
+	if offset > len(pending) {
+		return []Scheduled{}, nil
+	}
+
+	pending = pending[offset:]
+	if limit >= 0 && limit < len(pending) {
+		pending = pending[:limit]
+	}
+
+	return pending, nil
+}
+
+func (s *memorySchedule) Due(ctx context.Context, now time.Time, limit int) ([]Scheduled, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	due := []Scheduled{}
+	for _, scheduled := range sorted(s.claimed) {
+		if scheduled.At.After(now) || len(due) == limit {
+			break
+		}
+
+		due = append(due, scheduled)
+	}
+
+	for _, scheduled := range sorted(s.events) {
+		if scheduled.At.After(now) || len(due) == limit {
+			break
+		}
+
+		due = append(due, scheduled)
+		delete(s.events, scheduled.Event.ID)
+	}
+
+	for _, scheduled := range due {
+		s.claimed[scheduled.Event.ID] = Scheduled{Event: scheduled.Event, At: now.Add(SCHEDULE_CLAIM_TIMEOUT)}
+	}
+
+	return due, nil
+}
+
+func (s *memorySchedule) Ack(ctx context.Context, id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.claimed, id)
+	return nil
+}
+
+// sorted returns the events soonest first
+func sorted(scheduled map[string]Scheduled) []Scheduled {
+	events := make([]Scheduled, 0, len(scheduled))
+	for _, e := range scheduled {
+		events = append(events, e)
+	}
+
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].At.Before(events[j].At)
+	})
+
+	return events
+}
+
+// NewMemorySchedule creates an in process schedule, suited to a single replica
+func NewMemorySchedule() *memorySchedule {
+	return &memorySchedule{events: map[string]Scheduled{}, claimed: map[string]Scheduled{}}
+}
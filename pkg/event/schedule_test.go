@@ -0,0 +1,227 @@
+package event
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func newScheduled(id string, at time.Time) Scheduled {
+	return Scheduled{Event: Event{ID: id, Name: "invoice.due"}, At: at}
+}
+
+func scheduledIDs(events []Scheduled) []string {
+	ids := []string{}
+	for _, s := range events {
+		ids = append(ids, s.Event.ID)
+	}
+
+	return ids
+}
+
+func equalIDs(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func TestMemorySchedulePending(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	s := NewMemorySchedule()
+	s.Add(ctx, newScheduled("c", now.Add(3*time.Minute)))
+	s.Add(ctx, newScheduled("a", now.Add(time.Minute)))
+	s.Add(ctx, newScheduled("b", now.Add(2*time.Minute)))
+
+	tests := []struct {
+		name          string
+		limit, offset int
+		want          []string
+	}{
+		{"all", -1, 0, []string{"a", "b", "c"}},
+		{"limit", 2, 0, []string{"a", "b"}},
+		{"offset", -1, 1, []string{"b", "c"}},
+		{"limit and offset", 1, 1, []string{"b"}},
+		{"offset past the end", 10, 5, []string{}},
+		{"zero limit", 0, 0, []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pending, err := s.Pending(ctx, tt.limit, tt.offset)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := scheduledIDs(pending); !equalIDs(got, tt.want) {
+				t.Errorf("Pending(%v, %v) = %v, want %v", tt.limit, tt.offset, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoryScheduleDue(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	s := NewMemorySchedule()
+	s.Add(ctx, newScheduled("late", now.Add(-time.Minute)))
+	s.Add(ctx, newScheduled("later", now.Add(-time.Second)))
+	s.Add(ctx, newScheduled("now", now))
+	s.Add(ctx, newScheduled("future", now.Add(time.Minute)))
+
+	due, _ := s.Due(ctx, now, 2)
+	if got := scheduledIDs(due); !equalIDs(got, []string{"late", "later"}) {
+		t.Errorf("Due with a limit of 2 = %v, want the soonest two", got)
+	}
+
+	due, _ = s.Due(ctx, now, 10)
+	if got := scheduledIDs(due); !equalIDs(got, []string{"now"}) {
+		t.Errorf("second Due = %v, want only the event due now, the others were taken", got)
+	}
+
+	due, _ = s.Due(ctx, now, 10)
+	if len(due) != 0 {
+		t.Errorf("third Due = %v, want none", scheduledIDs(due))
+	}
+
+	pending, _ := s.Pending(ctx, -1, 0)
+	if got := scheduledIDs(pending); !equalIDs(got, []string{"future"}) {
+		t.Errorf("Pending after Due = %v, want the future event", got)
+	}
+}
+
+func TestMemoryScheduleAddAndCancel(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	s := NewMemorySchedule()
+	s.Add(ctx, newScheduled("a", now.Add(time.Minute)))
+	s.Add(ctx, newScheduled("a", now.Add(-time.Minute)))
+
+	pending, _ := s.Pending(ctx, -1, 0)
+	if len(pending) != 1 || !pending[0].At.Before(now) {
+		t.Errorf("Add of the same id = %+v, want it replaced", pending)
+	}
+
+	if err := s.Cancel(ctx, "a"); err != nil {
+		t.Errorf("Cancel: %v", err)
+	}
+	if err := s.Cancel(ctx, "a"); !errors.Is(err, ErrScheduledNotFound) {
+		t.Errorf("second Cancel: got %v, want ErrScheduledNotFound", err)
+	}
+	if due, _ := s.Due(ctx, now, 10); len(due) != 0 {
+		t.Errorf("Due after Cancel = %v, want none", scheduledIDs(due))
+	}
+}
+
+func TestPollReschedulesOnPublishFailure(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	previous := schedule
+	s := NewMemorySchedule()
+	SetSchedule(s)
+	t.Cleanup(func() {
+		SetSchedule(previous)
+		ResetMiddlewares()
+	})
+
+	published := 0
+	UsePublish(func(next PublishFunc) PublishFunc {
+		return func(ctx context.Context, e Event) error {
+			published++
+			return errors.New("transport down")
+		}
+	})
+
+	s.Add(ctx, newScheduled("a", now.Add(-3*time.Second)))
+	s.Add(ctx, newScheduled("b", now.Add(-2*time.Second)))
+	s.Add(ctx, newScheduled("c", now.Add(-time.Second)))
+
+	p := &schedulePoller{interval: time.Minute, quit: make(chan struct{})}
+	p.poll()
+
+	if published != 1 {
+		t.Errorf("poll made %v publish attempts, want it to stop after the first failure", published)
+	}
+
+	pending, _ := s.Pending(ctx, -1, 0)
+	if got := scheduledIDs(pending); !equalIDs(got, []string{"b", "c", "a"}) {
+		t.Errorf("Pending after the failure = %v, want the failed event after the others", got)
+	}
+	if !pending[2].At.After(now) {
+		t.Errorf("the failed event was put back at %v, want an interval later", pending[2].At)
+	}
+
+	close(p.quit)
+	p.poll()
+
+	if published != 1 {
+		t.Errorf("poll of a stopped poller made %v publish attempts, want none", published-1)
+	}
+}
+
+func TestMemoryScheduleClaims(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	s := NewMemorySchedule()
+	s.Add(ctx, newScheduled("a", now.Add(-time.Second)))
+	s.Add(ctx, newScheduled("b", now.Add(-time.Second)))
+
+	if due, _ := s.Due(ctx, now, 10); len(due) != 2 {
+		t.Fatalf("Due = %v, want both events", scheduledIDs(due))
+	}
+
+	s.Ack(ctx, "a")
+
+	if due, _ := s.Due(ctx, now.Add(SCHEDULE_CLAIM_TIMEOUT/2), 10); len(due) != 0 {
+		t.Errorf("Due before the claim expired = %v, want none", scheduledIDs(due))
+	}
+
+	expired := now.Add(SCHEDULE_CLAIM_TIMEOUT + time.Second)
+	due, _ := s.Due(ctx, expired, 10)
+	if got := scheduledIDs(due); !equalIDs(got, []string{"b"}) {
+		t.Errorf("Due after the claim expired = %v, want the event not acknowledged", got)
+	}
+
+	s.Add(ctx, newScheduled("b", expired))
+	if due, _ := s.Due(ctx, expired.Add(SCHEDULE_CLAIM_TIMEOUT+time.Second), 10); len(due) != 1 {
+		t.Errorf("Due of a rescheduled claim = %v, want it once", scheduledIDs(due))
+	}
+}
+
+func TestPollAcknowledgesPublished(t *testing.T) {
+	ctx := context.Background()
+
+	previous := schedule
+	s := NewMemorySchedule()
+	SetSchedule(s)
+	t.Cleanup(func() {
+		SetSchedule(previous)
+		ResetMiddlewares()
+	})
+
+	UsePublish(func(next PublishFunc) PublishFunc {
+		return func(ctx context.Context, e Event) error { return nil }
+	})
+
+	s.Add(ctx, newScheduled("a", time.Now().Add(-time.Second)))
+
+	p := &schedulePoller{interval: time.Minute, quit: make(chan struct{})}
+	p.poll()
+
+	if due, _ := s.Due(ctx, time.Now().Add(SCHEDULE_CLAIM_TIMEOUT+time.Second), 10); len(due) != 0 {
+		t.Errorf("Due after the event was published = %v, want none", scheduledIDs(due))
+	}
+}
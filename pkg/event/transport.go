@@ -0,0 +1,156 @@
+package event
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/QubelyLabs/bedrock/pkg/metrics"
+)
+
+// Transport carries events from emitters to the listeners
+type Transport interface {
+	// Publish hands the event to the transport, it is delivered asynchronously
+	Publish(ctx context.Context, event Event) error
+
+	// Start delivers events to handle until Stop is called, it blocks.
+	// handle returns once the event is queued and calls done when its listeners are through with it.
+	Start(handle func(event Event, done func())) error
+
+	// Stop asks Start to return once the events in flight are handled, or when ctx is done
+	Stop(ctx context.Context) error
+}
+
+// memoryTransport is a buffered channel, events stay in the process and are lost on exit
+type memoryTransport struct {
+	events  chan Event
+	quit    chan struct{}
+	stopped chan struct{}
+	stop    sync.Once
+}
+
+// Publish queues the event, waiting up to EVENT_TIMEOUT seconds while the buffer is full, or until ctx is done
+func (t *memoryTransport) Publish(ctx context.Context, event Event) error {
+	timeout := time.NewTimer(time.Second * EVENT_TIMEOUT)
+	defer timeout.Stop()
+
+	select {
+	case t.events <- event:
+		metrics.EventQueueDepth.Set(float64(len(t.events)))
+		return nil
+	case <-timeout.C:
+		return fmt.Errorf("event queue full after timeout, event dropped: %v", event)
+	case <-ctx.Done():
+		return fmt.Errorf("event queue full, event dropped: %v: %w", event, ctx.Err())
+	}
+}
+
+// TryPublish queues the event, failing right away when the buffer is full
+func (t *memoryTransport) TryPublish(event Event) error {
+	select {
+	case t.events <- event:
+		metrics.EventQueueDepth.Set(float64(len(t.events)))
+		return nil
+	default:
+		// Channel full, handle buffer overflow (optional)
+		return fmt.Errorf("event queue full, event dropped: %v", event)
+	}
+}
+
+// Start hands the queued events over in order, on stop the remaining events are handed over before it returns
+func (t *memoryTransport) Start(handle func(event Event, done func())) error {
+	for {
+		// Receive an event from the channel
+		select {
+		case event, ok := <-t.events:
+			if !ok {
+				return nil
+			}
+			metrics.EventQueueDepth.Set(float64(len(t.events)))
+			handle(event, func() {})
+		case <-t.quit:
+			// drain the events emitted before or during shutdown
+			for {
+				select {
+				case event := <-t.events:
+					metrics.EventQueueDepth.Set(float64(len(t.events)))
+					handle(event, func() {})
+				default:
+					close(t.stopped)
+					return nil
+				}
+			}
+		}
+	}
+}
+
+func (t *memoryTransport) Stop(ctx context.Context) error {
+	t.stop.Do(func() { close(t.quit) })
+
+	select {
+	case <-t.stopped:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("event queue not drained, %d events left: %w", len(t.events), ctx.Err())
+	}
+}
+
+// NewMemoryTransport creates an in process transport buffering up to size events
+func NewMemoryTransport(size int) *memoryTransport {
+	return &memoryTransport{
+		events:  make(chan Event, size),
+		quit:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+}
+
+// syncTransport delivers events inline in the publishing goroutine, e.g. in tests and scripts.
+// Listeners run one after the other, failed attempts are retried right away and Publish returns their errors.
+type syncTransport struct {
+	quit chan struct{}
+	stop sync.Once
+}
+
+func (t *syncTransport) Publish(ctx context.Context, event Event) error {
+	var errs []error
+	for _, listener := range matching(event.Name) {
+		var attempts []Attempt
+		for len(attempts) < listener.Retry.attempts() {
+			err := handle(listener, event)
+			if err == nil {
+				metrics.EventsHandled.WithLabelValues(event.Name, listener.ID, "ok").Inc()
+				attempts = nil
+				break
+			}
+
+			attempts = append(attempts, Attempt{At: time.Now(), Error: err.Error()})
+			metrics.EventListenerFailures.WithLabelValues(event.Name, listener.ID).Inc()
+		}
+
+		if len(attempts) > 0 {
+			metrics.EventsHandled.WithLabelValues(event.Name, listener.ID, "failed").Inc()
+			bury(event, listener.ID, attempts)
+			errs = append(errs, fmt.Errorf("event: listener %v failed: %v", listener.ID, attempts[len(attempts)-1].Error))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Start has nothing to receive, it blocks until Stop so it can replace the other transports
+func (t *syncTransport) Start(handle func(event Event, done func())) error {
+	<-t.quit
+	return nil
+}
+
+func (t *syncTransport) Stop(ctx context.Context) error {
+	t.stop.Do(func() { close(t.quit) })
+	return nil
+}
+
+// NewSyncTransport creates a transport delivering events inline, without StartListener
+func NewSyncTransport() *syncTransport {
+	return &syncTransport{quit: make(chan struct{})}
+}
@@ -0,0 +1,73 @@
+package event
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSyncTransport(t *testing.T) {
+	ctx := context.Background()
+
+	previous := deadLetters
+	letters := NewMemoryDeadLetters(10)
+	SetDeadLetters(letters)
+	t.Cleanup(func() {
+		SetDeadLetters(previous)
+		UnregisterListener("test.sync.paid")
+	})
+
+	calls := map[string]int{}
+	listener := func(id string, failures int) Listener {
+		return Listener{
+			Name:  "test.sync.paid",
+			ID:    id,
+			Retry: &RetryPolicy{Attempts: 3},
+			handler: func(ctx context.Context, e Event) error {
+				calls[id]++
+				if calls[id] <= failures {
+					return errors.New("unavailable")
+				}
+
+				return nil
+			},
+		}
+	}
+
+	for _, l := range []Listener{listener("flaky", 2), listener("broken", 5)} {
+		if err := register(l, l.handler); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := NewSyncTransport().Publish(ctx, Event{ID: "1", Name: "test.sync.paid"})
+	if err == nil || !strings.Contains(err.Error(), "listener broken failed") || calls["flaky"] != 3 || calls["broken"] != 3 {
+		t.Fatalf("Publish = %v with calls %v, want the broken listener error after 3 attempts each", err, calls)
+	}
+
+	buried, _ := letters.List(ctx, DeadLetterFilter{Event: "test.sync.paid"}, -1, 0)
+	if len(buried) != 1 || buried[0].Listener != "broken" || len(buried[0].Attempts) != 3 {
+		t.Errorf("dead letters = %+v, want the broken listener with its 3 attempts", buried)
+	}
+}
+
+func TestMemoryTransportPublishContext(t *testing.T) {
+	transport := NewMemoryTransport(1)
+	if err := transport.Publish(context.Background(), Event{Name: "invoice.paid"}); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	started := time.Now()
+	err := transport.Publish(ctx, Event{Name: "invoice.paid"})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Publish on a full buffer = %v, want the context error", err)
+	}
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Errorf("Publish on a full buffer returned after %v, want once ctx is done", elapsed)
+	}
+}
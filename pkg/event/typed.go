@@ -0,0 +1,141 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// Versioned is implemented by payloads with a schema version, others are at version 1.
+// Bump the version on breaking changes and register an Upcaster from the previous one,
+// additive changes keep the version as unknown fields are ignored when decoding.
+type Versioned interface {
+	EventVersion() int
+}
+
+// Upcaster rewrites the data of an event from a version to the next one
+type Upcaster func(data json.RawMessage) (json.RawMessage, error)
+
+var (
+	upcasters     = map[string]map[int]Upcaster{}
+	upcasterMutex = &sync.RWMutex{}
+)
+
+// RegisterUpcaster registers the upcaster of the event name from the version to version+1
+func RegisterUpcaster(name string, from int, upcaster Upcaster) {
+	upcasterMutex.Lock()
+	defer upcasterMutex.Unlock()
+
+	if upcasters[name] == nil {
+		upcasters[name] = map[int]Upcaster{}
+	}
+	upcasters[name][from] = upcaster
+}
+
+// upcast brings the data from the version to the target version, newer data is returned as is
+func upcast(name string, version, target int, data json.RawMessage) (json.RawMessage, error) {
+	upcasterMutex.RLock()
+	defer upcasterMutex.RUnlock()
+
+	for ; version < target; version++ {
+		upcaster, ok := upcasters[name][version]
+		if !ok {
+			return nil, fmt.Errorf("event: no upcaster for %v from version %v", name, version)
+		}
+
+		var err error
+		if data, err = upcaster(data); err != nil {
+			return nil, fmt.Errorf("event: cannot upcast %v from version %v: %w", name, version, err)
+		}
+	}
+
+	return data, nil
+}
+
+func versionOf(payload any) int {
+	if v, ok := payload.(Versioned); ok {
+		return v.EventVersion()
+	}
+
+	return 1
+}
+
+// New creates the envelope of a typed payload, see Envelop for the fields taken from ctx
+func New[T any](ctx context.Context, name string, payload T) (Event, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return Event{}, fmt.Errorf("event: cannot encode %v: %w", name, err)
+	}
+
+	return Envelop(ctx, Event{Name: name, Version: versionOf(payload), Key: keyOf(payload), Data: data}), nil
+}
+
+// Decode returns the typed payload of the event, upcasting older versions.
+// Events emitted with a single untyped payload value are decoded from it.
+func Decode[T any](e Event) (T, error) {
+	var payload T
+
+	data := e.Data
+	if len(data) == 0 {
+		if len(e.Payload) != 1 {
+			return payload, fmt.Errorf("event: %v has no data to decode", e.Name)
+		}
+
+		var err error
+		if data, err = json.Marshal(e.Payload[0]); err != nil {
+			return payload, fmt.Errorf("event: cannot encode the payload of %v: %w", e.Name, err)
+		}
+	}
+
+	version := e.Version
+	if version == 0 {
+		version = 1
+	}
+
+	data, err := upcast(e.Name, version, versionOf(payload), data)
+	if err != nil {
+		return payload, err
+	}
+
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return payload, fmt.Errorf("event: cannot decode %v: %w", e.Name, err)
+	}
+
+	return payload, nil
+}
+
+// Publish emits the payload as the data of a new event, encoding errors are returned to the caller
+func Publish[T any](ctx context.Context, name string, payload T) error {
+	e, err := New(ctx, name, payload)
+	if err != nil {
+		return err
+	}
+
+	return EmitWithContext(ctx, e)
+}
+
+// Subscribe registers a listener receiving the decoded payload of the events matching name, see Listener.
+// Its context carries the handling span, the timeout and the envelope, see FromContext.
+func Subscribe[T any](name string, fn func(ctx context.Context, payload T) error, opts ...*Options) error {
+	options := &Options{}
+	if len(opts) > 0 && opts[0] != nil {
+		options = opts[0]
+	}
+
+	return register(Listener{
+		Name:        name,
+		ID:          options.ID,
+		Concurrency: options.Concurrency,
+		Timeout:     options.Timeout,
+		Retry:       options.Retry,
+		handler: func(ctx context.Context, e Event) error {
+			payload, err := Decode[T](e)
+			if err != nil {
+				return err
+			}
+
+			return fn(ctx, payload)
+		},
+	}, fn)
+}
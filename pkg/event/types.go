@@ -1,11 +1,34 @@
 package event
 
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// Event is the envelope carried by the transports, Publish fills every field and Emit the missing ones
 type Event struct {
-	Name    string
-	Payload []any
+	ID            string            `json:"id,omitempty"`
+	Name          string            `json:"name"`
+	Version       int               `json:"version,omitempty"` // Schema version of Data, see Versioned
+	Key           string            `json:"key,omitempty"`     // Partition key, a listener handles the events of a key in order
+	Source        string            `json:"source,omitempty"`  // Service that emitted the event
+	Time          time.Time         `json:"time"`
+	CorrelationID string            `json:"correlation_id,omitempty"` // Shared by the events caused by the same request
+	CausationID   string            `json:"causation_id,omitempty"`   // ID of the event being handled when this one was emitted
+	Metadata      map[string]string `json:"metadata,omitempty"`       // e.g. META_USER_ID and META_WORKSPACE_ID
+	Data          json.RawMessage   `json:"data,omitempty"`           // Typed payload, see Publish and Subscribe
+	Payload       []any             `json:"payload,omitempty"`
+	Trace         map[string]string `json:"trace,omitempty"` // Trace context of the emitter, set by EmitWithContext
 }
 
 type Listener struct {
-	Name    string
-	Handler func(...any) error
+	Name        string // Event name or pattern, * matches a dot separated segment and ** any number of them
+	Handler     func(...any) error
+	ID          string        // Identifies the listener in dead letters and metrics, defaults to the event and handler function names
+	Concurrency int           // Deliveries running at once, unlimited when zero
+	Timeout     time.Duration // Fails an attempt taking longer, none when zero, a handler ignoring its context keeps running outside of Concurrency
+	Retry       *RetryPolicy  // DefaultRetryPolicy when nil
+
+	handler func(ctx context.Context, e Event) error // set by Subscribe, used instead of Handler
 }
@@ -0,0 +1,76 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+type Checker interface {
+	Name() string
+	Check(ctx context.Context) error
+}
+
+type checker struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+func (c *checker) Name() string {
+	return c.name
+}
+
+func (c *checker) Check(ctx context.Context) error {
+	return c.fn(ctx)
+}
+
+// NewChecker creates a custom checker, fn returns an error when the dependency is unhealthy
+func NewChecker(name string, fn func(ctx context.Context) error) Checker {
+	return &checker{name, fn}
+}
+
+// SQLChecker pings the database connection pool
+func SQLChecker(name string, db *gorm.DB) Checker {
+	return NewChecker(name, func(ctx context.Context) error {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return err
+		}
+
+		return sqlDB.PingContext(ctx)
+	})
+}
+
+// RedisChecker pings the redis server
+func RedisChecker(name string, client redis.UniversalClient) Checker {
+	return NewChecker(name, func(ctx context.Context) error {
+		return client.Ping(ctx).Err()
+	})
+}
+
+// HTTPChecker expects a 2xx response to a GET request on the url of a downstream dependency
+func HTTPChecker(name string, url string) Checker {
+	client := &http.Client{}
+
+	return NewChecker(name, func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+
+		response, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer response.Body.Close()
+
+		if response.StatusCode < 200 || response.StatusCode > 299 {
+			return fmt.Errorf("unexpected status %v", response.Status)
+		}
+
+		return nil
+	})
+}
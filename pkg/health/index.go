@@ -0,0 +1,203 @@
+// Package health provides liveness and readiness endpoints backed by pluggable dependency checks.
+// Liveness tells whether the process should be restarted, readiness whether it should receive traffic.
+package health
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/QubelyLabs/bedrock/pkg/config"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	TIMEOUT   = 2 * time.Second
+	CACHE_TTL = 5 * time.Second
+)
+
+var (
+	Default = NewHealth()
+)
+
+// Result is the outcome of a check
+type Result struct {
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	LatencyMs int64     `json:"latency_ms"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// check runs a checker with a timeout and caches its result,
+// a zero timeout or ttl uses the defaults and a negative ttl disables caching
+type check struct {
+	checker Checker
+	timeout time.Duration
+	ttl     time.Duration
+
+	mutex  sync.Mutex
+	result *Result
+}
+
+func (c *check) run(ctx context.Context) Result {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.result != nil && time.Since(c.result.CheckedAt) < c.ttl {
+		return *c.result
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := c.checker.Check(ctx)
+
+	result := Result{Status: "up", LatencyMs: time.Since(start).Milliseconds(), CheckedAt: start}
+	if err != nil {
+		result.Status = "down"
+		result.Error = err.Error()
+	}
+	c.result = &result
+
+	return result
+}
+
+type health struct {
+	mutex        sync.RWMutex
+	liveness     []*check
+	readiness    []*check
+	shuttingDown atomic.Bool
+}
+
+// AddLiveness registers a check failing liveness, only use it for failures a restart fixes
+func (h *health) AddLiveness(checker Checker, timeout, ttl time.Duration) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.liveness = append(h.liveness, newCheck(checker, timeout, ttl))
+}
+
+// AddReadiness registers a check failing readiness, e.g. a database or downstream service
+func (h *health) AddReadiness(checker Checker, timeout, ttl time.Duration) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.readiness = append(h.readiness, newCheck(checker, timeout, ttl))
+}
+
+// SetShuttingDown makes readiness fail, so traffic is drained before the server stops
+func (h *health) SetShuttingDown(v bool) {
+	h.shuttingDown.Store(v)
+}
+
+// Livez reports whether the process is alive
+func (h *health) Livez() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		h.mutex.RLock()
+		checks := h.liveness
+		h.mutex.RUnlock()
+
+		h.respond(c, checks, "")
+	}
+}
+
+// Readyz reports whether the process can serve traffic,
+// it fails while SHUTDOWN_SWITCH is on or the process is shutting down
+func (h *health) Readyz(cf *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		h.mutex.RLock()
+		checks := h.readiness
+		h.mutex.RUnlock()
+
+		reason := ""
+		if h.shuttingDown.Load() {
+			reason = "shutting down"
+		} else if cf != nil && config.Enabled(cf.Shutdown) {
+			reason = "shutdown switch is on"
+		}
+
+		h.respond(c, checks, reason)
+	}
+}
+
+// respond runs the checks concurrently and writes their results
+func (h *health) respond(c *gin.Context, checks []*check, reason string) {
+	results := make([]Result, len(checks))
+
+	var wg sync.WaitGroup
+	wg.Add(len(checks))
+	for i, ch := range checks {
+		go func(i int, ch *check) {
+			defer wg.Done()
+			results[i] = ch.run(c.Request.Context())
+		}(i, ch)
+	}
+	wg.Wait()
+
+	healthy := reason == ""
+	data := gin.H{}
+	for i, ch := range checks {
+		data[ch.checker.Name()] = results[i]
+		if results[i].Status != "up" {
+			healthy = false
+		}
+	}
+
+	code := http.StatusOK
+	message := "ok"
+	if !healthy {
+		code = http.StatusServiceUnavailable
+		message = "unavailable"
+		if reason != "" {
+			message = reason
+		}
+	}
+
+	c.JSON(code, gin.H{
+		"status":  healthy,
+		"message": message,
+		"data":    data,
+	})
+}
+
+func newCheck(checker Checker, timeout, ttl time.Duration) *check {
+	if timeout <= 0 {
+		timeout = TIMEOUT
+	}
+
+	if ttl < 0 {
+		ttl = 0
+	} else if ttl == 0 {
+		ttl = CACHE_TTL
+	}
+
+	return &check{checker: checker, timeout: timeout, ttl: ttl}
+}
+
+func NewHealth() *health {
+	return &health{}
+}
+
+// AddLiveness registers a liveness check on the default health
+func AddLiveness(checker Checker, timeout, ttl time.Duration) {
+	Default.AddLiveness(checker, timeout, ttl)
+}
+
+// AddReadiness registers a readiness check on the default health
+func AddReadiness(checker Checker, timeout, ttl time.Duration) {
+	Default.AddReadiness(checker, timeout, ttl)
+}
+
+// SetShuttingDown makes readiness of the default health fail
+func SetShuttingDown(v bool) {
+	Default.SetShuttingDown(v)
+}
+
+// Register mounts /livez and /readyz of the default health on the router
+func Register(router gin.IRouter, cf *config.Config) {
+	router.GET("/livez", Default.Livez())
+	router.GET("/readyz", Default.Readyz(cf))
+}
@@ -9,13 +9,13 @@ const (
 	redisContextKey = "redis_context"
 )
 
-func SetKV(c *gin.Context, v *redis.Client) {
+func SetKV(c *gin.Context, v redis.UniversalClient) {
 	c.Set(redisContextKey, v)
 }
 
-func GetKV(c *gin.Context) *redis.Client {
+func GetKV(c *gin.Context) redis.UniversalClient {
 	tx := c.MustGet(redisContextKey)
 
-	v := tx.(*redis.Client)
+	v := tx.(redis.UniversalClient)
 	return v
 }
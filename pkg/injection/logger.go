@@ -0,0 +1,26 @@
+package injection
+
+import (
+	"log/slog"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	loggerContextKey = "logger_context"
+)
+
+func SetLogger(c *gin.Context, v *slog.Logger) {
+	c.Set(loggerContextKey, v)
+}
+
+// GetLogger returns the request scoped logger, or the default logger when none was injected
+func GetLogger(c *gin.Context) *slog.Logger {
+	if v, ok := c.Get(loggerContextKey); ok {
+		if l, ok := v.(*slog.Logger); ok {
+			return l
+		}
+	}
+
+	return slog.Default()
+}
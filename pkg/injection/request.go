@@ -0,0 +1,18 @@
+package injection
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	requestIdContextKey = "request_id_context"
+)
+
+func SetRequestID(c *gin.Context, v string) {
+	c.Set(requestIdContextKey, v)
+}
+
+// GetRequestID returns the request id, or an empty string when none was injected
+func GetRequestID(c *gin.Context) string {
+	return c.GetString(requestIdContextKey)
+}
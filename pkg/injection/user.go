@@ -19,3 +19,14 @@ func GetUser(c *gin.Context) util.Object {
 	v := tx.(util.Object)
 	return v
 }
+
+// LookupUser returns the user if one was injected, unlike GetUser it does not panic
+func LookupUser(c *gin.Context) (util.Object, bool) {
+	tx, ok := c.Get(userContextKey)
+	if !ok {
+		return nil, false
+	}
+
+	v, ok := tx.(util.Object)
+	return v, ok
+}
@@ -19,3 +19,14 @@ func GetWorkspace(c *gin.Context) util.Object {
 	v := tx.(util.Object)
 	return v
 }
+
+// LookupWorkspace returns the workspace if one was injected, unlike GetWorkspace it does not panic
+func LookupWorkspace(c *gin.Context) (util.Object, bool) {
+	tx, ok := c.Get(workspaceContextKey)
+	if !ok {
+		return nil, false
+	}
+
+	v, ok := tx.(util.Object)
+	return v, ok
+}
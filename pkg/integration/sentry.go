@@ -2,20 +2,120 @@ package integration
 
 import (
 	"fmt"
+	"runtime/debug"
 
+	"github.com/QubelyLabs/bedrock/pkg/config"
+	"github.com/QubelyLabs/bedrock/pkg/injection"
 	"github.com/getsentry/sentry-go"
+	sentrygin "github.com/getsentry/sentry-go/gin"
+	"github.com/gin-gonic/gin"
 )
 
-func SentryInit() {
+// SentryInit initializes the sentry client from the config, it does nothing when no DSN is configured
+func SentryInit(cf *config.Config) error {
+	if cf.SentryDsn == "" {
+		return nil
+	}
+
 	// To initialize Sentry's handler, you need to initialize Sentry itself beforehand
 	if err := sentry.Init(sentry.ClientOptions{
-		Dsn:           "https://examplePublicKey@o0.ingest.sentry.io/0",
-		EnableTracing: true,
-		// Set TracesSampleRate to 1.0 to capture 100%
-		// of transactions for performance monitoring.
-		// We recommend adjusting this value in production,
-		TracesSampleRate: 1.0,
+		Dsn:              cf.SentryDsn,
+		Environment:      cf.GoEnv,
+		Release:          cf.SentryRelease,
+		ServerName:       cf.ServiceName,
+		SampleRate:       cf.SentrySampleRate,
+		EnableTracing:    cf.SentryTracesSampleRate > 0,
+		TracesSampleRate: cf.SentryTracesSampleRate,
+		AttachStacktrace: true,
 	}); err != nil {
-		fmt.Printf("Sentry initialization failed: %v\n", err)
+		return fmt.Errorf("sentry initialization failed: %w", err)
+	}
+
+	return nil
+}
+
+// SentryHub returns the hub of the request, or the current hub when the sentry middleware is not in use
+func SentryHub(c *gin.Context) *sentry.Hub {
+	if hub := sentrygin.GetHubFromContext(c); hub != nil {
+		return hub
 	}
+
+	return sentry.CurrentHub()
+}
+
+// SentryScope attaches the request id, user and workspace of the request to its sentry scope
+func SentryScope(c *gin.Context) {
+	hub := sentrygin.GetHubFromContext(c)
+	if hub == nil {
+		return
+	}
+
+	hub.ConfigureScope(func(scope *sentry.Scope) {
+		if id := injection.GetRequestID(c); id != "" {
+			scope.SetTag("request_id", id)
+		}
+
+		if user, ok := injection.LookupUser(c); ok {
+			id, _ := user["id"].(string)
+			email, _ := user["email"].(string)
+			scope.SetUser(sentry.User{ID: id, Email: email})
+		}
+
+		if workspace, ok := injection.LookupWorkspace(c); ok {
+			id, _ := workspace["id"].(string)
+			scope.SetTag("workspace_id", id)
+		}
+	})
+}
+
+// CaptureError reports an error of the request to sentry
+func CaptureError(c *gin.Context, err error) {
+	if err == nil {
+		return
+	}
+
+	SentryScope(c)
+	SentryHub(c).CaptureException(err)
+}
+
+// Breadcrumb records a step of the request, breadcrumbs are sent along with the next reported error
+func Breadcrumb(c *gin.Context, category, message string, data map[string]any) {
+	hub := sentrygin.GetHubFromContext(c)
+	if hub == nil {
+		return
+	}
+
+	hub.AddBreadcrumb(&sentry.Breadcrumb{
+		Category: category,
+		Message:  message,
+		Data:     data,
+		Level:    sentry.LevelInfo,
+	}, nil)
+}
+
+// PanicError is a panic recovered by Recover, with the stack of the panicking goroutine
+type PanicError struct {
+	Value any
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// Recover runs fn outside of a request, a panic is reported to sentry with the tags and returned as a PanicError
+func Recover(tags map[string]string, fn func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			hub := sentry.CurrentHub().Clone()
+			hub.ConfigureScope(func(scope *sentry.Scope) {
+				scope.SetTags(tags)
+			})
+			hub.Recover(r)
+
+			err = &PanicError{r, debug.Stack()}
+		}
+	}()
+
+	return fn()
 }
@@ -0,0 +1,85 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/QubelyLabs/bedrock/pkg/logger"
+)
+
+type election struct {
+	locker *locker
+	name   string
+	ttl    time.Duration
+	lead   func(ctx context.Context) error
+
+	leading atomic.Bool
+}
+
+// Run campaigns for the leadership until ctx is done.
+// The elected replica runs lead with a context cancelled when ctx is done or the leadership is lost,
+// and campaigns again when lead returns. The others retry every third of the ttl, taking over once the lease expires.
+func (e *election) Run(ctx context.Context) error {
+	log := logger.For("lock")
+	interval := e.ttl / 3
+
+	for {
+		lock, err := e.locker.acquire(ctx, "leader:"+e.name, 1, e.ttl, interval)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if err != nil {
+			log.Error("cannot campaign for leadership", "election", e.name, "error", err)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(interval):
+			}
+			continue
+		}
+
+		log.Info("elected leader", "election", e.name)
+		e.leading.Store(true)
+		err = lock.Hold(ctx, e.lead)
+		e.leading.Store(false)
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if err != nil && !errors.Is(err, context.Canceled) {
+			log.Error("leader stopped with an error", "election", e.name, "error", err)
+		} else {
+			log.Warn("leadership ended", "election", e.name)
+		}
+
+		// gives the other replicas a chance to take over
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+// IsLeader reports whether this replica currently runs the leader function
+func (e *election) IsLeader() bool {
+	return e.leading.Load()
+}
+
+// NewElection creates an election of name among the replicas, the leader holds its lease for ttl, TTL when zero, and keeps it alive
+func (l *locker) NewElection(name string, ttl time.Duration, lead func(ctx context.Context) error) *election {
+	if ttl <= 0 {
+		ttl = TTL
+	}
+
+	return &election{locker: l, name: name, ttl: ttl, lead: lead}
+}
+
+// NewElection creates an election of name on the default locker
+func NewElection(name string, ttl time.Duration, lead func(ctx context.Context) error) *election {
+	return Default.NewElection(name, ttl, lead)
+}
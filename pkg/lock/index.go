@@ -0,0 +1,274 @@
+// Package lock provides distributed mutexes, semaphores and leader election on leases held in redis.
+// A lease expires after its ttl, so the holder of a long task keeps it alive with Hold or KeepAlive,
+// and a crashed holder frees the lock once its lease expires.
+package lock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/QubelyLabs/bedrock/pkg/logger"
+	"github.com/google/uuid"
+)
+
+const (
+	TTL            = 30 * time.Second
+	RETRY_INTERVAL = 100 * time.Millisecond
+)
+
+var (
+	ErrNotAcquired = errors.New("lock: not acquired")
+	ErrNotHeld     = errors.New("lock: not held, the lease expired or was released")
+)
+
+// Store keeps the leases, a key holds up to limit leases at once
+type Store interface {
+	// Acquire takes a lease of the key for the token, false when limit other tokens hold one
+	Acquire(ctx context.Context, key, token string, limit int, ttl time.Duration) (bool, error)
+	// Extend renews the lease of the token for ttl, false when it is not held anymore
+	Extend(ctx context.Context, key, token string, ttl time.Duration) (bool, error)
+	// Release drops the lease of the token, false when it is not held anymore
+	Release(ctx context.Context, key, token string) (bool, error)
+}
+
+// Lock is a lease held on a key
+type Lock struct {
+	store Store
+	key   string
+	token string
+	ttl   time.Duration
+
+	mutex sync.Mutex
+	stop  chan struct{}
+	lost  chan struct{}
+}
+
+// Key returns the key of the lock
+func (l *Lock) Key() string {
+	return l.key
+}
+
+// Extend renews the lease for ttl, the ttl of the lock when zero
+func (l *Lock) Extend(ctx context.Context, ttl time.Duration) error {
+	if ttl <= 0 {
+		ttl = l.ttl
+	}
+
+	ok, err := l.store.Extend(ctx, l.key, l.token, ttl)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return ErrNotHeld
+	}
+
+	return nil
+}
+
+// Release stops keeping the lease alive and drops it, ErrNotHeld when it expired before
+func (l *Lock) Release(ctx context.Context) error {
+	l.mutex.Lock()
+	if l.stop != nil {
+		close(l.stop)
+		l.stop = nil
+	}
+	l.mutex.Unlock()
+
+	ok, err := l.store.Release(ctx, l.key, l.token)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return ErrNotHeld
+	}
+
+	return nil
+}
+
+// KeepAlive extends the lease every third of its ttl until it is released, the returned channel is closed when the lease is lost.
+// A lease which cannot be extended within two thirds of its ttl, e.g. while redis is unreachable, is given up before it expires,
+// so the holder stops before another one may take the lock.
+func (l *Lock) KeepAlive() <-chan struct{} {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.lost != nil {
+		return l.lost
+	}
+
+	l.stop = make(chan struct{})
+	l.lost = make(chan struct{})
+
+	go func(stop, lost chan struct{}) {
+		ticker := time.NewTicker(l.ttl / 3)
+		defer ticker.Stop()
+
+		extended := time.Now()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+
+			deadline := extended.Add(l.ttl - l.ttl/3)
+			ctx, cancel := context.WithDeadline(context.Background(), deadline)
+			err := l.Extend(ctx, l.ttl)
+			cancel()
+
+			switch {
+			case err == nil:
+				extended = time.Now()
+				continue
+			case errors.Is(err, ErrNotHeld):
+				logger.For("lock").Warn("lock lease lost", "key", l.key)
+			case time.Now().Before(deadline):
+				logger.For("lock").Error("cannot extend lock lease, retrying", "key", l.key, "error", err)
+				continue
+			default:
+				logger.For("lock").Error("cannot extend lock lease, giving it up", "key", l.key, "error", err)
+			}
+
+			close(lost)
+			return
+		}
+	}(l.stop, l.lost)
+
+	return l.lost
+}
+
+// Hold keeps the lease alive while fn runs and releases it once fn returns.
+// The context of fn is cancelled when the lease is lost, as another holder may take the lock.
+func (l *Lock) Hold(ctx context.Context, fn func(ctx context.Context) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	lost := l.KeepAlive()
+	go func() {
+		select {
+		case <-lost:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	err := fn(ctx)
+	if released := l.Release(context.WithoutCancel(ctx)); released != nil && !errors.Is(released, ErrNotHeld) {
+		logger.For("lock").Error("cannot release lock", "key", l.key, "error", released)
+	}
+
+	return err
+}
+
+type locker struct {
+	store    Store
+	interval time.Duration
+}
+
+// TryAcquire takes the mutex of the key for ttl, TTL when zero, or returns ErrNotAcquired when it is held
+func (l *locker) TryAcquire(ctx context.Context, key string, ttl time.Duration) (*Lock, error) {
+	return l.TryAcquireSemaphore(ctx, key, 1, ttl)
+}
+
+// Acquire waits for the mutex of the key until ctx is done, use a context with a timeout to bound the wait
+func (l *locker) Acquire(ctx context.Context, key string, ttl time.Duration) (*Lock, error) {
+	return l.AcquireSemaphore(ctx, key, 1, ttl)
+}
+
+// TryAcquireSemaphore takes one of the limit leases of the key, or returns ErrNotAcquired when they are all held.
+// A key is either a mutex or a semaphore, with the same limit for all its holders.
+func (l *locker) TryAcquireSemaphore(ctx context.Context, key string, limit int, ttl time.Duration) (*Lock, error) {
+	if limit <= 0 {
+		return nil, fmt.Errorf("lock: invalid limit %v of %v", limit, key)
+	}
+
+	if ttl <= 0 {
+		ttl = TTL
+	}
+
+	token := uuid.New().String()
+	ok, err := l.store.Acquire(ctx, key, token, limit, ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok {
+		return nil, ErrNotAcquired
+	}
+
+	return &Lock{store: l.store, key: key, token: token, ttl: ttl}, nil
+}
+
+// AcquireSemaphore waits for one of the limit leases of the key until ctx is done
+func (l *locker) AcquireSemaphore(ctx context.Context, key string, limit int, ttl time.Duration) (*Lock, error) {
+	return l.acquire(ctx, key, limit, ttl, l.interval)
+}
+
+func (l *locker) acquire(ctx context.Context, key string, limit int, ttl, interval time.Duration) (*Lock, error) {
+	for {
+		lock, err := l.TryAcquireSemaphore(ctx, key, limit, ttl)
+		if !errors.Is(err, ErrNotAcquired) {
+			return lock, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%w: %w", ErrNotAcquired, ctx.Err())
+		case <-time.After(interval):
+		}
+	}
+}
+
+// WithLock waits for the mutex of the key, then runs fn while holding it, see Lock.Hold
+func (l *locker) WithLock(ctx context.Context, key string, ttl time.Duration, fn func(ctx context.Context) error) error {
+	lock, err := l.Acquire(ctx, key, ttl)
+	if err != nil {
+		return err
+	}
+
+	return lock.Hold(ctx, fn)
+}
+
+// NewLocker creates a locker on the store, waiting acquisitions retry every RETRY_INTERVAL
+func NewLocker(store Store) *locker {
+	return &locker{store, RETRY_INTERVAL}
+}
+
+var (
+	Default = NewLocker(NewRedisStore(nil, ""))
+)
+
+// SetStore replaces the store of the default locker, e.g. with NewMemoryStore in tests
+func SetStore(store Store) {
+	Default = NewLocker(store)
+}
+
+// TryAcquire takes the mutex of the key on the default locker
+func TryAcquire(ctx context.Context, key string, ttl time.Duration) (*Lock, error) {
+	return Default.TryAcquire(ctx, key, ttl)
+}
+
+// Acquire waits for the mutex of the key on the default locker
+func Acquire(ctx context.Context, key string, ttl time.Duration) (*Lock, error) {
+	return Default.Acquire(ctx, key, ttl)
+}
+
+// TryAcquireSemaphore takes one of the limit leases of the key on the default locker
+func TryAcquireSemaphore(ctx context.Context, key string, limit int, ttl time.Duration) (*Lock, error) {
+	return Default.TryAcquireSemaphore(ctx, key, limit, ttl)
+}
+
+// AcquireSemaphore waits for one of the limit leases of the key on the default locker
+func AcquireSemaphore(ctx context.Context, key string, limit int, ttl time.Duration) (*Lock, error) {
+	return Default.AcquireSemaphore(ctx, key, limit, ttl)
+}
+
+// WithLock runs fn while holding the mutex of the key on the default locker
+func WithLock(ctx context.Context, key string, ttl time.Duration, fn func(ctx context.Context) error) error {
+	return Default.WithLock(ctx, key, ttl, fn)
+}
@@ -0,0 +1,85 @@
+package lock
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// memoryStore holds the leases in the process, for tests and single replica services
+type memoryStore struct {
+	mutex  sync.Mutex
+	leases map[string]map[string]time.Time
+}
+
+func (s *memoryStore) Acquire(ctx context.Context, key, token string, limit int, ttl time.Duration) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	leases := s.held(key)
+	if _, ok := leases[token]; !ok && len(leases) >= limit {
+		return false, nil
+	}
+
+	if leases == nil {
+		leases = map[string]time.Time{}
+		s.leases[key] = leases
+	}
+
+	leases[token] = time.Now().Add(ttl)
+	return true, nil
+}
+
+func (s *memoryStore) Extend(ctx context.Context, key, token string, ttl time.Duration) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	leases := s.held(key)
+	if _, ok := leases[token]; !ok {
+		return false, nil
+	}
+
+	leases[token] = time.Now().Add(ttl)
+	return true, nil
+}
+
+func (s *memoryStore) Release(ctx context.Context, key, token string) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	leases := s.held(key)
+	if _, ok := leases[token]; !ok {
+		return false, nil
+	}
+
+	delete(leases, token)
+	if len(leases) == 0 {
+		delete(s.leases, key)
+	}
+
+	return true, nil
+}
+
+// held drops the expired leases of the key and returns the others, nil when there are none
+func (s *memoryStore) held(key string) map[string]time.Time {
+	leases := s.leases[key]
+
+	now := time.Now()
+	for token, expiry := range leases {
+		if !expiry.After(now) {
+			delete(leases, token)
+		}
+	}
+
+	if len(leases) == 0 {
+		delete(s.leases, key)
+		return nil
+	}
+
+	return leases
+}
+
+// NewMemoryStore creates an in process store, leases are not shared with other replicas
+func NewMemoryStore() *memoryStore {
+	return &memoryStore{leases: map[string]map[string]time.Time{}}
+}
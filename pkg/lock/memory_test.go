@@ -0,0 +1,211 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+
+	type step struct {
+		op    string // acquire, extend, release or wait
+		token string
+		limit int
+		ttl   time.Duration
+		want  bool
+	}
+
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{"mutex", []step{
+			{op: "acquire", token: "a", limit: 1, ttl: time.Minute, want: true},
+			{op: "acquire", token: "b", limit: 1, ttl: time.Minute, want: false},
+			{op: "acquire", token: "a", limit: 1, ttl: time.Minute, want: true},
+			{op: "release", token: "b", want: false},
+			{op: "release", token: "a", want: true},
+			{op: "acquire", token: "b", limit: 1, ttl: time.Minute, want: true},
+		}},
+		{"semaphore", []step{
+			{op: "acquire", token: "a", limit: 2, ttl: time.Minute, want: true},
+			{op: "acquire", token: "b", limit: 2, ttl: time.Minute, want: true},
+			{op: "acquire", token: "c", limit: 2, ttl: time.Minute, want: false},
+			{op: "release", token: "a", want: true},
+			{op: "acquire", token: "c", limit: 2, ttl: time.Minute, want: true},
+		}},
+		{"expiry", []step{
+			{op: "acquire", token: "a", limit: 1, ttl: 10 * time.Millisecond, want: true},
+			{op: "wait", ttl: 20 * time.Millisecond},
+			{op: "extend", token: "a", ttl: time.Minute, want: false},
+			{op: "acquire", token: "b", limit: 1, ttl: time.Minute, want: true},
+			{op: "release", token: "a", want: false},
+		}},
+		{"extend", []step{
+			{op: "acquire", token: "a", limit: 1, ttl: 30 * time.Millisecond, want: true},
+			{op: "extend", token: "a", ttl: time.Minute, want: true},
+			{op: "wait", ttl: 40 * time.Millisecond},
+			{op: "acquire", token: "b", limit: 1, ttl: time.Minute, want: false},
+			{op: "extend", token: "b", ttl: time.Minute, want: false},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewMemoryStore()
+
+			for i, step := range tt.steps {
+				var ok bool
+				var err error
+
+				switch step.op {
+				case "acquire":
+					ok, err = s.Acquire(ctx, "key", step.token, step.limit, step.ttl)
+				case "extend":
+					ok, err = s.Extend(ctx, "key", step.token, step.ttl)
+				case "release":
+					ok, err = s.Release(ctx, "key", step.token)
+				case "wait":
+					time.Sleep(step.ttl)
+					continue
+				}
+
+				if err != nil {
+					t.Fatalf("step %v %v %v: %v", i, step.op, step.token, err)
+				}
+				if ok != step.want {
+					t.Errorf("step %v %v %v = %v, want %v", i, step.op, step.token, ok, step.want)
+				}
+			}
+		})
+	}
+}
+
+func TestLocker(t *testing.T) {
+	ctx := context.Background()
+	l := NewLocker(NewMemoryStore())
+
+	held, err := l.TryAcquire(ctx, "key", time.Minute)
+	if err != nil {
+		t.Fatalf("TryAcquire: %v", err)
+	}
+
+	if _, err := l.TryAcquire(ctx, "key", time.Minute); !errors.Is(err, ErrNotAcquired) {
+		t.Errorf("TryAcquire of a held key: got %v, want ErrNotAcquired", err)
+	}
+
+	if _, err := l.TryAcquireSemaphore(ctx, "other", 0, time.Minute); err == nil {
+		t.Error("TryAcquireSemaphore accepted a zero limit")
+	}
+
+	waiting, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if _, err := l.Acquire(waiting, "key", time.Minute); !errors.Is(err, ErrNotAcquired) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Acquire of a held key until the deadline: got %v, want ErrNotAcquired and the deadline", err)
+	}
+
+	if err := held.Release(ctx); err != nil {
+		t.Errorf("Release: %v", err)
+	}
+	if err := held.Release(ctx); !errors.Is(err, ErrNotHeld) {
+		t.Errorf("second Release: got %v, want ErrNotHeld", err)
+	}
+
+	ran := false
+	err = l.WithLock(ctx, "key", time.Minute, func(ctx context.Context) error {
+		ran = true
+		_, err := l.TryAcquire(ctx, "key", time.Minute)
+		return err
+	})
+	if !ran || !errors.Is(err, ErrNotAcquired) {
+		t.Errorf("WithLock ran %v and returned %v, want the key held while it runs", ran, err)
+	}
+
+	if _, err := l.TryAcquire(ctx, "key", time.Minute); err != nil {
+		t.Errorf("TryAcquire after WithLock: %v, want the key released", err)
+	}
+}
+
+func TestHoldKeepsTheLeaseAlive(t *testing.T) {
+	ctx := context.Background()
+	l := NewLocker(NewMemoryStore())
+
+	held, err := l.TryAcquire(ctx, "key", 30*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = held.Hold(ctx, func(ctx context.Context) error {
+		time.Sleep(100 * time.Millisecond)
+		_, err := l.TryAcquire(ctx, "key", time.Minute)
+		return err
+	})
+	if !errors.Is(err, ErrNotAcquired) {
+		t.Errorf("TryAcquire while held past the ttl: got %v, want ErrNotAcquired", err)
+	}
+}
+
+func TestHoldCancelsOnLeaseLoss(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	l := NewLocker(store)
+
+	held, err := l.TryAcquire(ctx, "key", 30*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = held.Hold(ctx, func(ctx context.Context) error {
+		store.Release(ctx, "key", held.token)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second):
+			return nil
+		}
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Hold after the lease was taken: got %v, want the context cancelled", err)
+	}
+}
+
+// unreachableStore grants the leases but cannot extend them, like redis going away
+type unreachableStore struct {
+	*memoryStore
+}
+
+func (s unreachableStore) Extend(ctx context.Context, key, token string, ttl time.Duration) (bool, error) {
+	return false, errors.New("connection refused")
+}
+
+func TestHoldGivesUpBeforeExpiry(t *testing.T) {
+	ctx := context.Background()
+	ttl := 150 * time.Millisecond
+	l := NewLocker(unreachableStore{NewMemoryStore()})
+
+	held, err := l.TryAcquire(ctx, "key", ttl)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	acquired := time.Now()
+	err = held.Hold(ctx, func(ctx context.Context) error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second):
+			return nil
+		}
+	})
+
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Hold while the lease cannot be extended: got %v, want the context cancelled", err)
+	}
+	if elapsed := time.Since(acquired); elapsed >= ttl {
+		t.Errorf("Hold gave the lease up after %v, want before its ttl of %v", elapsed, ttl)
+	}
+}
@@ -0,0 +1,128 @@
+package lock
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/QubelyLabs/bedrock/pkg/db"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	PREFIX = "lock:"
+)
+
+var (
+	// acquireSemaphore keeps the leases of a semaphore in a sorted set scored by expiry, dropping the expired ones first
+	acquireSemaphore = redis.NewScript(`
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', ARGV[4])
+if not redis.call('ZSCORE', KEYS[1], ARGV[1]) and redis.call('ZCARD', KEYS[1]) >= tonumber(ARGV[3]) then
+	return 0
+end
+redis.call('ZADD', KEYS[1], ARGV[4] + ARGV[2], ARGV[1])
+if redis.call('PTTL', KEYS[1]) < tonumber(ARGV[2]) then
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 1
+`)
+
+	// extend renews the lease only while the token still holds it, a mutex is a string and a semaphore a sorted set
+	extend = redis.NewScript(`
+local kind = redis.call('TYPE', KEYS[1])['ok']
+if kind == 'string' then
+	if redis.call('GET', KEYS[1]) ~= ARGV[1] then
+		return 0
+	end
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+elseif kind == 'zset' then
+	local expiry = redis.call('ZSCORE', KEYS[1], ARGV[1])
+	if not expiry or tonumber(expiry) <= tonumber(ARGV[3]) then
+		return 0
+	end
+	redis.call('ZADD', KEYS[1], ARGV[3] + ARGV[2], ARGV[1])
+	if redis.call('PTTL', KEYS[1]) < tonumber(ARGV[2]) then
+		redis.call('PEXPIRE', KEYS[1], ARGV[2])
+	end
+	return 1
+end
+return 0
+`)
+
+	// release deletes the lease only while the token still holds it, so an expired lock taken over by another holder is kept
+	release = redis.NewScript(`
+local kind = redis.call('TYPE', KEYS[1])['ok']
+if kind == 'string' then
+	if redis.call('GET', KEYS[1]) ~= ARGV[1] then
+		return 0
+	end
+	return redis.call('DEL', KEYS[1])
+elseif kind == 'zset' then
+	return redis.call('ZREM', KEYS[1], ARGV[1])
+end
+return 0
+`)
+)
+
+// redisStore holds a mutex in a string set with NX and a ttl, and a semaphore in a sorted set of its leases.
+// Semaphore leases expire on the clock of the replicas, which must stay within a fraction of the ttl of each other.
+type redisStore struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+func (s *redisStore) Acquire(ctx context.Context, key, token string, limit int, ttl time.Duration) (bool, error) {
+	client, err := s.redis()
+	if err != nil {
+		return false, err
+	}
+
+	if limit == 1 {
+		return client.SetNX(ctx, s.prefix+key, token, ttl).Result()
+	}
+
+	ok, err := acquireSemaphore.Run(ctx, client, []string{s.prefix + key}, token, ttl.Milliseconds(), limit, time.Now().UnixMilli()).Int()
+	return ok == 1, err
+}
+
+func (s *redisStore) Extend(ctx context.Context, key, token string, ttl time.Duration) (bool, error) {
+	client, err := s.redis()
+	if err != nil {
+		return false, err
+	}
+
+	ok, err := extend.Run(ctx, client, []string{s.prefix + key}, token, ttl.Milliseconds(), time.Now().UnixMilli()).Int()
+	return ok == 1, err
+}
+
+func (s *redisStore) Release(ctx context.Context, key, token string) (bool, error) {
+	client, err := s.redis()
+	if err != nil {
+		return false, err
+	}
+
+	ok, err := release.Run(ctx, client, []string{s.prefix + key}, token).Int()
+	return ok == 1, err
+}
+
+func (s *redisStore) redis() (redis.UniversalClient, error) {
+	if s.client != nil {
+		return s.client, nil
+	}
+
+	if client := db.KV(); client != nil {
+		return client, nil
+	}
+
+	return nil, fmt.Errorf("lock: redis is not initialized")
+}
+
+// NewRedisStore creates a store on the redis client with keys under the prefix, PREFIX when empty.
+// A nil client uses db.KV() at run time.
+func NewRedisStore(client redis.UniversalClient, prefix string) *redisStore {
+	if prefix == "" {
+		prefix = PREFIX
+	}
+
+	return &redisStore{client, prefix}
+}
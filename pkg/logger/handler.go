@@ -0,0 +1,85 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+)
+
+// state is the configured output, it is swapped as a whole by Init
+type state struct {
+	handler  slog.Handler
+	level    slog.Level
+	levels   map[string]slog.Level
+	redacted []string
+}
+
+var current atomic.Pointer[state]
+
+// handler applies the per package levels and forwards records to the configured output.
+// Loggers may be created before Init, so attributes and groups are replayed on the current output.
+type handler struct {
+	pkg string
+	ops []func(slog.Handler) slog.Handler
+
+	mutex   sync.Mutex
+	base    *state
+	applied slog.Handler
+}
+
+func (h *handler) Enabled(_ context.Context, level slog.Level) bool {
+	s := current.Load()
+	min := s.level
+	if l, ok := s.levels[h.pkg]; ok {
+		min = l
+	}
+
+	return level >= min
+}
+
+func (h *handler) Handle(ctx context.Context, r slog.Record) error {
+	return h.resolve().Handle(ctx, r)
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	pkg := h.pkg
+	for _, attr := range attrs {
+		if attr.Key == PackageKey {
+			pkg = attr.Value.String()
+		}
+	}
+
+	return h.with(pkg, func(next slog.Handler) slog.Handler { return next.WithAttrs(attrs) })
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	return h.with(h.pkg, func(next slog.Handler) slog.Handler { return next.WithGroup(name) })
+}
+
+func (h *handler) with(pkg string, op func(slog.Handler) slog.Handler) *handler {
+	ops := make([]func(slog.Handler) slog.Handler, 0, len(h.ops)+1)
+	ops = append(ops, h.ops...)
+	ops = append(ops, op)
+
+	return &handler{pkg: pkg, ops: ops}
+}
+
+// resolve returns the output with the attributes and groups of the logger applied
+func (h *handler) resolve() slog.Handler {
+	s := current.Load()
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.base != s {
+		applied := s.handler
+		for _, op := range h.ops {
+			applied = op(applied)
+		}
+		h.base = s
+		h.applied = applied
+	}
+
+	return h.applied
+}
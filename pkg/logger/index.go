@@ -0,0 +1,113 @@
+// Package logger provides structured logging based on log/slog.
+// Output is json in production and text otherwise, levels can be set per package.
+package logger
+
+import (
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/QubelyLabs/bedrock/pkg/config"
+)
+
+const (
+	PackageKey = "package"
+	Redacted   = "[REDACTED]"
+)
+
+var (
+	root = &handler{}
+
+	// RedactedKeys are the attribute, header and body keys whose values are never logged, LOG_REDACT adds to them on Init
+	RedactedKeys = []string{"password", "secret", "token", "authorization", "cookie", "api_key", "apikey"}
+)
+
+func init() {
+	current.Store(&state{
+		handler:  slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{ReplaceAttr: redact}),
+		level:    slog.LevelInfo,
+		levels:   map[string]slog.Level{},
+		redacted: RedactedKeys,
+	})
+}
+
+// Init configures the output from the config and makes it the slog and log default.
+// LOG_LEVEL sets the default level, LOG_LEVELS overrides it per package, e.g. "repository=debug,event=warn".
+func Init(cf *config.Config) {
+	// a new list is swapped in with the output, handlers may be reading the current one
+	redacted := append([]string{}, RedactedKeys...)
+	for _, key := range split(cf.LogRedact) {
+		redacted = append(redacted, strings.ToLower(key))
+	}
+
+	options := &slog.HandlerOptions{
+		Level:       slog.LevelDebug, // levels are applied per package by the root handler
+		ReplaceAttr: redact,
+	}
+
+	var output slog.Handler
+	if IsProduction(cf.GoEnv) {
+		output = slog.NewJSONHandler(os.Stdout, options)
+	} else {
+		output = slog.NewTextHandler(os.Stdout, options)
+	}
+
+	levels := map[string]slog.Level{}
+	for _, pair := range split(cf.LogLevels) {
+		pkg, level, ok := strings.Cut(pair, "=")
+		if ok {
+			levels[strings.TrimSpace(pkg)] = ParseLevel(level)
+		}
+	}
+
+	current.Store(&state{output, ParseLevel(cf.LogLevel), levels, redacted})
+	slog.SetDefault(slog.New(root))
+}
+
+// For returns the logger of a package, its level can be configured through LOG_LEVELS
+func For(pkg string) *slog.Logger {
+	return slog.New(root).With(PackageKey, pkg)
+}
+
+// Default returns the logger used when no package is specified
+func Default() *slog.Logger {
+	return slog.New(root)
+}
+
+func IsProduction(env string) bool {
+	env = strings.ToLower(env)
+	return env == "production" || env == "prod"
+}
+
+func ParseLevel(level string) slog.Level {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(strings.TrimSpace(level))); err != nil {
+		return slog.LevelInfo
+	}
+
+	return l
+}
+
+// IsRedacted reports whether the value of a key must not be logged
+func IsRedacted(key string) bool {
+	key = strings.ToLower(key)
+	for _, k := range current.Load().redacted {
+		if strings.Contains(key, k) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func redact(groups []string, attr slog.Attr) slog.Attr {
+	if attr.Value.Kind() != slog.KindGroup && IsRedacted(attr.Key) {
+		return slog.String(attr.Key, Redacted)
+	}
+
+	return attr
+}
+
+func split(value string) []string {
+	return strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ' ' })
+}
@@ -0,0 +1,94 @@
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	MAX_BODY_SIZE = 16 * 1024 // Bodies are truncated to this size in logs
+)
+
+// RedactBody returns a loggable version of a request or response body with redacted values removed.
+// Only json and form bodies are logged, other content types are summarized by their size.
+func RedactBody(contentType string, body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+
+	switch {
+	case strings.Contains(contentType, "json"):
+		var data any
+		if err := json.Unmarshal(body, &data); err != nil {
+			return fmt.Sprintf("[invalid json, %d bytes]", len(body))
+		}
+
+		buf, err := json.Marshal(redactValue(data))
+		if err != nil {
+			return fmt.Sprintf("[unencodable json, %d bytes]", len(body))
+		}
+
+		return truncate(string(buf))
+	case strings.Contains(contentType, "x-www-form-urlencoded"):
+		values, err := url.ParseQuery(string(body))
+		if err != nil {
+			return fmt.Sprintf("[invalid form, %d bytes]", len(body))
+		}
+
+		for key := range values {
+			if IsRedacted(key) {
+				values[key] = []string{Redacted}
+			}
+		}
+
+		return truncate(values.Encode())
+	default:
+		return fmt.Sprintf("[%v, %d bytes]", contentType, len(body))
+	}
+}
+
+// RedactHeaders returns the headers as a flat map with redacted values removed
+func RedactHeaders(headers http.Header) map[string]string {
+	result := map[string]string{}
+	for key := range headers {
+		if IsRedacted(key) {
+			result[key] = Redacted
+			continue
+		}
+
+		result[key] = headers.Get(key)
+	}
+
+	return result
+}
+
+func redactValue(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, item := range v {
+			if IsRedacted(key) {
+				v[key] = Redacted
+				continue
+			}
+
+			v[key] = redactValue(item)
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = redactValue(item)
+		}
+	}
+
+	return value
+}
+
+func truncate(value string) string {
+	if len(value) <= MAX_BODY_SIZE {
+		return value
+	}
+
+	return value[:MAX_BODY_SIZE] + "...[truncated]"
+}
@@ -0,0 +1,104 @@
+package metrics
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"gorm.io/gorm"
+)
+
+const (
+	gormStartKey = "metrics:start"
+)
+
+var (
+	dbCollectors = map[string]prometheus.Collector{}
+	dbMutex      = &sync.Mutex{}
+)
+
+// gormPlugin records the duration of every query
+type gormPlugin struct{}
+
+func (p *gormPlugin) Name() string {
+	return "metrics"
+}
+
+func (p *gormPlugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	for _, err := range []error{
+		cb.Create().Before("gorm:create").Register("metrics:before_create", p.before),
+		cb.Create().After("gorm:create").Register("metrics:after_create", p.after("create")),
+		cb.Query().Before("gorm:query").Register("metrics:before_query", p.before),
+		cb.Query().After("gorm:query").Register("metrics:after_query", p.after("query")),
+		cb.Update().Before("gorm:update").Register("metrics:before_update", p.before),
+		cb.Update().After("gorm:update").Register("metrics:after_update", p.after("update")),
+		cb.Delete().Before("gorm:delete").Register("metrics:before_delete", p.before),
+		cb.Delete().After("gorm:delete").Register("metrics:after_delete", p.after("delete")),
+		cb.Row().Before("gorm:row").Register("metrics:before_row", p.before),
+		cb.Row().After("gorm:row").Register("metrics:after_row", p.after("row")),
+		cb.Raw().Before("gorm:raw").Register("metrics:before_raw", p.before),
+		cb.Raw().After("gorm:raw").Register("metrics:after_raw", p.after("raw")),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *gormPlugin) before(db *gorm.DB) {
+	db.InstanceSet(gormStartKey, time.Now())
+}
+
+func (p *gormPlugin) after(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		value, ok := db.InstanceGet(gormStartKey)
+		if !ok {
+			return
+		}
+
+		start, ok := value.(time.Time)
+		if !ok {
+			return
+		}
+
+		status := "ok"
+		if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+			status = "error"
+		}
+
+		SQLDuration.WithLabelValues(operation, db.Statement.Table, status).Observe(time.Since(start).Seconds())
+	}
+}
+
+// NewGormPlugin creates a gorm plugin recording query durations, install it with db.Use
+func NewGormPlugin() gorm.Plugin {
+	return &gormPlugin{}
+}
+
+// RegisterDB exposes the connection pool stats of a database, name tells pools apart.
+// Registering a name again, e.g. when the database is initialized again, replaces its previous pool.
+func RegisterDB(db *gorm.DB, name string) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+
+	dbMutex.Lock()
+	defer dbMutex.Unlock()
+
+	if previous, ok := dbCollectors[name]; ok {
+		Registry.Unregister(previous)
+	}
+
+	collector := collectors.NewDBStatsCollector(sqlDB, name)
+	if err := Registry.Register(collector); err != nil {
+		return err
+	}
+	dbCollectors[name] = collector
+
+	return nil
+}
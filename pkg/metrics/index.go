@@ -0,0 +1,151 @@
+// Package metrics provides prometheus instrumentation for http handlers, sql queries, the cache,
+// events, jobs and outbound requests, all exposed on a single registry.
+package metrics
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	Namespace = "bedrock"
+)
+
+var (
+	Registry = prometheus.NewRegistry()
+
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "Number of http requests handled, by route and status.",
+	}, []string{"method", "route", "status"})
+
+	HTTPDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "Latency of http requests handled, by route and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	SQLDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Subsystem: "sql",
+		Name:      "query_duration_seconds",
+		Help:      "Latency of sql queries, by operation, table and status.",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+	}, []string{"operation", "table", "status"})
+
+	CacheRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Subsystem: "cache",
+		Name:      "requests_total",
+		Help:      "Number of cache lookups, by result (hit, miss or error).",
+	}, []string{"result"})
+
+	EventQueueDepth = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Subsystem: "event",
+		Name:      "queue_depth",
+		Help:      "Number of events waiting to be dispatched.",
+	})
+
+	EventsHandled = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Subsystem: "event",
+		Name:      "handled_total",
+		Help:      "Number of events handled by listeners, by event, listener and status.",
+	}, []string{"event", "listener", "status"})
+
+	EventDeliveriesInFlight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Subsystem: "event",
+		Name:      "deliveries_in_flight",
+		Help:      "Number of listener deliveries queued, running or waiting for a retry.",
+	})
+
+	EventListenerFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Subsystem: "event",
+		Name:      "listener_failures_total",
+		Help:      "Number of failed listener attempts, by event and listener.",
+	}, []string{"event", "listener"})
+
+	EventListenersAbandoned = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Subsystem: "event",
+		Name:      "listeners_abandoned",
+		Help:      "Number of listener calls still running after their timeout, by listener.",
+	}, []string{"listener"})
+
+	SchedulerRuns = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Subsystem: "scheduler",
+		Name:      "runs_total",
+		Help:      "Number of scheduled job runs, by job and status.",
+	}, []string{"job", "status"})
+
+	SchedulerDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Subsystem: "scheduler",
+		Name:      "run_duration_seconds",
+		Help:      "Duration of scheduled job runs, by job.",
+		Buckets:   prometheus.ExponentialBuckets(0.01, 4, 10),
+	}, []string{"job"})
+
+	QueueJobs = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Subsystem: "queue",
+		Name:      "jobs_total",
+		Help:      "Number of background job attempts, by queue, type and outcome (succeeded, retried, failed or lost).",
+	}, []string{"queue", "type", "status"})
+
+	QueueDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Subsystem: "queue",
+		Name:      "job_duration_seconds",
+		Help:      "Duration of background job attempts, by queue and type.",
+		Buckets:   prometheus.ExponentialBuckets(0.01, 4, 10),
+	}, []string{"queue", "type"})
+
+	ClientDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Subsystem: "http_client",
+		Name:      "request_duration_seconds",
+		Help:      "Latency of outbound http requests, by host, method and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"host", "method", "status"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequests,
+		HTTPDuration,
+		SQLDuration,
+		CacheRequests,
+		EventQueueDepth,
+		EventsHandled,
+		EventDeliveriesInFlight,
+		EventListenerFailures,
+		EventListenersAbandoned,
+		SchedulerRuns,
+		SchedulerDuration,
+		QueueJobs,
+		QueueDuration,
+		ClientDuration,
+	)
+}
+
+// Handler serves the registry in the prometheus exposition format, mount it on /metrics
+func Handler() gin.HandlerFunc {
+	handler := promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+
+	return func(c *gin.Context) {
+		handler.ServeHTTP(c.Writer, c.Request)
+	}
+}
@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/QubelyLabs/bedrock/pkg/injection"
+	"github.com/QubelyLabs/bedrock/pkg/services/pricing"
+	"github.com/gin-gonic/gin"
+)
+
+// RequireFeature blocks requests with 402 when the plan of the workspace does not include the feature
+func RequireFeature(client pricing.Client, feature string) gin.HandlerFunc {
+	return entitlement(client, func(e *pricing.Entitlements) (string, bool) {
+		return "Your plan does not include this feature, upgrade to continue", e.HasFeature(feature)
+	})
+}
+
+// RequireQuota blocks requests with 402 when the workspace has used up its limit for the metric
+func RequireQuota(client pricing.Client, metric string) gin.HandlerFunc {
+	return entitlement(client, func(e *pricing.Entitlements) (string, bool) {
+		return "Your plan limit has been reached, upgrade to continue", !e.OverQuota(metric)
+	})
+}
+
+func entitlement(client pricing.Client, allow func(*pricing.Entitlements) (string, bool)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		workspace, _ := injection.LookupWorkspace(c)
+		workspaceId, ok := workspace["id"].(string)
+		if !ok || workspaceId == "" {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"status":  false,
+				"message": "workspace not found",
+			})
+			return
+		}
+
+		entitlements, err := client.Entitlements(workspaceId)
+		if err != nil {
+			injection.GetLogger(c).Error("cannot retrieve entitlements", "workspace_id", workspaceId, "error", err)
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{
+				"status":  false,
+				"message": "Unable to verify your plan, try again in a bit",
+			})
+			return
+		}
+
+		if message, ok := allow(entitlements); !ok {
+			c.AbortWithStatusJSON(http.StatusPaymentRequired, gin.H{
+				"status":  false,
+				"message": message,
+			})
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"github.com/QubelyLabs/bedrock/pkg/injection"
+	"github.com/QubelyLabs/bedrock/pkg/integration"
 	"github.com/QubelyLabs/bedrock/pkg/util"
 	"github.com/gin-gonic/gin"
 )
@@ -18,6 +19,8 @@ func Injection() gin.HandlerFunc {
 			injection.SetWorkspace(c, util.FromBase64(workspace))
 		}
 
+		integration.SentryScope(c)
+
 		c.Next()
 	}
 }
@@ -0,0 +1,115 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/QubelyLabs/bedrock/pkg/config"
+	"github.com/QubelyLabs/bedrock/pkg/injection"
+	"github.com/QubelyLabs/bedrock/pkg/logger"
+	"github.com/gin-gonic/gin"
+)
+
+// Logger injects a request scoped logger carrying the request id, user, workspace and route.
+// It should be registered after the RequestID and Injection middlewares.
+func Logger() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		attrs := []any{
+			"request_id", injection.GetRequestID(c),
+			"method", c.Request.Method,
+			"route", c.FullPath(),
+		}
+
+		if user, ok := injection.LookupUser(c); ok {
+			attrs = append(attrs, "user_id", user["id"])
+		}
+
+		if workspace, ok := injection.LookupWorkspace(c); ok {
+			attrs = append(attrs, "workspace_id", workspace["id"])
+		}
+
+		injection.SetLogger(c, logger.Default().With(attrs...))
+
+		c.Next()
+	}
+}
+
+// bodyWriter keeps a copy of the response body for the access log
+type bodyWriter struct {
+	gin.ResponseWriter
+	body *bytes.Buffer
+}
+
+func (w *bodyWriter) Write(b []byte) (int, error) {
+	if w.body.Len() < logger.MAX_BODY_SIZE {
+		w.body.Write(b)
+	}
+
+	return w.ResponseWriter.Write(b)
+}
+
+// AccessLog logs every request while TRAFFIC_LOG_SWITCH is on,
+// request and response bodies are included with redacted values removed when TRAFFIC_LOG_BODY is set
+func AccessLog(cf *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !config.Enabled(cf.TrafficLog) {
+			c.Next()
+			return
+		}
+
+		start := time.Now()
+
+		var requestBody []byte
+		var writer *bodyWriter
+		if cf.TrafficLogBody {
+			if c.Request.Body != nil {
+				requestBody, _ = io.ReadAll(io.LimitReader(c.Request.Body, logger.MAX_BODY_SIZE+1))
+				c.Request.Body = readCloser{io.MultiReader(bytes.NewReader(requestBody), c.Request.Body), c.Request.Body}
+			}
+
+			writer = &bodyWriter{c.Writer, &bytes.Buffer{}}
+			c.Writer = writer
+		}
+
+		c.Next()
+
+		status := c.Writer.Status()
+		attrs := []any{
+			"status", status,
+			"path", c.Request.URL.Path,
+			"latency_ms", time.Since(start).Milliseconds(),
+			"size", c.Writer.Size(),
+			"client_ip", c.ClientIP(),
+			"user_agent", c.Request.UserAgent(),
+		}
+
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, "errors", c.Errors.String())
+		}
+
+		if writer != nil {
+			attrs = append(attrs,
+				"request_headers", logger.RedactHeaders(c.Request.Header),
+				"request_body", logger.RedactBody(c.ContentType(), requestBody),
+				"response_body", logger.RedactBody(c.Writer.Header().Get("Content-Type"), writer.body.Bytes()),
+			)
+		}
+
+		level := slog.LevelInfo
+		switch {
+		case status >= 500:
+			level = slog.LevelError
+		case status >= 400:
+			level = slog.LevelWarn
+		}
+
+		injection.GetLogger(c).With(logger.PackageKey, "access").Log(c.Request.Context(), level, "request completed", attrs...)
+	}
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"github.com/QubelyLabs/bedrock/pkg/injection"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	RequestIDHeader = "X-Request-ID"
+)
+
+// RequestID reuses the request id sent by the caller or generates one,
+// and echoes it in the response so it can be correlated with logs and error reports
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if id == "" || len(id) > 128 {
+			id = uuid.New().String()
+		}
+
+		injection.SetRequestID(c, id)
+		c.Header(RequestIDHeader, id)
+
+		c.Next()
+	}
+}
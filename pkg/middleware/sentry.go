@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/QubelyLabs/bedrock/pkg/injection"
+	sentrygin "github.com/getsentry/sentry-go/gin"
+	"github.com/gin-gonic/gin"
+)
+
+// Sentry starts a sentry transaction per route and reports panics.
+// Recovered panics are answered with a 500, so it should be registered before handlers that may panic.
+func Sentry() gin.HandlerFunc {
+	handler := sentrygin.New(sentrygin.Options{
+		Repanic: true,
+		Timeout: 2 * time.Second,
+	})
+
+	return func(c *gin.Context) {
+		defer func() {
+			if err := recover(); err != nil {
+				injection.GetLogger(c).Error("recovered from panic", "panic", fmt.Sprint(err))
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+					"status":  false,
+					"message": "Something went wrong, try again in a bit",
+				})
+			}
+		}()
+
+		handler(c)
+	}
+}
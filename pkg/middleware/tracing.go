@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"github.com/QubelyLabs/bedrock/pkg/injection"
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tracing starts a server span per request, continuing the trace of the caller when a traceparent header is sent
+func Tracing(serviceName string) gin.HandlerFunc {
+	return otelgin.Middleware(serviceName)
+}
+
+// TraceAttributes adds the request id, user and workspace to the server span,
+// it should be registered after the Tracing, RequestID and Injection middlewares
+func TraceAttributes() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		span := trace.SpanFromContext(c.Request.Context())
+		span.SetAttributes(attribute.String("request.id", injection.GetRequestID(c)))
+
+		if user, ok := injection.LookupUser(c); ok {
+			if id, ok := user["id"].(string); ok {
+				span.SetAttributes(attribute.String("enduser.id", id))
+			}
+		}
+
+		if workspace, ok := injection.LookupWorkspace(c); ok {
+			if id, ok := workspace["id"].(string); ok {
+				span.SetAttributes(attribute.String("workspace.id", id))
+			}
+		}
+
+		c.Next()
+	}
+}
@@ -1,61 +1,66 @@
 package middleware
 
 import (
-	"encoding/json"
-	"log"
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
 
 	"github.com/QubelyLabs/bedrock/pkg/injection"
+	"github.com/QubelyLabs/bedrock/pkg/uow"
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
+var errRollback = errors.New("transaction rolled back")
+
+// Transaction wraps the request in a transaction, committed when the handlers answer with a 2xx status and no errors.
+// The transaction is a unit of work in the request context, so uow.AfterCommit and nested uow.WithTransaction work in handlers.
 func Transaction(d *gorm.DB) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		tx := d.Begin()
-		if tx.Error != nil {
-			log.Print(tx.Error)
-			c.AbortWithStatus(http.StatusInternalServerError)
-			return
-		}
+	return transaction(d, &uow.Options{Retries: -1})
+}
 
-		injection.SetSQL(c, tx)
+// ReadOnlyTransaction wraps the request in a read only transaction, e.g. for GET routes reading a consistent snapshot
+func ReadOnlyTransaction(d *gorm.DB) gin.HandlerFunc {
+	return transaction(d, &uow.Options{ReadOnly: true, Retries: -1})
+}
 
+func transaction(d *gorm.DB, options *uow.Options) gin.HandlerFunc {
+	return func(c *gin.Context) {
 		defer func() {
 			if err := recover(); err != nil {
-				encodedError, marshalError := json.Marshal(err)
-				if marshalError != nil {
-					encodedError = []byte("Unable to marshall error")
-				}
-
-				log.Print(encodedError)
-
-				err := tx.Rollback().Error
-				if err != nil {
-					log.Print("Cannot rollback transaction", err)
-					return
-				}
-			} else if len(c.Errors) > 0 {
-				err := tx.Rollback().Error
-				if err != nil {
-					log.Print("Cannot rollback transaction", err)
-					return
-				}
-			} else if c.Writer.Status() < 200 || c.Writer.Status() >= 300 {
-				err := tx.Rollback().Error
-				if err != nil {
-					log.Print("Cannot rollback transaction", err)
-					return
-				}
-			} else {
-				err := tx.Commit().Error
-				if err != nil {
-					log.Print(err, "Cannot commit transaction")
-					return
-				}
+				injection.GetLogger(c).Error("panic in transaction, rolled back", "panic", fmt.Sprint(err))
+
+				// let the sentry and recovery middlewares report and answer the panic
+				panic(err)
 			}
 		}()
 
-		c.Next()
+		// the transaction context finds the request, e.g. to envelop the events written to the outbox in tx
+		ctx := context.WithValue(c.Request.Context(), gin.ContextKey, c)
+
+		began := false
+		err := uow.NewUnitOfWork(d).WithTransaction(ctx, func(tx *gorm.DB) error {
+			began = true
+			injection.SetSQL(c, tx)
+			c.Request = c.Request.WithContext(tx.Statement.Context)
+
+			c.Next()
+
+			if len(c.Errors) > 0 || c.Writer.Status() < 200 || c.Writer.Status() >= 300 {
+				return errRollback
+			}
+
+			return nil
+		}, options)
+
+		switch {
+		case err == nil || errors.Is(err, errRollback):
+		case !began:
+			injection.GetLogger(c).Error("cannot begin transaction", "error", err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+		default:
+			injection.GetLogger(c).Error("cannot commit transaction", "error", err)
+		}
 	}
 }
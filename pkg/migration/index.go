@@ -0,0 +1,407 @@
+// Package migration applies versioned schema migrations written in go or sql.
+// Applied migrations are recorded with a checksum in the schema_migrations table, and an advisory lock
+// makes sure only one replica migrates at a time.
+package migration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/QubelyLabs/bedrock/pkg/config"
+	"github.com/QubelyLabs/bedrock/pkg/db"
+	"github.com/QubelyLabs/bedrock/pkg/logger"
+	"gorm.io/gorm"
+)
+
+const (
+	TABLE     = "schema_migrations"
+	LOCK_NAME = "bedrock:migrations"
+)
+
+var (
+	Default = NewMigrator(nil)
+
+	ErrChecksumMismatch = errors.New("migration: applied migration was modified")
+	ErrNoDown           = errors.New("migration: migration cannot be reverted")
+)
+
+// Migration is a versioned schema change, versions are applied in lexical order,
+// e.g. a timestamp like 20240131120000
+type Migration struct {
+	Version string
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+
+	// Checksum detects edits of applied migrations, it is computed for sql migrations and empty for go ones
+	Checksum string
+
+	// NoTransaction runs the migration outside a transaction, e.g. for CREATE INDEX CONCURRENTLY on postgres
+	NoTransaction bool
+
+	sql string
+}
+
+// Record is a row of the migrations table
+type Record struct {
+	Version   string `gorm:"primaryKey;size:64"`
+	Name      string `gorm:"size:255"`
+	Checksum  string `gorm:"size:64"`
+	AppliedAt time.Time
+	Duration  int64
+}
+
+func (Record) TableName() string {
+	return TABLE
+}
+
+// Status is a migration and whether it was applied
+type Status struct {
+	Version   string     `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"applied_at"`
+}
+
+type migrator struct {
+	db *gorm.DB
+
+	mutex      sync.Mutex
+	migrations map[string]*Migration
+	entities   []any
+	dryRun     bool
+}
+
+// Add registers migrations, a version can only be registered once
+func (m *migrator) Add(migrations ...*Migration) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, migration := range migrations {
+		if migration.Version == "" {
+			return fmt.Errorf("migration: %v has no version", migration.Name)
+		}
+
+		if migration.Up == nil {
+			return fmt.Errorf("migration: %v %v has no up", migration.Version, migration.Name)
+		}
+
+		if _, ok := m.migrations[migration.Version]; ok {
+			return fmt.Errorf("migration: version %v is registered twice", migration.Version)
+		}
+
+		m.migrations[migration.Version] = migration
+	}
+
+	return nil
+}
+
+// Register adds a go migration, it panics on a duplicate version so it can be called from init
+func (m *migrator) Register(version, name string, up, down func(tx *gorm.DB) error) {
+	if err := m.Add(&Migration{Version: version, Name: name, Up: up, Down: down}); err != nil {
+		panic(err)
+	}
+}
+
+// Entities registers the entities auto migrated by Run when DB_SYNC is set
+func (m *migrator) Entities(entities ...any) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.entities = append(m.entities, entities...)
+}
+
+// SetDryRun makes Up and Down log the migrations they would run instead of running them
+func (m *migrator) SetDryRun(v bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.dryRun = v
+}
+
+// Run applies the pending migrations, then auto migrates the registered entities when DB_SYNC is set.
+// DB_MIGRATION_DRY_RUN only logs what would run.
+func (m *migrator) Run(ctx context.Context, cf *config.Config) error {
+	if cf.DBMigrationDryRun {
+		m.SetDryRun(true)
+	}
+
+	if _, err := m.Up(ctx); err != nil {
+		return err
+	}
+
+	if !cf.DBSync {
+		return nil
+	}
+
+	m.mutex.Lock()
+	entities := m.entities
+	dryRun := m.dryRun
+	m.mutex.Unlock()
+
+	if len(entities) == 0 {
+		return nil
+	}
+
+	if dryRun {
+		logger.For("migration").Info("dry run, would auto migrate entities", "count", len(entities))
+		return nil
+	}
+
+	return m.withLock(ctx, func(tx *gorm.DB) error {
+		return tx.AutoMigrate(entities...)
+	})
+}
+
+// Up applies the pending migrations in version order and returns them
+func (m *migrator) Up(ctx context.Context) ([]*Migration, error) {
+	migrations, dryRun := m.sorted()
+	if len(migrations) == 0 {
+		return nil, nil
+	}
+
+	var applied []*Migration
+	err := m.withLock(ctx, func(tx *gorm.DB) error {
+		records, err := m.records(tx)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range migrations {
+			record, ok := records[migration.Version]
+			if ok {
+				if record.Checksum != "" && migration.Checksum != "" && record.Checksum != migration.Checksum {
+					return fmt.Errorf("%w: %v %v", ErrChecksumMismatch, migration.Version, migration.Name)
+				}
+				continue
+			}
+
+			if dryRun {
+				logger.For("migration").Info("dry run, would apply migration", "version", migration.Version, "name", migration.Name, "statements", split(migration.sql, tx.Dialector.Name()))
+				applied = append(applied, migration)
+				continue
+			}
+
+			if err := m.apply(tx, migration); err != nil {
+				return err
+			}
+			applied = append(applied, migration)
+		}
+
+		return nil
+	})
+
+	return applied, err
+}
+
+// Down reverts the last steps applied migrations in reverse version order and returns them
+func (m *migrator) Down(ctx context.Context, steps int) ([]*Migration, error) {
+	migrations, dryRun := m.sorted()
+
+	var reverted []*Migration
+	err := m.withLock(ctx, func(tx *gorm.DB) error {
+		records, err := m.records(tx)
+		if err != nil {
+			return err
+		}
+
+		for i := len(migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+			migration := migrations[i]
+			if _, ok := records[migration.Version]; !ok {
+				continue
+			}
+
+			if migration.Down == nil {
+				return fmt.Errorf("%w: %v %v", ErrNoDown, migration.Version, migration.Name)
+			}
+
+			if dryRun {
+				logger.For("migration").Info("dry run, would revert migration", "version", migration.Version, "name", migration.Name)
+				reverted = append(reverted, migration)
+				continue
+			}
+
+			if err := m.revert(tx, migration); err != nil {
+				return err
+			}
+			reverted = append(reverted, migration)
+		}
+
+		return nil
+	})
+
+	return reverted, err
+}
+
+// Status lists the registered migrations and when they were applied
+func (m *migrator) Status(ctx context.Context) ([]Status, error) {
+	migrations, _ := m.sorted()
+
+	records, err := m.records(m.session(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(migrations))
+	for _, migration := range migrations {
+		status := Status{Version: migration.Version, Name: migration.Name}
+		if record, ok := records[migration.Version]; ok {
+			status.AppliedAt = &record.AppliedAt
+		}
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+func (m *migrator) apply(tx *gorm.DB, migration *Migration) error {
+	start := time.Now()
+
+	err := m.transaction(tx, migration, func(tx *gorm.DB) error {
+		if err := migration.Up(tx); err != nil {
+			return err
+		}
+
+		return tx.Create(&Record{
+			Version:   migration.Version,
+			Name:      migration.Name,
+			Checksum:  migration.Checksum,
+			AppliedAt: time.Now(),
+			Duration:  time.Since(start).Milliseconds(),
+		}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("migration: cannot apply %v %v: %w", migration.Version, migration.Name, err)
+	}
+
+	logger.For("migration").Info("applied migration", "version", migration.Version, "name", migration.Name, "duration", time.Since(start))
+	return nil
+}
+
+func (m *migrator) revert(tx *gorm.DB, migration *Migration) error {
+	err := m.transaction(tx, migration, func(tx *gorm.DB) error {
+		if err := migration.Down(tx); err != nil {
+			return err
+		}
+
+		return tx.Delete(&Record{Version: migration.Version}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("migration: cannot revert %v %v: %w", migration.Version, migration.Name, err)
+	}
+
+	logger.For("migration").Info("reverted migration", "version", migration.Version, "name", migration.Name)
+	return nil
+}
+
+func (m *migrator) transaction(tx *gorm.DB, migration *Migration, fn func(tx *gorm.DB) error) error {
+	if migration.NoTransaction {
+		return fn(tx)
+	}
+
+	return tx.Transaction(fn)
+}
+
+// withLock runs fn holding the advisory lock, dry runs do not lock
+func (m *migrator) withLock(ctx context.Context, fn func(tx *gorm.DB) error) error {
+	tx := m.session(ctx)
+
+	m.mutex.Lock()
+	dryRun := m.dryRun
+	m.mutex.Unlock()
+
+	if dryRun {
+		return fn(tx)
+	}
+
+	unlock, err := lock(ctx, tx, LOCK_NAME)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := tx.AutoMigrate(&Record{}); err != nil {
+		return err
+	}
+
+	return fn(tx)
+}
+
+// records returns the applied migrations by version
+func (m *migrator) records(tx *gorm.DB) (map[string]Record, error) {
+	records := map[string]Record{}
+	if !tx.Migrator().HasTable(&Record{}) {
+		return records, nil
+	}
+
+	var rows []Record
+	if err := tx.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		records[row.Version] = row
+	}
+
+	return records, nil
+}
+
+func (m *migrator) sorted() ([]*Migration, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	migrations := make([]*Migration, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		migrations = append(migrations, migration)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, m.dryRun
+}
+
+// session returns the database pinned to the primary, so reads of the migrations table never hit a lagging replica
+func (m *migrator) session(ctx context.Context) *gorm.DB {
+	d := m.db
+	if d == nil {
+		d = db.SQL()
+	}
+
+	return db.Primary(d.WithContext(ctx)).Session(&gorm.Session{})
+}
+
+// NewMigrator creates a migrator for the database, nil uses db.SQL() at run time
+func NewMigrator(d *gorm.DB) *migrator {
+	return &migrator{db: d, migrations: map[string]*Migration{}}
+}
+
+// Add registers migrations on the default migrator
+func Add(migrations ...*Migration) error {
+	return Default.Add(migrations...)
+}
+
+// Register adds a go migration to the default migrator
+func Register(version, name string, up, down func(tx *gorm.DB) error) {
+	Default.Register(version, name, up, down)
+}
+
+// LoadFS adds the sql migrations of a directory to the default migrator
+func LoadFS(fsys fs.FS, dir string) error {
+	return Default.LoadFS(fsys, dir)
+}
+
+// Entities registers entities auto migrated by the default migrator when DB_SYNC is set
+func Entities(entities ...any) {
+	Default.Entities(entities...)
+}
+
+// Run applies the pending migrations of the default migrator
+func Run(ctx context.Context, cf *config.Config) error {
+	return Default.Run(ctx, cf)
+}
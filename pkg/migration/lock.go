@@ -0,0 +1,82 @@
+package migration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"time"
+
+	"github.com/QubelyLabs/bedrock/pkg/db"
+	"github.com/QubelyLabs/bedrock/pkg/logger"
+	"gorm.io/gorm"
+)
+
+const (
+	LOCK_TIMEOUT = 10 * time.Minute
+)
+
+var (
+	ErrLockTimeout = errors.New("migration: timed out waiting for the migration lock")
+)
+
+// lock takes a session level advisory lock on a dedicated connection, so other replicas wait while one migrates.
+// Sqlite has a single writer anyway, so it is not locked.
+func lock(ctx context.Context, tx *gorm.DB, name string) (func(), error) {
+	dialect := tx.Dialector.Name()
+	if dialect == db.SQLite {
+		return func() {}, nil
+	}
+
+	sqlDB, err := tx.DB()
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var unlock string
+	var key any
+	switch dialect {
+	case db.Postgres:
+		hash := fnv.New64a()
+		hash.Write([]byte(name))
+		key = int64(hash.Sum64())
+
+		if _, err = conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", key); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("migration: cannot lock: %w", err)
+		}
+		unlock = "SELECT pg_advisory_unlock($1)"
+	default:
+		key = name
+
+		timeout := LOCK_TIMEOUT
+		if deadline, ok := ctx.Deadline(); ok {
+			timeout = time.Until(deadline)
+		}
+
+		var acquired *int
+		err = conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", key, int(timeout.Seconds())).Scan(&acquired)
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("migration: cannot lock: %w", err)
+		}
+
+		if acquired == nil || *acquired != 1 {
+			conn.Close()
+			return nil, ErrLockTimeout
+		}
+		unlock = "SELECT RELEASE_LOCK(?)"
+	}
+
+	return func() {
+		if _, err := conn.ExecContext(context.Background(), unlock, key); err != nil {
+			logger.For("migration").Error("cannot release the migration lock", "error", err)
+		}
+		conn.Close()
+	}, nil
+}
@@ -0,0 +1,234 @@
+package migration
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/QubelyLabs/bedrock/pkg/db"
+	"gorm.io/gorm"
+)
+
+const (
+	UP_SUFFIX      = ".up.sql"
+	DOWN_SUFFIX    = ".down.sql"
+	NO_TRANSACTION = "-- migration:no-transaction"
+)
+
+// LoadFS adds the sql migrations of a directory, e.g. an embed.FS.
+// Files are named <version>_<name>.up.sql and <version>_<name>.down.sql, the down file is optional.
+// Statements are separated by semicolons, a first line of "-- migration:no-transaction" runs the file outside a transaction.
+func (m *migrator) LoadFS(fsys fs.FS, dir string) error {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return err
+	}
+
+	migrations := map[string]*Migration{}
+	downs := map[string]string{}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".sql") {
+			continue
+		}
+
+		var base string
+		switch {
+		case strings.HasSuffix(name, UP_SUFFIX):
+			base = strings.TrimSuffix(name, UP_SUFFIX)
+		case strings.HasSuffix(name, DOWN_SUFFIX):
+			base = strings.TrimSuffix(name, DOWN_SUFFIX)
+		default:
+			return fmt.Errorf("migration: %v is neither an up nor a down migration", name)
+		}
+
+		version, title, ok := strings.Cut(base, "_")
+		if !ok || version == "" {
+			return fmt.Errorf("migration: %v is not named <version>_<name>", name)
+		}
+
+		content, err := fs.ReadFile(fsys, path.Join(dir, name))
+		if err != nil {
+			return err
+		}
+
+		if strings.HasSuffix(name, DOWN_SUFFIX) {
+			downs[version] = string(content)
+			continue
+		}
+
+		sum := sha256.Sum256(content)
+		migrations[version] = &Migration{
+			Version:       version,
+			Name:          strings.ReplaceAll(title, "_", " "),
+			Up:            exec(string(content)),
+			Checksum:      hex.EncodeToString(sum[:]),
+			NoTransaction: strings.HasPrefix(strings.TrimSpace(string(content)), NO_TRANSACTION),
+			sql:           string(content),
+		}
+	}
+
+	for version, content := range downs {
+		migration, ok := migrations[version]
+		if !ok {
+			return fmt.Errorf("migration: down migration %v has no up migration", version)
+		}
+		migration.Down = exec(content)
+	}
+
+	for _, migration := range migrations {
+		if err := m.Add(migration); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// exec runs the statements of the file, split by the quoting rules of the dialect of tx
+func exec(content string) func(tx *gorm.DB) error {
+	return func(tx *gorm.DB) error {
+		for _, statement := range split(content, tx.Dialector.Name()) {
+			if err := tx.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+}
+
+// split separates sql statements on semicolons outside of quotes, comments and postgres dollar quoted bodies.
+// A backslash escapes the next character in the strings of mysql and the E'...' strings of postgres only.
+func split(content, dialect string) []string {
+	var statements []string
+	var current strings.Builder
+
+	flush := func() {
+		statement := strings.TrimSpace(current.String())
+		if statement != "" && !onlyComments(statement) {
+			statements = append(statements, statement)
+		}
+		current.Reset()
+	}
+
+	for i := 0; i < len(content); i++ {
+		ch := content[i]
+
+		switch {
+		case ch == '-' && strings.HasPrefix(content[i:], "--"):
+			end := strings.IndexByte(content[i:], '\n')
+			if end < 0 {
+				end = len(content) - i
+			}
+			current.WriteString(content[i : i+end])
+			i += end - 1
+		case ch == '/' && strings.HasPrefix(content[i:], "/*"):
+			end := strings.Index(content[i+2:], "*/")
+			if end < 0 {
+				end = len(content) - i - 2
+			} else {
+				end += 2
+			}
+			current.WriteString(content[i : i+2+end])
+			i += 2 + end - 1
+		case ch == '\'' || ch == '"' || ch == '`':
+			end := i + 1
+			escapes := ch != '`' && (dialect == db.MySQL || dialect == db.Postgres && ch == '\'' && escapeString(content, i))
+			for end < len(content) {
+				if content[end] == '\\' && escapes {
+					end += 2
+					continue
+				}
+				if content[end] == ch {
+					break
+				}
+				end++
+			}
+			if end >= len(content) {
+				end = len(content) - 1
+			}
+			current.WriteString(content[i : end+1])
+			i = end
+		case ch == '$':
+			tag := dollarTag(content[i:])
+			if tag == "" {
+				current.WriteByte(ch)
+				continue
+			}
+			end := strings.Index(content[i+len(tag):], tag)
+			if end < 0 {
+				end = len(content) - i - len(tag)
+			} else {
+				end += len(tag)
+			}
+			current.WriteString(content[i : i+len(tag)+end])
+			i += len(tag) + end - 1
+		case ch == ';':
+			flush()
+		default:
+			current.WriteByte(ch)
+		}
+	}
+	flush()
+
+	return statements
+}
+
+// dollarTag returns the $tag$ opening a postgres dollar quoted string, or an empty string
+func dollarTag(s string) string {
+	for i := 1; i < len(s); i++ {
+		switch {
+		case s[i] == '$':
+			return s[:i+1]
+		case s[i] == '_' || s[i] >= 'a' && s[i] <= 'z' || s[i] >= 'A' && s[i] <= 'Z' || i > 1 && s[i] >= '0' && s[i] <= '9':
+		default:
+			return ""
+		}
+	}
+
+	return ""
+}
+
+// escapeString reports whether the quote at i opens a postgres E'...' string
+func escapeString(content string, i int) bool {
+	if i == 0 || content[i-1] != 'E' && content[i-1] != 'e' {
+		return false
+	}
+
+	// the E is a prefix, not the end of an identifier like name'
+	if i == 1 {
+		return true
+	}
+	prev := content[i-2]
+	return !(prev == '_' || prev >= 'a' && prev <= 'z' || prev >= 'A' && prev <= 'Z' || prev >= '0' && prev <= '9')
+}
+
+// onlyComments reports whether the statement holds nothing but -- and /* */ comments
+func onlyComments(statement string) bool {
+	for {
+		statement = strings.TrimSpace(statement)
+		switch {
+		case statement == "":
+			return true
+		case strings.HasPrefix(statement, "--"):
+			end := strings.IndexByte(statement, '\n')
+			if end < 0 {
+				return true
+			}
+			statement = statement[end+1:]
+		case strings.HasPrefix(statement, "/*"):
+			end := strings.Index(statement[2:], "*/")
+			if end < 0 {
+				return true
+			}
+			statement = statement[2+end+2:]
+		default:
+			return false
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package migration
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/QubelyLabs/bedrock/pkg/db"
+)
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		name    string
+		dialect string
+		content string
+		want    []string
+	}{
+		{"statements", db.Postgres, "CREATE TABLE a (id int);\nCREATE TABLE b (id int);\n", []string{"CREATE TABLE a (id int)", "CREATE TABLE b (id int)"}},
+		{"no trailing semicolon", db.SQLite, "SELECT 1;\nSELECT 2", []string{"SELECT 1", "SELECT 2"}},
+		{"semicolon in a string", db.SQLite, "INSERT INTO t VALUES ('a;b');", []string{"INSERT INTO t VALUES ('a;b')"}},
+		{"doubled quote", db.Postgres, "INSERT INTO t VALUES ('it''s;');SELECT 1;", []string{"INSERT INTO t VALUES ('it''s;')", "SELECT 1"}},
+		{"semicolon in an identifier", db.Postgres, `SELECT "a;b" FROM t;`, []string{`SELECT "a;b" FROM t`}},
+		{"semicolon in a backtick identifier", db.MySQL, "SELECT `a;b` FROM t;", []string{"SELECT `a;b` FROM t"}},
+		{"postgres backslash is literal", db.Postgres, "INSERT INTO t VALUES ('C:\\');\nSELECT 1;", []string{"INSERT INTO t VALUES ('C:\\')", "SELECT 1"}},
+		{"sqlite backslash is literal", db.SQLite, "INSERT INTO t VALUES ('C:\\');\nSELECT 1;", []string{"INSERT INTO t VALUES ('C:\\')", "SELECT 1"}},
+		{"mysql backslash escapes", db.MySQL, "INSERT INTO t VALUES ('a\\';b');\nSELECT 1;", []string{"INSERT INTO t VALUES ('a\\';b')", "SELECT 1"}},
+		{"postgres escape string", db.Postgres, "INSERT INTO t VALUES (E'a\\';b');\nSELECT 1;", []string{"INSERT INTO t VALUES (E'a\\';b')", "SELECT 1"}},
+		{"identifier ending in e", db.Postgres, "SELECT name'C:\\';SELECT 1;", []string{"SELECT name'C:\\'", "SELECT 1"}},
+		{"line comment", db.Postgres, "-- a; comment\nSELECT 1; -- trailing;\n", []string{"-- a; comment\nSELECT 1"}},
+		{"block comment", db.MySQL, "/* a; comment */ SELECT 1;", []string{"/* a; comment */ SELECT 1"}},
+		{"block comment after the last statement", db.MySQL, "SELECT 1;\n/* the end; */\n", []string{"SELECT 1"}},
+		{"comments only", db.MySQL, "-- one\n/* two */\n-- three", nil},
+		{"dollar quoted body", db.Postgres, "CREATE FUNCTION f() RETURNS int AS $$ BEGIN RETURN 1; END; $$ LANGUAGE plpgsql;\nSELECT 1;", []string{"CREATE FUNCTION f() RETURNS int AS $$ BEGIN RETURN 1; END; $$ LANGUAGE plpgsql", "SELECT 1"}},
+		{"tagged dollar quoted body", db.Postgres, "DO $body$ BEGIN PERFORM 1; END $body$;\nSELECT 1;", []string{"DO $body$ BEGIN PERFORM 1; END $body$", "SELECT 1"}},
+		{"parameters", db.Postgres, "PREPARE p AS SELECT $1, $2;\nSELECT 1;", []string{"PREPARE p AS SELECT $1, $2", "SELECT 1"}},
+		{"empty", db.Postgres, "  \n;;\n", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := split(tt.content, tt.dialect); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("split(%q, %v) = %q, want %q", tt.content, tt.dialect, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDollarTag(t *testing.T) {
+	tests := []struct {
+		s    string
+		want string
+	}{
+		{"$$ body $$", "$$"},
+		{"$body$ x $body$", "$body$"},
+		{"$_tag1$", "$_tag1$"},
+		{"$1", ""},
+		{"$1$", ""},
+		{"$a1, $2", ""},
+		{"$tag", ""},
+		{"$", ""},
+		{"$a-b$", ""},
+	}
+
+	for _, tt := range tests {
+		if got := dollarTag(tt.s); got != tt.want {
+			t.Errorf("dollarTag(%q) = %q, want %q", tt.s, got, tt.want)
+		}
+	}
+}
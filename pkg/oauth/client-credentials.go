@@ -0,0 +1,242 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/QubelyLabs/bedrock/pkg/util"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	REFRESH_BEFORE = 60 * time.Second // Refresh tokens this long before they expire
+	FETCH_TIMEOUT  = 10 * time.Second
+	KEY_PREFIX     = "oauth:token:"
+)
+
+// Token represents an access token issued by the authorization server
+type Token struct {
+	AccessToken string    `json:"access_token"`
+	TokenType   string    `json:"token_type"`
+	ExpiresIn   int       `json:"expires_in"`
+	Expiry      time.Time `json:"expiry"`
+}
+
+// Valid reports whether the token is usable for at least the given duration
+func (t *Token) Valid(within time.Duration) bool {
+	return t != nil && t.AccessToken != "" && time.Now().Add(within).Before(t.Expiry)
+}
+
+// clientCredentials is a token source for the OAuth2 client credentials grant.
+// Tokens are cached in memory and, when a redis client is provided, shared across replicas.
+type clientCredentials struct {
+	tokenUrl     string
+	clientId     string
+	clientSecret string
+	scopes       []string
+	client       *http.Client
+	kv           redis.UniversalClient
+
+	mutex      sync.RWMutex
+	fetch      sync.Mutex
+	token      *Token
+	refreshing bool
+}
+
+// Token returns a valid access token, fetching a new one if the cached token has expired.
+// When the cached token is about to expire, it is returned and a refresh is started in the background.
+func (s *clientCredentials) Token(ctx context.Context) (string, error) {
+	s.mutex.RLock()
+	token := s.token
+	s.mutex.RUnlock()
+
+	if token.Valid(REFRESH_BEFORE) {
+		return token.AccessToken, nil
+	}
+
+	if token.Valid(0) {
+		s.refreshInBackground()
+		return token.AccessToken, nil
+	}
+
+	token, err := s.refresh(ctx, false)
+	if err != nil {
+		return "", err
+	}
+
+	return token.AccessToken, nil
+}
+
+// Invalidate drops the cached token, forcing the next call to Token to fetch a new one
+func (s *clientCredentials) Invalidate() {
+	s.mutex.Lock()
+	s.token = nil
+	s.mutex.Unlock()
+
+	if s.kv != nil {
+		s.kv.Del(context.Background(), s.key())
+	}
+}
+
+func (s *clientCredentials) refreshInBackground() {
+	s.mutex.Lock()
+	if s.refreshing {
+		s.mutex.Unlock()
+		return
+	}
+	s.refreshing = true
+	s.mutex.Unlock()
+
+	go func() {
+		defer func() {
+			s.mutex.Lock()
+			s.refreshing = false
+			s.mutex.Unlock()
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), FETCH_TIMEOUT)
+		defer cancel()
+
+		// a failed background refresh is not fatal, the current token is still valid
+		s.refresh(ctx, true)
+	}()
+}
+
+// refresh fetches a new token, only one fetch runs at a time.
+// When force is false, a token fetched by a concurrent caller or another replica is reused.
+func (s *clientCredentials) refresh(ctx context.Context, force bool) (*Token, error) {
+	s.fetch.Lock()
+	defer s.fetch.Unlock()
+
+	if !force {
+		s.mutex.RLock()
+		token := s.token
+		s.mutex.RUnlock()
+		if token.Valid(0) {
+			return token, nil
+		}
+
+		if token := s.load(ctx); token.Valid(REFRESH_BEFORE) {
+			s.set(token)
+			return token, nil
+		}
+	}
+
+	token, err := s.request(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.set(token)
+	s.store(ctx, token)
+
+	return token, nil
+}
+
+func (s *clientCredentials) set(token *Token) {
+	s.mutex.Lock()
+	s.token = token
+	s.mutex.Unlock()
+}
+
+// request performs the client credentials grant against the token endpoint
+func (s *clientCredentials) request(ctx context.Context) (*Token, error) {
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	if len(s.scopes) > 0 {
+		form.Set("scope", strings.Join(s.scopes, " "))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.tokenUrl, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(s.clientId), url.QueryEscape(s.clientSecret))
+
+	response, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return nil, fmt.Errorf("oauth: token request failed: %v", response.Status)
+	}
+
+	token := new(Token)
+	if err := json.NewDecoder(response.Body).Decode(token); err != nil {
+		return nil, err
+	}
+
+	if token.AccessToken == "" {
+		return nil, errors.New("oauth: token response has no access_token")
+	}
+
+	token.Expiry = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+
+	return token, nil
+}
+
+func (s *clientCredentials) key() string {
+	return KEY_PREFIX + util.ConsistentHash(fmt.Sprintf("%v|%v|%v", s.tokenUrl, s.clientId, strings.Join(s.scopes, " ")))
+}
+
+// load reads a token shared by another replica, if any
+func (s *clientCredentials) load(ctx context.Context) *Token {
+	if s.kv == nil {
+		return nil
+	}
+
+	str, err := s.kv.Get(ctx, s.key()).Result()
+	if err != nil {
+		return nil
+	}
+
+	token := new(Token)
+	if err := json.Unmarshal([]byte(str), token); err != nil {
+		return nil
+	}
+
+	return token
+}
+
+// store shares a token with other replicas until shortly before it expires
+func (s *clientCredentials) store(ctx context.Context, token *Token) {
+	if s.kv == nil {
+		return
+	}
+
+	exp := time.Until(token.Expiry) - REFRESH_BEFORE
+	if exp <= 0 {
+		return
+	}
+
+	buf, err := json.Marshal(token)
+	if err != nil {
+		return
+	}
+
+	s.kv.Set(ctx, s.key(), string(buf), exp)
+}
+
+// NewClientCredentials creates a token source for the client credentials grant.
+// kv is optional, when provided tokens are shared across replicas through redis.
+func NewClientCredentials(tokenUrl, clientId, clientSecret string, scopes []string, kv redis.UniversalClient) *clientCredentials {
+	return &clientCredentials{
+		tokenUrl:     tokenUrl,
+		clientId:     clientId,
+		clientSecret: clientSecret,
+		scopes:       scopes,
+		client:       &http.Client{Timeout: FETCH_TIMEOUT},
+		kv:           kv,
+	}
+}
@@ -0,0 +1,31 @@
+// Package oauth provides OAuth2 token sources for authenticating service to service calls.
+// currently, only the client credentials grant is implemented
+package oauth
+
+import (
+	"strings"
+
+	"github.com/QubelyLabs/bedrock/pkg/config"
+	"github.com/QubelyLabs/bedrock/pkg/request"
+	"github.com/redis/go-redis/v9"
+)
+
+// Configure creates a client credentials token source from the config
+// and registers it on the default request client for every host listed in OAUTH_HOSTS.
+// It returns nil when no token url is configured.
+func Configure(cf *config.Config, kv redis.UniversalClient) *clientCredentials {
+	if cf.OAuthTokenUrl == "" {
+		return nil
+	}
+
+	source := NewClientCredentials(cf.OAuthTokenUrl, cf.OAuthClientId, cf.OAuthClientSecret, split(cf.OAuthScopes), kv)
+	for _, host := range split(cf.OAuthHosts) {
+		request.Authorize(host, source)
+	}
+
+	return source
+}
+
+func split(value string) []string {
+	return strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ' ' })
+}
@@ -0,0 +1,135 @@
+// Package outbox makes event publishing transactional: events are written to the outbox table
+// in the transaction of the data they describe, and a relay publishes them once committed.
+// Delivery is at least once, so listeners must be idempotent.
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/QubelyLabs/bedrock/pkg/event"
+	"github.com/QubelyLabs/bedrock/pkg/migration"
+	"github.com/QubelyLabs/bedrock/pkg/tracing"
+	"github.com/QubelyLabs/bedrock/pkg/uow"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	TABLE = "outbox_messages"
+
+	STATUS_PENDING    = "pending"
+	STATUS_PUBLISHING = "publishing"
+	STATUS_DELIVERED  = "delivered"
+	STATUS_FAILED     = "failed"
+)
+
+// Message is an event waiting in the outbox
+type Message struct {
+	ID          string     `gorm:"primaryKey;size:36" json:"id"`
+	Name        string     `gorm:"size:255;not null" json:"name"`
+	Payload     string     `gorm:"type:text" json:"payload"`
+	Envelope    string     `gorm:"type:text" json:"envelope"`
+	Trace       string     `gorm:"type:text" json:"trace"`
+	Status      string     `gorm:"size:16;not null;index:idx_outbox_status_available,priority:1" json:"status"`
+	Attempts    int        `json:"attempts"`
+	LastError   string     `gorm:"type:text" json:"last_error,omitempty"`
+	AvailableAt time.Time  `gorm:"not null;index:idx_outbox_status_available,priority:2" json:"available_at"` // Lease deadline while publishing
+	CreatedAt   time.Time  `json:"created_at"`
+	DeliveredAt *time.Time `json:"delivered_at,omitempty"`
+}
+
+func (Message) TableName() string {
+	return TABLE
+}
+
+// Event decodes the message, payload values come back as their json types, e.g. map[string]any for structs
+func (m *Message) Event() (event.Event, error) {
+	e := event.Event{Name: m.Name}
+	if m.Envelope != "" {
+		if err := json.Unmarshal([]byte(m.Envelope), &e); err != nil {
+			return e, err
+		}
+	} else if err := json.Unmarshal([]byte(m.Payload), &e.Payload); err != nil {
+		return e, err
+	}
+
+	if m.Trace != "" {
+		if err := json.Unmarshal([]byte(m.Trace), &e.Trace); err != nil {
+			return e, err
+		}
+	}
+
+	return e, nil
+}
+
+// Add writes the event to the outbox in tx, it is published only if tx commits.
+// The envelope is filled when written, so the event keeps its id across delivery attempts.
+func Add(tx *gorm.DB, e event.Event) error {
+	e = event.Envelop(tx.Statement.Context, e)
+
+	payload, err := json.Marshal(e.Payload)
+	if err != nil {
+		return err
+	}
+
+	trace := e.Trace
+	if trace == nil {
+		trace = map[string]string{}
+		tracing.Inject(tx.Statement.Context, trace)
+	}
+
+	traceJson, err := json.Marshal(trace)
+	if err != nil {
+		return err
+	}
+
+	e.Trace = nil
+	envelope, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	return tx.Create(&Message{
+		ID:          uuid.New().String(),
+		Name:        e.Name,
+		Payload:     string(payload),
+		Envelope:    string(envelope),
+		Trace:       string(traceJson),
+		Status:      STATUS_PENDING,
+		AvailableAt: now,
+		CreatedAt:   now,
+	}).Error
+}
+
+// Emit writes the event to the outbox in the unit of work of ctx,
+// e.g. the request context under middleware.Transaction, or directly without one
+func Emit(ctx context.Context, e event.Event) error {
+	return Add(uow.DB(ctx), event.Envelop(ctx, e))
+}
+
+// Publish writes the typed payload to the outbox in the unit of work of ctx, see event.Publish
+func Publish[T any](ctx context.Context, name string, payload T) error {
+	e, err := event.New(ctx, name, payload)
+	if err != nil {
+		return err
+	}
+
+	return Add(uow.DB(ctx), e)
+}
+
+// Migration creates the outbox table, add it to the migrator with a version ordering it before the migrations using it
+func Migration(version string) *migration.Migration {
+	return &migration.Migration{
+		Version: version,
+		Name:    "create outbox messages",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&Message{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&Message{})
+		},
+	}
+}
@@ -0,0 +1,227 @@
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"github.com/QubelyLabs/bedrock/pkg/config"
+	"github.com/QubelyLabs/bedrock/pkg/db"
+	"github.com/QubelyLabs/bedrock/pkg/event"
+	"github.com/QubelyLabs/bedrock/pkg/logger"
+	"github.com/QubelyLabs/bedrock/pkg/tracing"
+	"github.com/QubelyLabs/bedrock/pkg/util"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	BATCH_SIZE       = 100
+	POLL_INTERVAL    = time.Second
+	MAX_ATTEMPTS     = 10
+	RETENTION        = 7 * 24 * time.Hour
+	RETRY_BACKOFF    = 5 * time.Second
+	MAX_BACKOFF      = time.Hour
+	CLEANUP_INTERVAL = time.Hour
+	LEASE_TIMEOUT    = 5 * time.Minute
+)
+
+// Publisher delivers an event taken from the outbox, e.g. to the in process listeners or an external transport
+type Publisher func(ctx context.Context, e event.Event) error
+
+type relay struct {
+	db          *gorm.DB
+	publish     Publisher
+	batchSize   int
+	interval    time.Duration
+	maxAttempts int
+	retention   time.Duration
+}
+
+// Run polls the outbox until ctx is done, pass it to app.Go to run it as a worker
+func (r *relay) Run(ctx context.Context) error {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	lastCleanup := time.Time{}
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+
+		n, err := r.RelayOnce(ctx)
+		if err != nil && ctx.Err() == nil {
+			logger.For("outbox").Error("cannot relay outbox messages", "error", err)
+		}
+
+		if time.Since(lastCleanup) > CLEANUP_INTERVAL {
+			if err := r.Cleanup(ctx); err != nil && ctx.Err() == nil {
+				logger.For("outbox").Error("cannot clean up outbox messages", "error", err)
+			}
+			lastCleanup = time.Now()
+		}
+
+		// a full batch means more messages are waiting, so poll again right away
+		if n == r.batchSize {
+			timer.Reset(0)
+		} else {
+			timer.Reset(r.interval)
+		}
+	}
+}
+
+// RelayOnce publishes a batch of due messages and returns how many were taken.
+// The batch is claimed in a short transaction with SELECT ... FOR UPDATE SKIP LOCKED, so relays on several replicas share the work,
+// then published outside of it. A message whose relay died while publishing is taken again once its lease expired.
+func (r *relay) RelayOnce(ctx context.Context) (int, error) {
+	messages, err := r.claim(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	// on shutdown the messages left are taken again once their lease expired
+	for i := 0; i < len(messages) && ctx.Err() == nil; i++ {
+		if recordErr := r.deliver(ctx, &messages[i]); recordErr != nil && err == nil {
+			err = recordErr
+		}
+	}
+
+	return len(messages), err
+}
+
+// claim leases a batch of due messages for LEASE_TIMEOUT, each claim counts as an attempt
+func (r *relay) claim(ctx context.Context) ([]Message, error) {
+	var messages []Message
+
+	err := r.sql().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+
+		// messages whose relay died on their last attempt are not taken again
+		err := tx.Model(&Message{}).
+			Where("status = ? AND available_at <= ? AND attempts >= ?", STATUS_PUBLISHING, now, r.maxAttempts).
+			Updates(map[string]any{"status": STATUS_FAILED, "last_error": "lease expired while publishing"}).Error
+		if err != nil {
+			return err
+		}
+
+		query := tx.Where("status IN ? AND available_at <= ?", []string{STATUS_PENDING, STATUS_PUBLISHING}, now).
+			Order("available_at, created_at").
+			Limit(r.batchSize)
+
+		// sqlite has no row locks, its single writer serializes the relays instead
+		if tx.Dialector.Name() != db.SQLite {
+			query = query.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"})
+		}
+
+		if err := query.Find(&messages).Error; err != nil {
+			return err
+		}
+
+		for i := range messages {
+			message := &messages[i]
+			message.Status = STATUS_PUBLISHING
+			message.Attempts++
+			message.AvailableAt = now.Add(LEASE_TIMEOUT)
+
+			if err := tx.Model(message).Select("status", "attempts", "available_at").Updates(message).Error; err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return messages, nil
+}
+
+// deliver publishes a claimed message and records the outcome, a failed message is retried with an exponential backoff
+func (r *relay) deliver(ctx context.Context, message *Message) error {
+	log := logger.For("outbox")
+
+	e, err := message.Event()
+	if err == nil {
+		err = r.publish(tracing.Extract(ctx, e.Trace), e)
+	}
+
+	now := time.Now()
+	values := map[string]any{}
+
+	if err == nil {
+		values["status"], values["delivered_at"], values["last_error"] = STATUS_DELIVERED, now, ""
+	} else if message.Attempts >= r.maxAttempts {
+		values["status"], values["last_error"] = STATUS_FAILED, err.Error()
+		log.Error("outbox message failed, giving up", "id", message.ID, "event", message.Name, "attempts", message.Attempts, "error", err)
+	} else {
+		values["status"], values["last_error"] = STATUS_PENDING, err.Error()
+		values["available_at"] = now.Add(util.Backoff(message.Attempts, RETRY_BACKOFF, MAX_BACKOFF))
+		log.Warn("cannot publish outbox message, retrying", "id", message.ID, "event", message.Name, "attempt", message.Attempts, "error", err)
+	}
+
+	// the outcome is recorded even on shutdown, the attempts fence the lease as a relay taking the message again counts one more
+	result := r.sql().WithContext(context.WithoutCancel(ctx)).Model(&Message{}).
+		Where("id = ? AND status = ? AND attempts = ?", message.ID, STATUS_PUBLISHING, message.Attempts).
+		Updates(values)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		log.Warn("outbox message lease lost, another relay took it", "id", message.ID, "event", message.Name)
+	}
+
+	return nil
+}
+
+// Cleanup deletes the messages delivered before the retention period
+func (r *relay) Cleanup(ctx context.Context) error {
+	return r.sql().WithContext(ctx).
+		Where("status = ? AND delivered_at < ?", STATUS_DELIVERED, time.Now().Add(-r.retention)).
+		Delete(&Message{}).Error
+}
+
+func (r *relay) sql() *gorm.DB {
+	if r.db != nil {
+		return r.db
+	}
+
+	return db.SQL()
+}
+
+// NewRelay creates a relay publishing the outbox of the database, nil uses db.SQL() at run time.
+// Zero values use the defaults.
+func NewRelay(d *gorm.DB, publish Publisher, batchSize int, interval time.Duration, maxAttempts int, retention time.Duration) *relay {
+	if batchSize <= 0 {
+		batchSize = BATCH_SIZE
+	}
+
+	if interval <= 0 {
+		interval = POLL_INTERVAL
+	}
+
+	if maxAttempts <= 0 {
+		maxAttempts = MAX_ATTEMPTS
+	}
+
+	if retention <= 0 {
+		retention = RETENTION
+	}
+
+	return &relay{d, publish, batchSize, interval, maxAttempts, retention}
+}
+
+// NewDefaultRelay creates a relay of the default database publishing to the event listeners, configured by OUTBOX_*
+func NewDefaultRelay(cf *config.Config) *relay {
+	return NewRelay(
+		nil,
+		event.EmitWithContext,
+		cf.OutboxBatchSize,
+		time.Duration(cf.OutboxPollInterval)*time.Second,
+		cf.OutboxMaxAttempts,
+		time.Duration(cf.OutboxRetention)*time.Second,
+	)
+}
@@ -0,0 +1,161 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/QubelyLabs/bedrock/pkg/config"
+	"github.com/QubelyLabs/bedrock/pkg/db"
+	"github.com/QubelyLabs/bedrock/pkg/event"
+	"gorm.io/gorm"
+)
+
+// the relay runs against sqlite, so it needs no database server
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "bedrock-outbox")
+	if err != nil {
+		panic(err)
+	}
+
+	code := func() int {
+		defer os.RemoveAll(dir)
+
+		err := db.InitSQL(&config.Config{
+			DBDialect:  "sqlite",
+			DBName:     filepath.Join(dir, "test.db"),
+			DBLogLevel: "silent",
+		})
+		if err != nil {
+			panic(err)
+		}
+		defer db.CloseSQL()
+
+		if err := Migration("1").Up(db.SQL()); err != nil {
+			panic(err)
+		}
+
+		return m.Run()
+	}()
+
+	os.Exit(code)
+}
+
+// add empties the outbox then writes the events to it
+func add(t *testing.T, names ...string) {
+	t.Helper()
+
+	if err := db.SQL().Session(&gorm.Session{AllowGlobalUpdate: true}).Delete(&Message{}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range names {
+		if err := Add(db.SQL(), event.Event{Name: name, Payload: []any{name}}); err != nil {
+			t.Fatalf("Add(%v): %v", name, err)
+		}
+	}
+}
+
+func message(t *testing.T, name string) Message {
+	t.Helper()
+
+	found := Message{}
+	if err := db.SQL().Where("name = ?", name).First(&found).Error; err != nil {
+		t.Fatalf("cannot find the message %v: %v", name, err)
+	}
+
+	return found
+}
+
+func TestRelayOnce(t *testing.T) {
+	ctx := context.Background()
+	add(t, "user.created", "user.deleted")
+
+	published := []string{}
+	r := NewRelay(nil, func(ctx context.Context, e event.Event) error {
+		published = append(published, e.Name)
+		if e.Name == "user.deleted" {
+			return errors.New("broker unavailable")
+		}
+
+		return nil
+	}, 0, 0, 2, 0)
+
+	started := time.Now()
+	n, err := r.RelayOnce(ctx)
+	if err != nil || n != 2 {
+		t.Fatalf("RelayOnce = %v, %v, want 2 messages", n, err)
+	}
+	if len(published) != 2 {
+		t.Fatalf("RelayOnce published %v, want both messages", published)
+	}
+
+	delivered := message(t, "user.created")
+	if delivered.Status != STATUS_DELIVERED || delivered.DeliveredAt == nil || delivered.Attempts != 1 {
+		t.Errorf("published message = %+v, want delivered after 1 attempt", delivered)
+	}
+
+	retried := message(t, "user.deleted")
+	if retried.Status != STATUS_PENDING || retried.Attempts != 1 || retried.LastError != "broker unavailable" {
+		t.Errorf("failed message = %+v, want pending with its error", retried)
+	}
+	if retried.AvailableAt.Before(started.Add(RETRY_BACKOFF - time.Second)) {
+		t.Errorf("failed message available at %v, want a backoff of %v", retried.AvailableAt, RETRY_BACKOFF)
+	}
+
+	if n, _ := r.RelayOnce(ctx); n != 0 {
+		t.Errorf("RelayOnce took %v messages before the backoff elapsed", n)
+	}
+
+	db.SQL().Model(&Message{}).Where("id = ?", retried.ID).Update("available_at", time.Now())
+
+	if n, err := r.RelayOnce(ctx); err != nil || n != 1 {
+		t.Fatalf("RelayOnce after the backoff = %v, %v, want 1 message", n, err)
+	}
+
+	failed := message(t, "user.deleted")
+	if failed.Status != STATUS_FAILED || failed.Attempts != 2 {
+		t.Errorf("message failing its last attempt = %+v, want failed after 2 attempts", failed)
+	}
+
+	if n, _ := r.RelayOnce(ctx); n != 0 {
+		t.Errorf("RelayOnce took %v failed messages", n)
+	}
+}
+
+func TestRelayOnceExpiredLease(t *testing.T) {
+	ctx := context.Background()
+	add(t, "user.created", "user.deleted")
+
+	// a relay that died while publishing left both messages claimed, the second on its last attempt
+	db.SQL().Model(&Message{}).Where("1 = 1").Updates(map[string]any{"status": STATUS_PUBLISHING, "attempts": 1, "available_at": time.Now().Add(-time.Second)})
+	db.SQL().Model(&Message{}).Where("name = ?", "user.deleted").Update("attempts", 2)
+
+	stale := message(t, "user.created")
+
+	r := NewRelay(nil, func(ctx context.Context, e event.Event) error { return nil }, 0, 0, 2, 0)
+	if n, err := r.RelayOnce(ctx); err != nil || n != 1 {
+		t.Fatalf("RelayOnce = %v, %v, want the expired message", n, err)
+	}
+
+	if found := message(t, "user.created"); found.Status != STATUS_DELIVERED || found.Attempts != 2 {
+		t.Errorf("expired message = %+v, want delivered on its second attempt", found)
+	}
+	if found := message(t, "user.deleted"); found.Status != STATUS_FAILED {
+		t.Errorf("message expired on its last attempt = %+v, want failed", found)
+	}
+
+	// the relay that lost the lease cannot overwrite the outcome
+	stale.Status = STATUS_PUBLISHING
+	r = NewRelay(nil, func(ctx context.Context, e event.Event) error { return errors.New("too late") }, 0, 0, 2, 0)
+	if err := r.deliver(ctx, &stale); err != nil {
+		t.Fatalf("deliver with a lost lease: %v", err)
+	}
+	if found := message(t, "user.created"); found.Status != STATUS_DELIVERED {
+		t.Errorf("the relay that lost the lease recorded %v", found.Status)
+	}
+}
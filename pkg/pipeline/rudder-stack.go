@@ -19,6 +19,11 @@ func (s *rudderStackService) Track(data Track) error {
 	return s.client.Enqueue(data)
 }
 
+// Close flushes the queued messages and stops the client
+func (s *rudderStackService) Close() error {
+	return s.client.Close()
+}
+
 func NewRudderStackService(dataPlaneUrl, key string) *rudderStackService {
 	client, err := analytics.NewWithConfig(
 		key,
@@ -0,0 +1,229 @@
+// Package queue runs durable background jobs: jobs are stored in the database or redis,
+// taken by a pool of workers and retried with an exponential backoff until they succeed or run out of attempts.
+// A job may run more than once, e.g. when its worker dies, so handlers must be idempotent.
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/QubelyLabs/bedrock/pkg/config"
+	"github.com/QubelyLabs/bedrock/pkg/db"
+	"github.com/google/uuid"
+)
+
+const (
+	DEFAULT_QUEUE = "default"
+	MAX_ATTEMPTS  = 5
+	RETENTION     = 7 * 24 * time.Hour
+
+	STATUS_PENDING   = "pending"
+	STATUS_RUNNING   = "running"
+	STATUS_SUCCEEDED = "succeeded"
+	STATUS_FAILED    = "failed"
+	STATUS_CANCELLED = "cancelled"
+)
+
+var (
+	ErrDuplicate   = errors.New("queue: a job with the unique key is already pending or running")
+	ErrJobNotFound = errors.New("queue: job not found")
+	ErrLeaseLost   = errors.New("queue: job lease lost, its visibility timeout expired")
+	ErrNotPending  = errors.New("queue: job is not pending")
+	ErrPermanent   = errors.New("queue: permanent failure")
+)
+
+// Job is a unit of background work
+type Job struct {
+	ID          string     `gorm:"primaryKey;size:36" json:"id"`
+	Queue       string     `gorm:"size:64;not null;index:idx_queue_jobs_dequeue,priority:1" json:"queue"`
+	Type        string     `gorm:"size:255;not null" json:"type"`
+	Payload     string     `gorm:"type:text" json:"payload"`
+	Priority    int        `gorm:"not null;default:0" json:"priority"`
+	UniqueKey   *string    `gorm:"size:255;uniqueIndex" json:"unique_key,omitempty"` // Cleared once the job is finished
+	Status      string     `gorm:"size:16;not null;index:idx_queue_jobs_dequeue,priority:2" json:"status"`
+	Attempts    int        `json:"attempts"`
+	MaxAttempts int        `json:"max_attempts"`
+	LastError   string     `gorm:"type:text" json:"last_error,omitempty"`
+	Token       string     `gorm:"size:36" json:"-"`                                                     // Lease of the worker running the job
+	AvailableAt time.Time  `gorm:"not null;index:idx_queue_jobs_dequeue,priority:3" json:"available_at"` // Visibility deadline while running
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+}
+
+func (Job) TableName() string {
+	return TABLE
+}
+
+// Decode unmarshals the payload of the job
+func (j *Job) Decode(v any) error {
+	return json.Unmarshal([]byte(j.Payload), v)
+}
+
+// Options configures an enqueued job
+type Options struct {
+	Queue       string    // DEFAULT_QUEUE when empty
+	Priority    int       // Higher priorities are taken first
+	UniqueKey   string    // Enqueuing the key of a pending or running job returns that job with ErrDuplicate
+	RunAt       time.Time // Delays the job until then
+	MaxAttempts int       // MAX_ATTEMPTS when zero
+}
+
+// Store keeps the jobs, every method taking a job checks the lease of its worker
+type Store interface {
+	// Enqueue adds the job, or returns the pending or running job with the same unique key and ErrDuplicate
+	Enqueue(ctx context.Context, job *Job) (*Job, error)
+	// Dequeue leases the next available job of the queue for the visibility timeout, nil when there is none
+	Dequeue(ctx context.Context, queue string, visibility time.Duration) (*Job, error)
+	// Extend pushes the visibility deadline of a running job
+	Extend(ctx context.Context, job *Job, visibility time.Duration) error
+	Complete(ctx context.Context, job *Job) error
+	// Retry makes the job available again at the time
+	Retry(ctx context.Context, job *Job, at time.Time, cause error) error
+	Fail(ctx context.Context, job *Job, cause error) error
+	// Cancel removes a pending job
+	Cancel(ctx context.Context, id string) error
+	Get(ctx context.Context, id string) (*Job, error)
+	// List returns the jobs of the queue with the status, a negative limit returns all
+	List(ctx context.Context, queue, status string, limit, offset int) ([]Job, error)
+}
+
+// Handler runs a job, returning an error retries it unless it wraps ErrPermanent
+type Handler func(ctx context.Context, job *Job) error
+
+var (
+	Default  Store = NewSQLStore(nil)
+	handlers       = map[string]Handler{}
+	mutex          = &sync.RWMutex{}
+)
+
+// SetStore replaces the default store
+func SetStore(store Store) {
+	Default = store
+}
+
+// Configure sets the default store of QUEUE_BACKEND, sql by default or redis on db.KV()
+func Configure(cf *config.Config) error {
+	switch strings.ToLower(cf.QueueBackend) {
+	case "", "sql":
+		SetStore(NewSQLStore(nil))
+		return nil
+	case "redis":
+		if db.KV() == nil {
+			return fmt.Errorf("queue: the redis backend needs redis to be initialized")
+		}
+
+		SetStore(NewRedisStore(db.KV(), ""))
+		return nil
+	default:
+		return fmt.Errorf("queue: unsupported backend %v", cf.QueueBackend)
+	}
+}
+
+// Register sets the handler of the job type, replacing the previous one
+func Register(jobType string, handler Handler) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	handlers[jobType] = handler
+}
+
+// Handle registers a handler receiving the decoded payload of the job type
+func Handle[T any](jobType string, fn func(ctx context.Context, payload T) error) {
+	Register(jobType, func(ctx context.Context, job *Job) error {
+		var payload T
+		if err := job.Decode(&payload); err != nil {
+			return Permanent(fmt.Errorf("cannot decode payload: %w", err))
+		}
+
+		return fn(ctx, payload)
+	})
+}
+
+func handlerFor(jobType string) (Handler, bool) {
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	handler, ok := handlers[jobType]
+	return handler, ok
+}
+
+// Permanent marks the error of a handler as not worth retrying
+func Permanent(err error) error {
+	return fmt.Errorf("%w: %w", ErrPermanent, err)
+}
+
+// NewJob creates a job of the type with the json encoded payload
+func NewJob(jobType string, payload any, opts ...*Options) (*Job, error) {
+	options := &Options{}
+	if len(opts) > 0 && opts[0] != nil {
+		options = opts[0]
+	}
+
+	buf, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("queue: cannot encode the payload of %v: %w", jobType, err)
+	}
+
+	now := time.Now()
+	job := &Job{
+		ID:          uuid.New().String(),
+		Queue:       options.Queue,
+		Type:        jobType,
+		Payload:     string(buf),
+		Priority:    options.Priority,
+		Status:      STATUS_PENDING,
+		MaxAttempts: options.MaxAttempts,
+		AvailableAt: now,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	if job.Queue == "" {
+		job.Queue = DEFAULT_QUEUE
+	}
+
+	if job.MaxAttempts <= 0 {
+		job.MaxAttempts = MAX_ATTEMPTS
+	}
+
+	if options.UniqueKey != "" {
+		job.UniqueKey = &options.UniqueKey
+	}
+
+	if options.RunAt.After(now) {
+		job.AvailableAt = options.RunAt
+	}
+
+	return job, nil
+}
+
+// Enqueue adds a job to the default store, see Options for the priority, unique key and delay
+func Enqueue(ctx context.Context, jobType string, payload any, opts ...*Options) (*Job, error) {
+	job, err := NewJob(jobType, payload, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return Default.Enqueue(ctx, job)
+}
+
+// Get returns a job of the default store
+func Get(ctx context.Context, id string) (*Job, error) {
+	return Default.Get(ctx, id)
+}
+
+// List returns the jobs of a queue of the default store with the status
+func List(ctx context.Context, queue, status string, limit, offset int) ([]Job, error) {
+	return Default.List(ctx, queue, status, limit, offset)
+}
+
+// Cancel removes a pending job of the default store
+func Cancel(ctx context.Context, id string) error {
+	return Default.Cancel(ctx, id)
+}
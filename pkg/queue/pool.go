@@ -0,0 +1,265 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/QubelyLabs/bedrock/pkg/config"
+	"github.com/QubelyLabs/bedrock/pkg/integration"
+	"github.com/QubelyLabs/bedrock/pkg/logger"
+	"github.com/QubelyLabs/bedrock/pkg/metrics"
+	"github.com/QubelyLabs/bedrock/pkg/tracing"
+	"github.com/QubelyLabs/bedrock/pkg/util"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+const (
+	CONCURRENCY        = 10
+	VISIBILITY_TIMEOUT = 5 * time.Minute
+	POLL_INTERVAL      = time.Second
+	RETRY_BACKOFF      = 5 * time.Second
+	MAX_BACKOFF        = time.Hour
+	CLEANUP_INTERVAL   = time.Hour
+)
+
+type pool struct {
+	store       Store
+	queues      []string
+	concurrency int
+	visibility  time.Duration
+	interval    time.Duration
+}
+
+// Run takes jobs until ctx is done then waits for the running jobs, e.g. a.Go("queue", queue.NewPool(nil, nil, 0, 0, 0).Run).
+// Running jobs keep their context on shutdown, a job outliving the shutdown deadline is taken again after its visibility timeout.
+func (p *pool) Run(ctx context.Context) error {
+	log := logger.For("queue")
+
+	slots := make(chan struct{}, p.concurrency)
+	wg := sync.WaitGroup{}
+	defer wg.Wait()
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	lastCleanup := time.Time{}
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+
+		// take jobs as long as some are available, waiting for a free slot before each
+		for {
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+
+			job, err := p.next(ctx)
+			if err != nil && ctx.Err() == nil {
+				log.Error("cannot take jobs", "queues", p.queues, "error", err)
+			}
+
+			if job == nil {
+				<-slots
+				break
+			}
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer func() { <-slots }()
+
+				p.process(context.WithoutCancel(ctx), job)
+			}()
+		}
+
+		if pruner, ok := p.client().(interface {
+			Prune(ctx context.Context, before time.Time) (int64, error)
+		}); ok && time.Since(lastCleanup) > CLEANUP_INTERVAL {
+			if _, err := pruner.Prune(ctx, time.Now().Add(-RETENTION)); err != nil && ctx.Err() == nil {
+				log.Error("cannot prune finished jobs", "error", err)
+			}
+			lastCleanup = time.Now()
+		}
+
+		timer.Reset(p.interval)
+	}
+}
+
+// next leases a job of the first queue having one available
+func (p *pool) next(ctx context.Context) (*Job, error) {
+	for _, queue := range p.queues {
+		job, err := p.client().Dequeue(ctx, queue, p.visibility)
+		if err != nil || job != nil {
+			return job, err
+		}
+	}
+
+	return nil, nil
+}
+
+// process runs the job while extending its lease, then completes, retries or fails it
+func (p *pool) process(ctx context.Context, job *Job) {
+	log := logger.For("queue")
+	store := p.client()
+
+	ctx, span := tracing.Tracer().Start(ctx, "job "+job.Type)
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("job.id", job.ID),
+		attribute.String("job.queue", job.Queue),
+		attribute.Int("job.attempt", job.Attempts),
+	)
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	done := make(chan struct{})
+	heartbeat := make(chan struct{})
+	go func() {
+		defer close(heartbeat)
+		p.heartbeat(runCtx, cancel, *job, done)
+	}()
+
+	started := time.Now()
+	err := p.call(runCtx, job)
+	close(done)
+	<-heartbeat
+
+	metrics.QueueDuration.WithLabelValues(job.Queue, job.Type).Observe(time.Since(started).Seconds())
+
+	status := STATUS_SUCCEEDED
+	var outcome error
+	switch {
+	case err == nil:
+		outcome = store.Complete(ctx, job)
+	case errors.Is(err, ErrPermanent) || job.Attempts >= job.MaxAttempts:
+		status = STATUS_FAILED
+		outcome = store.Fail(ctx, job, err)
+	default:
+		status = "retried"
+		outcome = store.Retry(ctx, job, time.Now().Add(util.Backoff(job.Attempts, RETRY_BACKOFF, MAX_BACKOFF)), err)
+	}
+
+	if errors.Is(outcome, ErrLeaseLost) {
+		status = "lost"
+	}
+
+	span.SetAttributes(attribute.String("job.status", status))
+	metrics.QueueJobs.WithLabelValues(job.Queue, job.Type, status).Inc()
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	switch {
+	case errors.Is(outcome, ErrLeaseLost):
+		log.Warn("job lease lost, another worker took it", "id", job.ID, "type", job.Type, "error", err)
+	case outcome != nil:
+		log.Error("cannot record job outcome", "id", job.ID, "type", job.Type, "status", status, "error", outcome)
+	case status == STATUS_FAILED:
+		log.Error("job failed, giving up", "id", job.ID, "type", job.Type, "attempts", job.Attempts, "error", err)
+	case status == "retried":
+		log.Warn("job failed, retrying", "id", job.ID, "type", job.Type, "attempt", job.Attempts, "error", err)
+	}
+}
+
+// heartbeat extends the lease of the job until done, a lost lease cancels the job as another worker may take it
+func (p *pool) heartbeat(ctx context.Context, cancel context.CancelFunc, job Job, done chan struct{}) {
+	ticker := time.NewTicker(p.visibility / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+
+		err := p.client().Extend(ctx, &job, p.visibility)
+		if errors.Is(err, ErrLeaseLost) {
+			cancel()
+			return
+		} else if err != nil && ctx.Err() == nil {
+			logger.For("queue").Error("cannot extend job lease", "id", job.ID, "type", job.Type, "error", err)
+		}
+	}
+}
+
+// call runs the handler of the job, a panic is reported to sentry and returned as an error
+func (p *pool) call(ctx context.Context, job *Job) error {
+	handler, ok := handlerFor(job.Type)
+	if !ok {
+		// retried, a replica running a newer version may know the type
+		return fmt.Errorf("queue: no handler registered for %v", job.Type)
+	}
+
+	err := integration.Recover(map[string]string{"job": job.Type, "job.id": job.ID}, func() error {
+		return handler(ctx, job)
+	})
+
+	var panicked *integration.PanicError
+	if errors.As(err, &panicked) {
+		logger.For("queue").Error("job panicked", "id", job.ID, "type", job.Type, "panic", panicked.Value, "stack", string(panicked.Stack))
+	}
+
+	return err
+}
+
+func (p *pool) client() Store {
+	if p.store != nil {
+		return p.store
+	}
+
+	return Default
+}
+
+// NewPool creates a pool of workers taking the jobs of the queues from the store, the first queues first.
+// A nil store uses the default store at run time, zero values use the defaults.
+func NewPool(store Store, queues []string, concurrency int, visibility, interval time.Duration) *pool {
+	if len(queues) == 0 {
+		queues = []string{DEFAULT_QUEUE}
+	}
+
+	if concurrency <= 0 {
+		concurrency = CONCURRENCY
+	}
+
+	if visibility <= 0 {
+		visibility = VISIBILITY_TIMEOUT
+	}
+
+	if interval <= 0 {
+		interval = POLL_INTERVAL
+	}
+
+	return &pool{store, queues, concurrency, visibility, interval}
+}
+
+// NewDefaultPool creates a pool of the default store, configured by QUEUE_*
+func NewDefaultPool(cf *config.Config) *pool {
+	queues := []string{}
+	for _, name := range strings.Split(cf.QueueNames, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			queues = append(queues, name)
+		}
+	}
+
+	return NewPool(
+		nil,
+		queues,
+		cf.QueueConcurrency,
+		time.Duration(cf.QueueVisibilityTimeout)*time.Second,
+		time.Duration(cf.QueuePollInterval)*time.Second,
+	)
+}
@@ -0,0 +1,68 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProcess(t *testing.T) {
+	Register("test:ok", func(ctx context.Context, job *Job) error { return nil })
+	Register("test:flaky", func(ctx context.Context, job *Job) error { return errors.New("unavailable") })
+	Register("test:broken", func(ctx context.Context, job *Job) error { return Permanent(errors.New("invalid")) })
+	Register("test:panic", func(ctx context.Context, job *Job) error { panic("boom") })
+
+	tests := []struct {
+		name        string
+		jobType     string
+		maxAttempts int
+		status      string
+		lastError   string
+		delayed     bool
+	}{
+		{"complete", "test:ok", 0, STATUS_SUCCEEDED, "", false},
+		{"retry", "test:flaky", 0, STATUS_PENDING, "unavailable", true},
+		{"fail after the last attempt", "test:flaky", 1, STATUS_FAILED, "unavailable", false},
+		{"fail on a permanent error", "test:broken", 0, STATUS_FAILED, "invalid", false},
+		{"retry a panic", "test:panic", 0, STATUS_PENDING, "boom", true},
+		{"retry an unknown type", "test:unknown", 0, STATUS_PENDING, "no handler", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			s := newSQLStore(t)
+			p := NewPool(s, nil, 1, time.Minute, 0)
+
+			enqueue(t, s, tt.jobType, &Options{MaxAttempts: tt.maxAttempts})
+			job := dequeue(t, s, time.Minute)
+
+			started := time.Now()
+			p.process(ctx, job)
+
+			found, err := s.Get(ctx, job.ID)
+			if err != nil {
+				t.Fatalf("Get: %v", err)
+			}
+
+			if found.Status != tt.status {
+				t.Errorf("status = %v, want %v", found.Status, tt.status)
+			}
+			if !strings.Contains(found.LastError, tt.lastError) {
+				t.Errorf("last error = %q, want %q", found.LastError, tt.lastError)
+			}
+			if found.Token != "" {
+				t.Errorf("the lease was kept: %v", found.Token)
+			}
+
+			if tt.delayed && found.AvailableAt.Before(started.Add(RETRY_BACKOFF-time.Second)) {
+				t.Errorf("retried at %v, want a backoff of %v", found.AvailableAt, RETRY_BACKOFF)
+			}
+			if !tt.delayed && found.FinishedAt == nil {
+				t.Error("the finished job has no finish time")
+			}
+		})
+	}
+}
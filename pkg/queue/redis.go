@@ -0,0 +1,484 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	QUEUE_KEY = "{bedrock:queue}"
+	LIST_PAGE = 100 // Jobs read at once by List
+)
+
+// finishLua is shared by the scripts changing the state of a job, they all get the keys of the queue in the same order:
+// ready, delayed, running, finished, unique and the job. A finished job releases its unique key and expires after the retention.
+const finishLua = `
+local function finish(key, id, status, reason, now, retention)
+	local unique = redis.call('HGET', key, 'unique_key')
+	if unique and unique ~= '' and redis.call('HGET', KEYS[5], unique) == id then
+		redis.call('HDEL', KEYS[5], unique)
+	end
+	redis.call('HSET', key, 'status', status, 'last_error', reason, 'token', '', 'unique_key', '', 'finished_at', now, 'updated_at', now)
+	redis.call('PEXPIRE', key, retention)
+	redis.call('ZADD', KEYS[4], now, id)
+	redis.call('ZREMRANGEBYSCORE', KEYS[4], '-inf', now - retention)
+end
+`
+
+// readyLua orders the ready jobs by priority first and availability second, which holds for priorities within ±900
+const readyLua = `
+local function ready(key, id, at)
+	local priority = tonumber(redis.call('HGET', key, 'priority') or '0')
+	redis.call('ZADD', KEYS[1], -priority * 1e13 + at, id)
+end
+`
+
+var (
+	enqueueJob = redis.NewScript(readyLua + `
+if ARGV[2] ~= '' then
+	local existing = redis.call('HGET', KEYS[5], ARGV[2])
+	if existing then
+		return existing
+	end
+	redis.call('HSET', KEYS[5], ARGV[2], ARGV[1])
+end
+redis.call('HSET', KEYS[6], unpack(ARGV, 5))
+if tonumber(ARGV[4]) > tonumber(ARGV[3]) then
+	redis.call('ZADD', KEYS[2], ARGV[4], ARGV[1])
+else
+	ready(KEYS[6], ARGV[1], tonumber(ARGV[4]))
+end
+return ARGV[1]
+`)
+
+	// dequeueJob moves the due delayed jobs to the ready set, takes back the running jobs past their visibility deadline,
+	// then leases the first ready job
+	dequeueJob = redis.NewScript(finishLua + readyLua + `
+local now = tonumber(ARGV[1])
+local due = redis.call('ZRANGEBYSCORE', KEYS[2], '-inf', now, 'WITHSCORES', 'LIMIT', 0, 100)
+for i = 1, #due, 2 do
+	redis.call('ZREM', KEYS[2], due[i])
+	ready(ARGV[4] .. due[i], due[i], tonumber(due[i + 1]))
+end
+local expired = redis.call('ZRANGEBYSCORE', KEYS[3], '-inf', now, 'LIMIT', 0, 100)
+for _, id in ipairs(expired) do
+	local key = ARGV[4] .. id
+	redis.call('ZREM', KEYS[3], id)
+	if tonumber(redis.call('HGET', key, 'attempts') or '0') >= tonumber(redis.call('HGET', key, 'max_attempts') or '0') then
+		finish(key, id, 'failed', 'visibility timeout expired', now, tonumber(ARGV[5]))
+	else
+		redis.call('HSET', key, 'status', 'pending', 'token', '', 'updated_at', now)
+		ready(key, id, now)
+	end
+end
+local popped = redis.call('ZPOPMIN', KEYS[1])
+if #popped == 0 then
+	return false
+end
+local key = ARGV[4] .. popped[1]
+redis.call('HINCRBY', key, 'attempts', 1)
+redis.call('HSET', key, 'status', 'running', 'token', ARGV[3], 'available_at', ARGV[2], 'updated_at', now)
+redis.call('ZADD', KEYS[3], ARGV[2], popped[1])
+return redis.call('HGETALL', key)
+`)
+
+	extendJob = redis.NewScript(`
+if redis.call('HGET', KEYS[6], 'token') ~= ARGV[1] then
+	return 0
+end
+redis.call('HSET', KEYS[6], 'available_at', ARGV[3], 'updated_at', ARGV[4])
+redis.call('ZADD', KEYS[3], ARGV[3], ARGV[2])
+return 1
+`)
+
+	completeJob = redis.NewScript(finishLua + `
+if redis.call('HGET', KEYS[6], 'token') ~= ARGV[1] then
+	return 0
+end
+redis.call('ZREM', KEYS[3], ARGV[2])
+finish(KEYS[6], ARGV[2], ARGV[4], ARGV[5], tonumber(ARGV[3]), tonumber(ARGV[6]))
+return 1
+`)
+
+	retryJob = redis.NewScript(`
+if redis.call('HGET', KEYS[6], 'token') ~= ARGV[1] then
+	return 0
+end
+redis.call('ZREM', KEYS[3], ARGV[2])
+redis.call('HSET', KEYS[6], 'status', 'pending', 'token', '', 'last_error', ARGV[5], 'available_at', ARGV[4], 'updated_at', ARGV[3])
+redis.call('ZADD', KEYS[2], ARGV[4], ARGV[2])
+return 1
+`)
+
+	cancelJob = redis.NewScript(finishLua + `
+local status = redis.call('HGET', KEYS[6], 'status')
+if not status then
+	return -1
+end
+if status ~= 'pending' then
+	return 0
+end
+redis.call('ZREM', KEYS[1], ARGV[1])
+redis.call('ZREM', KEYS[2], ARGV[1])
+finish(KEYS[6], ARGV[1], 'cancelled', '', tonumber(ARGV[2]), tonumber(ARGV[3]))
+return 1
+`)
+)
+
+// redisStore keeps each job in a hash, indexed per queue by sorted sets of the ready, delayed, running and finished jobs.
+// Every change of state runs in a script, so workers on several replicas never lease the same job.
+type redisStore struct {
+	client    redis.UniversalClient
+	key       string
+	retention time.Duration
+}
+
+func (s *redisStore) Enqueue(ctx context.Context, job *Job) (*Job, error) {
+	unique := ""
+	if job.UniqueKey != nil {
+		unique = *job.UniqueKey
+	}
+
+	args := []any{job.ID, unique, millis(time.Now()), millis(job.AvailableAt)}
+	for field, value := range fields(job) {
+		args = append(args, field, value)
+	}
+
+	id, err := enqueueJob.Run(ctx, s.client, s.keys(job.Queue, job.ID), args...).Text()
+	if err != nil {
+		return nil, err
+	}
+
+	if id != job.ID {
+		existing, err := s.Get(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+
+		return existing, ErrDuplicate
+	}
+
+	return job, nil
+}
+
+func (s *redisStore) Dequeue(ctx context.Context, queue string, visibility time.Duration) (*Job, error) {
+	now := time.Now()
+	values, err := dequeueJob.Run(ctx, s.client, s.keys(queue, ""),
+		millis(now), millis(now.Add(visibility)), uuid.New().String(), s.key+":job:", s.retention.Milliseconds(),
+	).StringSlice()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	hash := map[string]string{}
+	for i := 0; i+1 < len(values); i += 2 {
+		hash[values[i]] = values[i+1]
+	}
+
+	return parse(hash), nil
+}
+
+func (s *redisStore) Extend(ctx context.Context, job *Job, visibility time.Duration) error {
+	now := time.Now()
+	deadline := now.Add(visibility)
+
+	err := s.leased(extendJob.Run(ctx, s.client, s.keys(job.Queue, job.ID), job.Token, job.ID, millis(deadline), millis(now)))
+	if err == nil {
+		job.AvailableAt = deadline
+	}
+
+	return err
+}
+
+func (s *redisStore) Complete(ctx context.Context, job *Job) error {
+	return s.finish(ctx, job, STATUS_SUCCEEDED, "")
+}
+
+func (s *redisStore) Retry(ctx context.Context, job *Job, at time.Time, cause error) error {
+	err := s.leased(retryJob.Run(ctx, s.client, s.keys(job.Queue, job.ID), job.Token, job.ID, millis(time.Now()), millis(at), cause.Error()))
+	if err == nil {
+		job.Status, job.LastError, job.Token, job.AvailableAt = STATUS_PENDING, cause.Error(), "", at
+	}
+
+	return err
+}
+
+func (s *redisStore) Fail(ctx context.Context, job *Job, cause error) error {
+	return s.finish(ctx, job, STATUS_FAILED, cause.Error())
+}
+
+func (s *redisStore) finish(ctx context.Context, job *Job, status, lastError string) error {
+	now := time.Now()
+	err := s.leased(completeJob.Run(ctx, s.client, s.keys(job.Queue, job.ID),
+		job.Token, job.ID, millis(now), status, lastError, s.retention.Milliseconds(),
+	))
+	if err == nil {
+		job.Status, job.LastError, job.UniqueKey, job.Token, job.FinishedAt = status, lastError, nil, "", &now
+	}
+
+	return err
+}
+
+func (s *redisStore) leased(cmd *redis.Cmd) error {
+	ok, err := cmd.Int()
+	if err != nil {
+		return err
+	}
+
+	if ok == 0 {
+		return ErrLeaseLost
+	}
+
+	return nil
+}
+
+func (s *redisStore) Cancel(ctx context.Context, id string) error {
+	queue, err := s.client.HGet(ctx, s.job(id), "queue").Result()
+	if errors.Is(err, redis.Nil) {
+		return ErrJobNotFound
+	} else if err != nil {
+		return err
+	}
+
+	result, err := cancelJob.Run(ctx, s.client, s.keys(queue, id), id, millis(time.Now()), s.retention.Milliseconds()).Int()
+	if err != nil {
+		return err
+	}
+
+	switch result {
+	case -1:
+		return ErrJobNotFound
+	case 0:
+		return ErrNotPending
+	default:
+		return nil
+	}
+}
+
+func (s *redisStore) Get(ctx context.Context, id string) (*Job, error) {
+	hash, err := s.client.HGetAll(ctx, s.job(id)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(hash) == 0 {
+		return nil, ErrJobNotFound
+	}
+
+	return parse(hash), nil
+}
+
+// List returns pending jobs in dequeue order, running jobs by visibility deadline and finished jobs latest first.
+// An empty status returns every job, finished jobs are kept for the retention.
+// The sets are paged with ZRANGE and only the jobs of a page are read, the finished set holds every final status,
+// so one of them is filtered page by page.
+func (s *redisStore) List(ctx context.Context, queue, status string, limit, offset int) ([]Job, error) {
+	jobs := []Job{}
+	if limit == 0 {
+		return jobs, nil
+	}
+
+	keys := s.keys(queue, "")
+	var sets []string
+	switch status {
+	case STATUS_PENDING:
+		sets = keys[:2]
+	case STATUS_RUNNING:
+		sets = keys[2:3]
+	case STATUS_SUCCEEDED, STATUS_FAILED, STATUS_CANCELLED:
+		sets = keys[3:4]
+	case "":
+		sets = keys[:4]
+	default:
+		return jobs, nil
+	}
+
+	skip := int64(offset)
+	for _, set := range sets {
+		filtered := status != "" && set == keys[3]
+
+		// whole sets before the offset are skipped by their size, a filtered set is skipped job by job
+		start := int64(0)
+		if !filtered {
+			size, err := s.client.ZCard(ctx, set).Result()
+			if err != nil {
+				return nil, err
+			}
+
+			if skip >= size {
+				skip -= size
+				continue
+			}
+			start, skip = skip, 0
+		}
+
+		for {
+			count := int64(LIST_PAGE)
+			if !filtered && limit > 0 {
+				count = int64(limit - len(jobs))
+			}
+
+			page, err := s.page(ctx, set, set == keys[3], start, count)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, job := range page {
+				// finished between the reads, or expired
+				if job == nil || (status != "" && job.Status != status) {
+					continue
+				}
+
+				if skip > 0 {
+					skip--
+					continue
+				}
+
+				jobs = append(jobs, *job)
+				if limit > 0 && len(jobs) == limit {
+					return jobs, nil
+				}
+			}
+
+			if int64(len(page)) < count {
+				break
+			}
+			start += count
+		}
+	}
+
+	return jobs, nil
+}
+
+// page reads count jobs of the set from start, newest first when reversed, nil for the jobs gone meanwhile
+func (s *redisStore) page(ctx context.Context, set string, reversed bool, start, count int64) ([]*Job, error) {
+	var ids []string
+	var err error
+	if reversed {
+		ids, err = s.client.ZRevRange(ctx, set, start, start+count-1).Result()
+	} else {
+		ids, err = s.client.ZRange(ctx, set, start, start+count-1).Result()
+	}
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+
+	cmds, err := s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, id := range ids {
+			pipe.HGetAll(ctx, s.job(id))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	jobs := make([]*Job, len(cmds))
+	for i, cmd := range cmds {
+		if hash := cmd.(*redis.MapStringStringCmd).Val(); len(hash) > 0 {
+			jobs[i] = parse(hash)
+		}
+	}
+
+	return jobs, nil
+}
+
+// keys returns the ready, delayed, running and finished sets of the queue, the unique keys and the job
+func (s *redisStore) keys(queue, id string) []string {
+	prefix := s.key + ":" + queue
+	return []string{prefix + ":ready", prefix + ":delayed", prefix + ":running", prefix + ":finished", s.key + ":unique", s.job(id)}
+}
+
+func (s *redisStore) job(id string) string {
+	return s.key + ":job:" + id
+}
+
+func fields(job *Job) map[string]any {
+	unique := ""
+	if job.UniqueKey != nil {
+		unique = *job.UniqueKey
+	}
+
+	finished := ""
+	if job.FinishedAt != nil {
+		finished = strconv.FormatInt(millis(*job.FinishedAt), 10)
+	}
+
+	return map[string]any{
+		"id":           job.ID,
+		"queue":        job.Queue,
+		"type":         job.Type,
+		"payload":      job.Payload,
+		"priority":     job.Priority,
+		"unique_key":   unique,
+		"status":       job.Status,
+		"attempts":     job.Attempts,
+		"max_attempts": job.MaxAttempts,
+		"last_error":   job.LastError,
+		"token":        job.Token,
+		"available_at": millis(job.AvailableAt),
+		"created_at":   millis(job.CreatedAt),
+		"updated_at":   millis(job.UpdatedAt),
+		"finished_at":  finished,
+	}
+}
+
+func parse(hash map[string]string) *Job {
+	job := &Job{
+		ID:          hash["id"],
+		Queue:       hash["queue"],
+		Type:        hash["type"],
+		Payload:     hash["payload"],
+		Priority:    int(number(hash["priority"])),
+		Status:      hash["status"],
+		Attempts:    int(number(hash["attempts"])),
+		MaxAttempts: int(number(hash["max_attempts"])),
+		LastError:   hash["last_error"],
+		Token:       hash["token"],
+		AvailableAt: time.UnixMilli(number(hash["available_at"])),
+		CreatedAt:   time.UnixMilli(number(hash["created_at"])),
+		UpdatedAt:   time.UnixMilli(number(hash["updated_at"])),
+	}
+
+	if unique := hash["unique_key"]; unique != "" {
+		job.UniqueKey = &unique
+	}
+
+	if finished := hash["finished_at"]; finished != "" {
+		at := time.UnixMilli(number(finished))
+		job.FinishedAt = &at
+	}
+
+	return job
+}
+
+// number parses the integers written by the scripts, which lua may format as floats
+func number(value string) int64 {
+	n, _ := strconv.ParseFloat(value, 64)
+	return int64(n)
+}
+
+func millis(t time.Time) int64 {
+	return t.UnixMilli()
+}
+
+// NewRedisStore creates a store shared by the replicas under the key, QUEUE_KEY when empty.
+// The key is wrapped in a hash tag, so the jobs and their indexes share a cluster slot.
+// Finished jobs are kept for RETENTION.
+func NewRedisStore(client redis.UniversalClient, key string) *redisStore {
+	if key == "" {
+		key = QUEUE_KEY
+	} else if !strings.HasPrefix(key, "{") {
+		key = "{" + key + "}"
+	}
+
+	return &redisStore{client, key, RETENTION}
+}
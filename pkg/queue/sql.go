@@ -0,0 +1,269 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/QubelyLabs/bedrock/pkg/db"
+	"github.com/QubelyLabs/bedrock/pkg/migration"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	TABLE = "queue_jobs"
+)
+
+// sqlStore keeps the jobs in the queue_jobs table, workers on several replicas take jobs with SELECT ... FOR UPDATE SKIP LOCKED
+type sqlStore struct {
+	db *gorm.DB
+}
+
+func (s *sqlStore) Enqueue(ctx context.Context, job *Job) (*Job, error) {
+	if job.UniqueKey != nil {
+		if existing, err := s.unique(ctx, *job.UniqueKey); err != nil || existing != nil {
+			return existing, err
+		}
+	}
+
+	if err := s.sql(ctx).Create(job).Error; err != nil {
+		// a concurrent enqueue of the same unique key won the insert
+		if job.UniqueKey != nil {
+			if existing, _ := s.unique(ctx, *job.UniqueKey); existing != nil {
+				return existing, ErrDuplicate
+			}
+		}
+
+		return nil, err
+	}
+
+	return job, nil
+}
+
+// unique returns the pending or running job holding the key with ErrDuplicate, finished jobs release their key
+func (s *sqlStore) unique(ctx context.Context, key string) (*Job, error) {
+	existing := &Job{}
+	err := db.Primary(s.sql(ctx)).Where("unique_key = ?", key).First(existing).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return existing, ErrDuplicate
+}
+
+func (s *sqlStore) Dequeue(ctx context.Context, queue string, visibility time.Duration) (*Job, error) {
+	var job *Job
+
+	err := s.sql(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+
+		// jobs whose worker died on their last attempt are not taken again
+		err := tx.Model(&Job{}).
+			Where("queue = ? AND status = ? AND available_at <= ? AND attempts >= max_attempts", queue, STATUS_RUNNING, now).
+			Updates(map[string]any{
+				"status":      STATUS_FAILED,
+				"last_error":  "visibility timeout expired",
+				"unique_key":  nil,
+				"token":       "",
+				"finished_at": now,
+				"updated_at":  now,
+			}).Error
+		if err != nil {
+			return err
+		}
+
+		// a running job past its visibility deadline lost its worker, so it is taken again
+		query := tx.Where("queue = ? AND status IN ? AND available_at <= ?", queue, []string{STATUS_PENDING, STATUS_RUNNING}, now).
+			Order("priority desc, available_at, created_at").
+			Limit(1)
+
+		// sqlite has no row locks, its single writer serializes the workers instead
+		if tx.Dialector.Name() != db.SQLite {
+			query = query.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"})
+		}
+
+		var jobs []Job
+		if err := query.Find(&jobs).Error; err != nil {
+			return err
+		}
+
+		if len(jobs) == 0 {
+			return nil
+		}
+
+		job = &jobs[0]
+		job.Status = STATUS_RUNNING
+		job.Attempts++
+		job.Token = uuid.New().String()
+		job.AvailableAt = now.Add(visibility)
+		job.UpdatedAt = now
+
+		return tx.Model(job).Select("status", "attempts", "token", "available_at", "updated_at").Updates(job).Error
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return job, nil
+}
+
+func (s *sqlStore) Extend(ctx context.Context, job *Job, visibility time.Duration) error {
+	now := time.Now()
+	err := s.leased(ctx, job, map[string]any{"available_at": now.Add(visibility), "updated_at": now})
+	if err == nil {
+		job.AvailableAt = now.Add(visibility)
+	}
+
+	return err
+}
+
+func (s *sqlStore) Complete(ctx context.Context, job *Job) error {
+	return s.finish(ctx, job, STATUS_SUCCEEDED, "")
+}
+
+func (s *sqlStore) Retry(ctx context.Context, job *Job, at time.Time, cause error) error {
+	err := s.leased(ctx, job, map[string]any{
+		"status":       STATUS_PENDING,
+		"last_error":   cause.Error(),
+		"token":        "",
+		"available_at": at,
+		"updated_at":   time.Now(),
+	})
+	if err == nil {
+		job.Status, job.LastError, job.Token, job.AvailableAt = STATUS_PENDING, cause.Error(), "", at
+	}
+
+	return err
+}
+
+func (s *sqlStore) Fail(ctx context.Context, job *Job, cause error) error {
+	return s.finish(ctx, job, STATUS_FAILED, cause.Error())
+}
+
+func (s *sqlStore) finish(ctx context.Context, job *Job, status, lastError string) error {
+	now := time.Now()
+	err := s.leased(ctx, job, map[string]any{
+		"status":      status,
+		"last_error":  lastError,
+		"unique_key":  nil,
+		"token":       "",
+		"finished_at": now,
+		"updated_at":  now,
+	})
+	if err == nil {
+		job.Status, job.LastError, job.UniqueKey, job.Token, job.FinishedAt = status, lastError, nil, "", &now
+	}
+
+	return err
+}
+
+// leased updates the running job while the worker still holds its lease
+func (s *sqlStore) leased(ctx context.Context, job *Job, values map[string]any) error {
+	result := s.sql(ctx).Model(&Job{}).
+		Where("id = ? AND status = ? AND token = ?", job.ID, STATUS_RUNNING, job.Token).
+		Updates(values)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrLeaseLost
+	}
+
+	return nil
+}
+
+func (s *sqlStore) Cancel(ctx context.Context, id string) error {
+	now := time.Now()
+	result := s.sql(ctx).Model(&Job{}).
+		Where("id = ? AND status = ?", id, STATUS_PENDING).
+		Updates(map[string]any{
+			"status":      STATUS_CANCELLED,
+			"unique_key":  nil,
+			"finished_at": now,
+			"updated_at":  now,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		if _, err := s.Get(ctx, id); err != nil {
+			return err
+		}
+
+		return ErrNotPending
+	}
+
+	return nil
+}
+
+func (s *sqlStore) Get(ctx context.Context, id string) (*Job, error) {
+	job := &Job{}
+	err := db.Primary(s.sql(ctx)).Where("id = ?", id).First(job).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrJobNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	return job, nil
+}
+
+// List returns pending jobs in dequeue order and the others latest first, an empty status returns every job
+func (s *sqlStore) List(ctx context.Context, queue, status string, limit, offset int) ([]Job, error) {
+	query := s.sql(ctx).Where("queue = ?", queue)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	if status == STATUS_PENDING {
+		query = query.Order("priority desc, available_at, created_at")
+	} else {
+		query = query.Order("updated_at desc")
+	}
+
+	jobs := []Job{}
+	err := query.Limit(limit).Offset(offset).Find(&jobs).Error
+	return jobs, err
+}
+
+// Prune deletes the jobs finished before the time
+func (s *sqlStore) Prune(ctx context.Context, before time.Time) (int64, error) {
+	result := s.sql(ctx).
+		Where("status IN ? AND finished_at < ?", []string{STATUS_SUCCEEDED, STATUS_FAILED, STATUS_CANCELLED}, before).
+		Delete(&Job{})
+	return result.RowsAffected, result.Error
+}
+
+func (s *sqlStore) sql(ctx context.Context) *gorm.DB {
+	if s.db != nil {
+		return s.db.WithContext(ctx)
+	}
+
+	return db.SQL().WithContext(ctx)
+}
+
+// NewSQLStore creates a store on the queue_jobs table of the database, nil uses db.SQL() at run time
+func NewSQLStore(d *gorm.DB) *sqlStore {
+	return &sqlStore{d}
+}
+
+// Migration creates the table of the sql store
+func Migration(version string) *migration.Migration {
+	return &migration.Migration{
+		Version: version,
+		Name:    "create queue jobs",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&Job{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&Job{})
+		},
+	}
+}
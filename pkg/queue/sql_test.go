@@ -0,0 +1,227 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/QubelyLabs/bedrock/pkg/config"
+	"github.com/QubelyLabs/bedrock/pkg/db"
+	"gorm.io/gorm"
+)
+
+// the sql store runs against sqlite, so it needs no database server
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "bedrock-queue")
+	if err != nil {
+		panic(err)
+	}
+
+	code := func() int {
+		defer os.RemoveAll(dir)
+
+		err := db.InitSQL(&config.Config{
+			DBDialect:  "sqlite",
+			DBName:     filepath.Join(dir, "test.db"),
+			DBLogLevel: "silent",
+		})
+		if err != nil {
+			panic(err)
+		}
+		defer db.CloseSQL()
+
+		if err := Migration("1").Up(db.SQL()); err != nil {
+			panic(err)
+		}
+
+		return m.Run()
+	}()
+
+	os.Exit(code)
+}
+
+func newSQLStore(t *testing.T) *sqlStore {
+	t.Helper()
+
+	if err := db.SQL().Session(&gorm.Session{AllowGlobalUpdate: true}).Delete(&Job{}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	return NewSQLStore(nil)
+}
+
+func enqueue(t *testing.T, s Store, jobType string, opts *Options) *Job {
+	t.Helper()
+
+	job, err := NewJob(jobType, map[string]string{"type": jobType}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	job, err = s.Enqueue(context.Background(), job)
+	if err != nil {
+		t.Fatalf("Enqueue(%v): %v", jobType, err)
+	}
+
+	return job
+}
+
+func dequeue(t *testing.T, s Store, visibility time.Duration) *Job {
+	t.Helper()
+
+	job, err := s.Dequeue(context.Background(), DEFAULT_QUEUE, visibility)
+	if err != nil {
+		t.Fatalf("Dequeue: %v", err)
+	}
+
+	return job
+}
+
+func TestSQLUniqueKey(t *testing.T) {
+	ctx := context.Background()
+	s := newSQLStore(t)
+
+	first := enqueue(t, s, "send", &Options{UniqueKey: "user-1"})
+
+	job, _ := NewJob("send", nil, &Options{UniqueKey: "user-1"})
+	existing, err := s.Enqueue(ctx, job)
+	if !errors.Is(err, ErrDuplicate) {
+		t.Fatalf("Enqueue of a pending key: got %v, want ErrDuplicate", err)
+	}
+	if existing == nil || existing.ID != first.ID {
+		t.Fatalf("Enqueue of a pending key returned %+v, want the pending job %v", existing, first.ID)
+	}
+
+	running := dequeue(t, s, time.Minute)
+	if _, err := s.Enqueue(ctx, job); !errors.Is(err, ErrDuplicate) {
+		t.Fatalf("Enqueue of a running key: got %v, want ErrDuplicate", err)
+	}
+
+	if err := s.Complete(ctx, running); err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+
+	again, err := s.Enqueue(ctx, job)
+	if err != nil {
+		t.Fatalf("Enqueue after the job finished: %v", err)
+	}
+	if again.ID == first.ID {
+		t.Error("Enqueue after the job finished returned the finished job")
+	}
+}
+
+func TestSQLDequeueOrder(t *testing.T) {
+	s := newSQLStore(t)
+
+	enqueue(t, s, "low", &Options{Priority: -1})
+	enqueue(t, s, "normal", nil)
+	enqueue(t, s, "high", &Options{Priority: 10})
+	enqueue(t, s, "later", &Options{Priority: 20, RunAt: time.Now().Add(time.Hour)})
+
+	for _, want := range []string{"high", "normal", "low"} {
+		job := dequeue(t, s, time.Minute)
+		if job == nil || job.Type != want {
+			t.Fatalf("Dequeue = %+v, want %v", job, want)
+		}
+		if job.Status != STATUS_RUNNING || job.Attempts != 1 || job.Token == "" {
+			t.Errorf("Dequeue did not lease %v: %+v", want, job)
+		}
+	}
+
+	if job := dequeue(t, s, time.Minute); job != nil {
+		t.Errorf("Dequeue took %v before its run time", job.Type)
+	}
+}
+
+func TestSQLVisibilityTimeout(t *testing.T) {
+	ctx := context.Background()
+	s := newSQLStore(t)
+
+	enqueue(t, s, "send", nil)
+
+	stale := dequeue(t, s, 10*time.Millisecond)
+	if job := dequeue(t, s, time.Minute); job != nil {
+		t.Fatal("Dequeue took a job still leased")
+	}
+
+	time.Sleep(20 * time.Millisecond)
+
+	taken := dequeue(t, s, time.Minute)
+	if taken == nil || taken.ID != stale.ID {
+		t.Fatalf("Dequeue after the visibility timeout = %+v, want %v", taken, stale.ID)
+	}
+	if taken.Attempts != 2 || taken.Token == stale.Token {
+		t.Errorf("Dequeue after the visibility timeout did not renew the lease: %+v", taken)
+	}
+
+	if err := s.Complete(ctx, stale); !errors.Is(err, ErrLeaseLost) {
+		t.Errorf("Complete from the old worker: got %v, want ErrLeaseLost", err)
+	}
+	if err := s.Extend(ctx, stale, time.Minute); !errors.Is(err, ErrLeaseLost) {
+		t.Errorf("Extend from the old worker: got %v, want ErrLeaseLost", err)
+	}
+
+	if err := s.Complete(ctx, taken); err != nil {
+		t.Errorf("Complete from the new worker: %v", err)
+	}
+}
+
+func TestSQLExpiredOnLastAttempt(t *testing.T) {
+	ctx := context.Background()
+	s := newSQLStore(t)
+
+	job := enqueue(t, s, "send", &Options{MaxAttempts: 1, UniqueKey: "user-1"})
+	dequeue(t, s, 10*time.Millisecond)
+
+	time.Sleep(20 * time.Millisecond)
+
+	if taken := dequeue(t, s, time.Minute); taken != nil {
+		t.Fatalf("Dequeue took %+v past its last attempt", taken)
+	}
+
+	found, err := s.Get(ctx, job.ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if found.Status != STATUS_FAILED || found.UniqueKey != nil || found.FinishedAt == nil {
+		t.Errorf("job expired on its last attempt = %+v, want failed with its key released", found)
+	}
+}
+
+func TestSQLCancel(t *testing.T) {
+	ctx := context.Background()
+	s := newSQLStore(t)
+
+	pending := enqueue(t, s, "pending", &Options{Priority: -1})
+	running := enqueue(t, s, "running", nil)
+	dequeue(t, s, time.Minute)
+
+	if err := s.Cancel(ctx, pending.ID); err != nil {
+		t.Fatalf("Cancel of a pending job: %v", err)
+	}
+	if found, _ := s.Get(ctx, pending.ID); found.Status != STATUS_CANCELLED {
+		t.Errorf("Cancel left the job %v", found.Status)
+	}
+
+	tests := []struct {
+		name string
+		id   string
+		want error
+	}{
+		{"running", running.ID, ErrNotPending},
+		{"cancelled", pending.ID, ErrNotPending},
+		{"unknown", "missing", ErrJobNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := s.Cancel(ctx, tt.id); !errors.Is(err, tt.want) {
+				t.Errorf("Cancel: got %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -1,7 +1,12 @@
 package repository
 
 import (
+	"fmt"
+
+	"github.com/QubelyLabs/bedrock/pkg/contract"
+	"github.com/QubelyLabs/bedrock/pkg/db"
 	"github.com/QubelyLabs/bedrock/pkg/injection"
+	"github.com/QubelyLabs/bedrock/pkg/integration"
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
@@ -12,64 +17,88 @@ func (r *Repository[E]) SQL(c *gin.Context) *gorm.DB {
 	return injection.GetSQL(c)
 }
 
+// reader returns the database for reads, outside a transaction they go to a read replica
+// unless the request or its user wrote within the sticky window
+func (r *Repository[E]) reader(c *gin.Context) *gorm.DB {
+	tx := r.SQL(c).WithContext(c.Request.Context())
+	if isSticky(c) {
+		return db.Primary(tx)
+	}
+
+	return tx
+}
+
 func (r *Repository[E]) UpsertOne(c *gin.Context, entity *E) error {
 	err := r.SQL(c).WithContext(c.Request.Context()).Save(entity).Error
 	if err != nil {
+		r.breadcrumb(c, "UpsertOne", err)
 		return err
 	}
 
+	markWrite(c)
 	return nil
 }
 
 func (r *Repository[E]) UpsertMany(c *gin.Context, entities ...E) error {
 	err := r.SQL(c).WithContext(c.Request.Context()).Save(entities).Error
 	if err != nil {
+		r.breadcrumb(c, "UpsertMany", err)
 		return err
 	}
 
+	markWrite(c)
 	return nil
 }
 
 func (r *Repository[E]) CreateOne(c *gin.Context, entity *E) error {
 	err := r.SQL(c).WithContext(c.Request.Context()).Create(entity).Error
 	if err != nil {
+		r.breadcrumb(c, "CreateOne", err)
 		return err
 	}
 
+	markWrite(c)
 	return nil
 }
 
 func (r *Repository[E]) CreateMany(c *gin.Context, entities ...E) error {
 	err := r.SQL(c).WithContext(c.Request.Context()).Create(entities).Error
 	if err != nil {
+		r.breadcrumb(c, "CreateMany", err)
 		return err
 	}
 
+	markWrite(c)
 	return nil
 }
 
 func (r *Repository[E]) UpdateOne(c *gin.Context, id string, entity *E) error {
 	err := r.SQL(c).WithContext(c.Request.Context()).Where("id = ?", id).Updates(entity).Error
 	if err != nil {
+		r.breadcrumb(c, "UpdateOne", err)
 		return err
 	}
 
+	markWrite(c)
 	return nil
 }
 
 func (r *Repository[E]) UpdateMany(c *gin.Context, entity *E, query any, args ...any) error {
 	err := r.SQL(c).WithContext(c.Request.Context()).Where(query, args...).Updates(entity).Error
 	if err != nil {
+		r.breadcrumb(c, "UpdateMany", err)
 		return err
 	}
 
+	markWrite(c)
 	return nil
 }
 
 func (r *Repository[E]) FindOne(c *gin.Context, id string) (E, error) {
 	entity := new(E)
-	err := r.SQL(c).WithContext(c.Request.Context()).Where("id = ?", id).First(entity).Error
+	err := r.reader(c).Where("id = ?", id).First(entity).Error
 	if err != nil {
+		r.breadcrumb(c, "FindOne", err)
 		return *entity, err
 	}
 
@@ -86,8 +115,28 @@ func (r *Repository[E]) FindAll(c *gin.Context) ([]E, error) {
 
 func (r *Repository[E]) FindManyWithLimit(c *gin.Context, limit int, offset int, query any, args ...any) ([]E, error) {
 	entities := new([]E)
-	err := r.SQL(c).WithContext(c.Request.Context()).Where(query, args...).Limit(limit).Offset(offset).Find(entities).Error
+	err := r.reader(c).Where(query, args...).Limit(limit).Offset(offset).Find(entities).Error
+	if err != nil {
+		r.breadcrumb(c, "FindManyWithLimit", err)
+		return nil, err
+	}
+
+	return *entities, nil
+}
+
+// Search finds entities where any of the columns contains term, matching is case insensitive on every dialect
+func (r *Repository[E]) Search(c *gin.Context, columns []string, term string, limit int, offset int) ([]E, error) {
+	entities := new([]E)
+	tx := r.reader(c)
+
+	conditions := tx.Session(&gorm.Session{NewDB: true})
+	for _, column := range columns {
+		conditions = conditions.Or(db.Like(tx, column), db.Contains(term))
+	}
+
+	err := tx.Where(conditions).Limit(limit).Offset(offset).Find(entities).Error
 	if err != nil {
+		r.breadcrumb(c, "Search", err)
 		return nil, err
 	}
 
@@ -98,9 +147,11 @@ func (r *Repository[E]) DeleteOne(c *gin.Context, id string) error {
 	entity := new(E)
 	err := r.SQL(c).WithContext(c.Request.Context()).Where("id = ?", id).Delete(entity).Error
 	if err != nil {
+		r.breadcrumb(c, "DeleteOne", err)
 		return err
 	}
 
+	markWrite(c)
 	return nil
 }
 
@@ -108,18 +159,35 @@ func (r *Repository[E]) DeleteMany(c *gin.Context, query any, args ...any) error
 	entity := new(E)
 	err := r.SQL(c).WithContext(c.Request.Context()).Where(query, args...).Delete(entity).Error
 	if err != nil {
+		r.breadcrumb(c, "DeleteMany", err)
 		return err
 	}
 
+	markWrite(c)
 	return nil
 }
 
 func (r *Repository[E]) Count(c *gin.Context, query any, args ...any) (i int64, err error) {
 	entity := new(E)
-	err = r.SQL(c).WithContext(c.Request.Context()).Where(query, args...).Model(entity).Count(&i).Error
+	err = r.reader(c).Where(query, args...).Model(entity).Count(&i).Error
+	if err != nil {
+		r.breadcrumb(c, "Count", err)
+	}
 	return
 }
 
+// breadcrumb records a failed query on the request, so it is attached to the error reported for it
+func (r *Repository[E]) breadcrumb(c *gin.Context, operation string, err error) {
+	integration.Breadcrumb(c, "repository", fmt.Sprintf("%T.%v failed", *new(E), operation), map[string]any{
+		"error": err.Error(),
+	})
+}
+
 func NewRepository[E any]() *Repository[E] {
 	return &Repository[E]{}
 }
+
+var (
+	_ contract.Repository[struct{}] = (*Repository[struct{}])(nil)
+	_ contract.Searcher[struct{}]   = (*Repository[struct{}])(nil)
+)
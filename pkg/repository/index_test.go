@@ -0,0 +1,317 @@
+package repository_test
+
+import (
+	"errors"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/QubelyLabs/bedrock/pkg/config"
+	"github.com/QubelyLabs/bedrock/pkg/db"
+	"github.com/QubelyLabs/bedrock/pkg/injection"
+	"github.com/QubelyLabs/bedrock/pkg/repository"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// the repository contract runs against sqlite, so it needs no database server
+
+type widget struct {
+	repository.Entity
+	Name     string `gorm:"size:255" json:"name"`
+	SKU      string `gorm:"size:64;uniqueIndex" json:"sku"`
+	Stock    int    `json:"stock"`
+	Metadata string `gorm:"type:text" json:"metadata"`
+}
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+
+	dir, err := os.MkdirTemp("", "bedrock-repository")
+	if err != nil {
+		panic(err)
+	}
+
+	code := func() int {
+		defer os.RemoveAll(dir)
+
+		err := db.InitSQL(&config.Config{
+			DBDialect:  "sqlite",
+			DBName:     filepath.Join(dir, "test.db"),
+			DBLogLevel: "silent",
+		})
+		if err != nil {
+			panic(err)
+		}
+		defer db.CloseSQL()
+
+		if err := db.SQL().AutoMigrate(&widget{}); err != nil {
+			panic(err)
+		}
+
+		return m.Run()
+	}()
+
+	os.Exit(code)
+}
+
+func newContext(t *testing.T) *gin.Context {
+	t.Helper()
+
+	if err := db.SQL().Session(&gorm.Session{AllowGlobalUpdate: true}).Unscoped().Delete(&widget{}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", "/", nil)
+	injection.SetSQL(c, db.SQL())
+
+	return c
+}
+
+func create(t *testing.T, c *gin.Context, r *repository.Repository[widget], widgets ...widget) []widget {
+	t.Helper()
+
+	for i := range widgets {
+		if err := r.CreateOne(c, &widgets[i]); err != nil {
+			t.Fatalf("CreateOne(%v): %v", widgets[i].Name, err)
+		}
+	}
+
+	return widgets
+}
+
+func TestDialect(t *testing.T) {
+	if got := db.Dialect(); got != db.SQLite {
+		t.Fatalf("Dialect() = %v, want %v", got, db.SQLite)
+	}
+}
+
+func TestCRUD(t *testing.T) {
+	c := newContext(t)
+	r := repository.NewRepository[widget]()
+
+	created := create(t, c, r, widget{Name: "Bolt", SKU: "B-1", Stock: 3})[0]
+	if created.ID == "" || created.CreatedAt == nil {
+		t.Fatalf("CreateOne did not set the id and creation time: %+v", created)
+	}
+
+	found, err := r.FindOne(c, created.ID)
+	if err != nil {
+		t.Fatalf("FindOne: %v", err)
+	}
+	if found.Name != "Bolt" || found.Stock != 3 {
+		t.Errorf("FindOne = %+v, want the created widget", found)
+	}
+
+	if err := r.UpdateOne(c, created.ID, &widget{Stock: 7}); err != nil {
+		t.Fatalf("UpdateOne: %v", err)
+	}
+	found, _ = r.FindOne(c, created.ID)
+	if found.Stock != 7 || found.Name != "Bolt" {
+		t.Errorf("after UpdateOne = %+v, want stock 7 and the name kept", found)
+	}
+
+	if err := r.DeleteOne(c, created.ID); err != nil {
+		t.Fatalf("DeleteOne: %v", err)
+	}
+	if _, err := r.FindOne(c, created.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("FindOne after DeleteOne: got %v, want gorm.ErrRecordNotFound", err)
+	}
+}
+
+func TestManyOperations(t *testing.T) {
+	c := newContext(t)
+	r := repository.NewRepository[widget]()
+
+	if err := r.CreateMany(c, widget{Name: "Nut", SKU: "N-1", Stock: 1}, widget{Name: "Nut", SKU: "N-2", Stock: 2}, widget{Name: "Gear", SKU: "G-1", Stock: 5}); err != nil {
+		t.Fatalf("CreateMany: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		query any
+		args  []any
+		want  int
+	}{
+		{"all", nil, nil, 3},
+		{"by name", "name = ?", []any{"Nut"}, 2},
+		{"by stock", "stock > ?", []any{1}, 2},
+		{"none", "name = ?", []any{"Washer"}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			found, err := r.FindMany(c, tt.query, tt.args...)
+			if err != nil {
+				t.Fatalf("FindMany: %v", err)
+			}
+			if len(found) != tt.want {
+				t.Errorf("FindMany returned %v widgets, want %v", len(found), tt.want)
+			}
+
+			count, err := r.Count(c, tt.query, tt.args...)
+			if err != nil {
+				t.Fatalf("Count: %v", err)
+			}
+			if count != int64(tt.want) {
+				t.Errorf("Count = %v, want %v", count, tt.want)
+			}
+		})
+	}
+
+	page, err := r.FindManyWithLimit(c, 2, 1, nil)
+	if err != nil || len(page) != 2 {
+		t.Errorf("FindManyWithLimit(2, 1) returned %v widgets and %v, want 2", len(page), err)
+	}
+
+	if err := r.UpdateMany(c, &widget{Stock: 9}, "name = ?", "Nut"); err != nil {
+		t.Fatalf("UpdateMany: %v", err)
+	}
+	if count, _ := r.Count(c, "stock = ?", 9); count != 2 {
+		t.Errorf("UpdateMany updated %v widgets, want 2", count)
+	}
+
+	if err := r.DeleteMany(c, "name = ?", "Nut"); err != nil {
+		t.Fatalf("DeleteMany: %v", err)
+	}
+	if all, _ := r.FindAll(c); len(all) != 1 || all[0].Name != "Gear" {
+		t.Errorf("FindAll after DeleteMany = %+v, want only the gear", all)
+	}
+}
+
+func TestUpsert(t *testing.T) {
+	c := newContext(t)
+	r := repository.NewRepository[widget]()
+
+	existing := create(t, c, r, widget{Name: "Bolt", SKU: "B-1", Stock: 3})[0]
+
+	existing.Stock = 4
+	if err := r.UpsertOne(c, &existing); err != nil {
+		t.Fatalf("UpsertOne of an existing widget: %v", err)
+	}
+
+	added := widget{Name: "Nut", SKU: "N-1", Stock: 1}
+	if err := r.UpsertOne(c, &added); err != nil {
+		t.Fatalf("UpsertOne of a new widget: %v", err)
+	}
+
+	all, _ := r.FindAll(c)
+	if len(all) != 2 {
+		t.Fatalf("UpsertOne left %v widgets, want 2", len(all))
+	}
+	if found, _ := r.FindOne(c, existing.ID); found.Stock != 4 {
+		t.Errorf("UpsertOne did not update the existing widget, stock %v", found.Stock)
+	}
+
+	existing.Stock, added.Stock = 10, 20
+	if err := r.UpsertMany(c, existing, added); err != nil {
+		t.Fatalf("UpsertMany: %v", err)
+	}
+	if count, _ := r.Count(c, "stock IN ?", []int{10, 20}); count != 2 {
+		t.Errorf("UpsertMany updated %v widgets, want 2", count)
+	}
+}
+
+func TestUpsertConflictClause(t *testing.T) {
+	c := newContext(t)
+	r := repository.NewRepository[widget]()
+
+	create(t, c, r, widget{Name: "Bolt", SKU: "B-1", Stock: 3})
+
+	conflicting := widget{Name: "Bolt v2", SKU: "B-1", Stock: 8}
+	if err := r.SQL(c).Clauses(db.Upsert("sku")).Create(&conflicting).Error; err != nil {
+		t.Fatalf("Create with db.Upsert(sku): %v", err)
+	}
+
+	all, _ := r.FindAll(c)
+	if len(all) != 1 {
+		t.Fatalf("the conflicting insert left %v widgets, want 1", len(all))
+	}
+	if all[0].Name != "Bolt v2" || all[0].Stock != 8 {
+		t.Errorf("the conflicting insert did not update the row: %+v", all[0])
+	}
+}
+
+func TestSearch(t *testing.T) {
+	c := newContext(t)
+	r := repository.NewRepository[widget]()
+
+	create(t, c, r,
+		widget{Name: "Steel Bolt", SKU: "B-1"},
+		widget{Name: "Brass bolt", SKU: "B-2"},
+		widget{Name: "Nut", SKU: "N_1"},
+		widget{Name: "Nut 100%", SKU: "N-2"},
+		widget{Name: "Nut 1000", SKU: "N-3"},
+	)
+
+	tests := []struct {
+		name    string
+		columns []string
+		term    string
+		want    []string
+	}{
+		{"case insensitive", []string{"name"}, "BOLT", []string{"Brass bolt", "Steel Bolt"}},
+		{"any column", []string{"name", "sku"}, "b-2", []string{"Brass bolt"}},
+		{"escaped percent", []string{"name"}, "100%", []string{"Nut 100%"}},
+		{"escaped underscore", []string{"sku"}, "N_", []string{"Nut"}},
+		{"no match", []string{"name"}, "washer", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			found, err := r.Search(c, tt.columns, tt.term, 10, 0)
+			if err != nil {
+				t.Fatalf("Search: %v", err)
+			}
+
+			names := []string{}
+			for _, w := range found {
+				names = append(names, w.Name)
+			}
+			sort.Strings(names)
+
+			if len(names) != len(tt.want) {
+				t.Fatalf("Search(%q) = %v, want %v", tt.term, names, tt.want)
+			}
+			for i := range names {
+				if names[i] != tt.want[i] {
+					t.Errorf("Search(%q) = %v, want %v", tt.term, names, tt.want)
+				}
+			}
+		})
+	}
+
+	if page, _ := r.Search(c, []string{"name"}, "nut", 2, 1); len(page) != 2 {
+		t.Errorf("Search with a limit and offset returned %v widgets, want 2", len(page))
+	}
+}
+
+func TestJSONExtract(t *testing.T) {
+	c := newContext(t)
+	r := repository.NewRepository[widget]()
+
+	create(t, c, r,
+		widget{Name: "Bolt", SKU: "B-1", Metadata: `{"billing":{"plan":"pro"}}`},
+		widget{Name: "Nut", SKU: "N-1", Metadata: `{"billing":{"plan":"free"}}`},
+	)
+
+	expression, err := db.JSONExtract(r.SQL(c), "metadata", "billing.plan")
+	if err != nil {
+		t.Fatalf("JSONExtract: %v", err)
+	}
+
+	found, err := r.FindMany(c, expression+" = ?", "pro")
+	if err != nil {
+		t.Fatalf("FindMany on %v: %v", expression, err)
+	}
+	if len(found) != 1 || found[0].Name != "Bolt" {
+		t.Errorf("FindMany on %v = %+v, want the bolt", expression, found)
+	}
+
+	if _, err := db.JSONExtract(r.SQL(c), "metadata; DROP", "plan"); err == nil {
+		t.Error("JSONExtract accepted an invalid column")
+	}
+}
@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/QubelyLabs/bedrock/pkg/db"
+	"github.com/QubelyLabs/bedrock/pkg/injection"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	lastWriteContextKey = "lastWrite"
+	STICKY_KEY_PREFIX   = "db:sticky:"
+)
+
+// markWrite keeps the reads of the request, and of the user for the sticky window, on the primary
+func markWrite(c *gin.Context) {
+	if !db.HasReplicas() {
+		return
+	}
+
+	c.Set(lastWriteContextKey, time.Now())
+
+	if key, ok := stickyKey(c); ok {
+		err := db.KV().Set(c.Request.Context(), key, 1, db.StickyWindow()).Err()
+		if err != nil {
+			injection.GetLogger(c).Warn("cannot record write for read stickiness", "error", err)
+		}
+	}
+}
+
+// isSticky reports whether the request or its user wrote within the sticky window
+func isSticky(c *gin.Context) bool {
+	if !db.HasReplicas() {
+		return false
+	}
+
+	if v, ok := c.Get(lastWriteContextKey); ok {
+		if lastWrite, ok := v.(time.Time); ok && time.Since(lastWrite) < db.StickyWindow() {
+			return true
+		}
+	}
+
+	if key, ok := stickyKey(c); ok {
+		n, err := db.KV().Exists(c.Request.Context(), key).Result()
+		return err == nil && n > 0
+	}
+
+	return false
+}
+
+// stickyKey returns the redis key of the user, writes of anonymous requests only stick for the request
+func stickyKey(c *gin.Context) (string, bool) {
+	if db.KV() == nil {
+		return "", false
+	}
+
+	user, ok := injection.LookupUser(c)
+	if !ok {
+		return "", false
+	}
+
+	id, ok := user["id"].(string)
+	if !ok || id == "" {
+		return "", false
+	}
+
+	return STICKY_KEY_PREFIX + id, true
+}
@@ -4,12 +4,19 @@ import (
 	"bytes"
 	"encoding/json"
 	"net/http"
+
+	"github.com/QubelyLabs/bedrock/pkg/contract"
 )
 
 var (
 	Default = NewRequest()
 )
 
+// Authorize registers a token source for a host on the default request
+func Authorize(host string, source contract.TokenSource) {
+	Default.Authorize(host, source)
+}
+
 // Post sends a POST request with a JSON body
 func Post(url string, body interface{}, queries map[string]string, headers map[string]string, retryCount int) (*HttpResponse, error) {
 	bodyBytes, err := json.Marshal(body)
@@ -3,12 +3,23 @@ package request
 import (
 	"bytes"
 	"compress/gzip"
+	"context"
 	"encoding/json"
 	"errors"
+	"io"
 	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
 	"time"
 
+	"github.com/QubelyLabs/bedrock/pkg/contract"
+	"github.com/QubelyLabs/bedrock/pkg/metrics"
+	"github.com/QubelyLabs/bedrock/pkg/tracing"
 	"github.com/QubelyLabs/bedrock/pkg/util"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // HttpResponse represents the response structure
@@ -27,28 +38,73 @@ type DataResponse struct {
 	Data    util.Object `json:"data,omitempty"`
 }
 
+// Requester is implemented by the request client, it lets consumers swap the transport in tests
+type Requester interface {
+	Get(url string, queries map[string]string, headers map[string]string, retryCount int) (*HttpResponse, error)
+	Post(url string, body interface{}, queries map[string]string, headers map[string]string, retryCount int) (*HttpResponse, error)
+}
+
 const (
 	timeout = 10 * time.Second
 )
 
 type request struct {
 	client *http.Client
+	tokens *tokens
+	ctx    context.Context
+}
+
+// tokens holds the token sources per host, it is shared by copies of a request
+type tokens struct {
+	sources map[string]contract.TokenSource
+	mutex   sync.RWMutex
 }
 
 func NewRequest() *request {
 	return &request{
 		client: &http.Client{Timeout: timeout},
+		tokens: &tokens{sources: map[string]contract.TokenSource{}},
+		ctx:    context.Background(),
+	}
+}
+
+// WithContext returns a copy of the request bound to ctx,
+// requests made with it are cancelled with ctx and carry its trace context
+func (s *request) WithContext(ctx context.Context) *request {
+	return &request{s.client, s.tokens, ctx}
+}
+
+// Authorize registers a token source for a host, requests to the host carry an Authorization header
+// The host may include a port, in which case only requests to that port are authorized
+func (s *request) Authorize(host string, source contract.TokenSource) {
+	s.tokens.mutex.Lock()
+	defer s.tokens.mutex.Unlock()
+
+	s.tokens.sources[host] = source
+}
+
+func (s *request) tokenSource(u *url.URL) contract.TokenSource {
+	s.tokens.mutex.RLock()
+	defer s.tokens.mutex.RUnlock()
+
+	if source, ok := s.tokens.sources[u.Host]; ok {
+		return source
 	}
+
+	return s.tokens.sources[u.Hostname()]
 }
 
 func (s *request) Request(method, url string, body *bytes.Reader, queries map[string]string, headers map[string]string, retryCount int) (*HttpResponse, error) {
 	var req *http.Request
 	var err error
 
+	ctx, span := tracing.Tracer().Start(s.ctx, "HTTP "+method, trace.WithSpanKind(trace.SpanKindClient))
+	defer span.End()
+
 	if method == "GET" || body == nil {
-		req, err = http.NewRequest(method, url, nil)
+		req, err = http.NewRequestWithContext(ctx, method, url, nil)
 	} else {
-		req, err = http.NewRequest(method, url, body)
+		req, err = http.NewRequestWithContext(ctx, method, url, body)
 	}
 
 	if err != nil {
@@ -65,13 +121,57 @@ func (s *request) Request(method, url string, body *bytes.Reader, queries map[st
 		req.Header.Set(key, value)
 	}
 
+	span.SetAttributes(semconv.URLFull(req.URL.Redacted()), semconv.ServerAddress(req.URL.Hostname()))
+	tracing.InjectHeader(ctx, req.Header)
+
 	var response *http.Response
-	response, err = s.client.Do(req)
+	source := s.tokenSource(req.URL)
+	if req.Header.Get("Authorization") != "" {
+		source = nil
+	}
+
+	start := time.Now()
+	defer func() {
+		status := "error"
+		if response != nil {
+			status = strconv.Itoa(response.StatusCode)
+		}
+		metrics.ClientDuration.WithLabelValues(req.URL.Host, method, status).Observe(time.Since(start).Seconds())
+	}()
+
+	response, err = s.do(req, source)
 	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
 		return nil, err
 	}
+
+	// The token may have been revoked or rotated, retry once with a fresh one
+	if response.StatusCode == http.StatusUnauthorized && source != nil {
+		response.Body.Close()
+		source.Invalidate()
+
+		if req.Body != nil {
+			if _, err := body.Seek(0, io.SeekStart); err != nil {
+				return nil, err
+			}
+			req.Body = io.NopCloser(body)
+		}
+
+		response, err = s.do(req, source)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return nil, err
+		}
+	}
 	defer response.Body.Close()
 
+	span.SetAttributes(semconv.HTTPResponseStatusCode(response.StatusCode))
+	if response.StatusCode >= 500 {
+		span.SetStatus(codes.Error, response.Status)
+	}
+
 	data := DataResponse{}
 
 	if response.Body != nil {
@@ -100,3 +200,15 @@ func (s *request) Request(method, url string, body *bytes.Reader, queries map[st
 
 	return &HttpResponse{true, response.StatusCode, response.Status, "", "", data}, err
 }
+
+func (s *request) do(req *http.Request, source contract.TokenSource) (*http.Response, error) {
+	if source != nil {
+		token, err := source.Token(req.Context())
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	return s.client.Do(req)
+}
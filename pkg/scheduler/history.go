@@ -0,0 +1,146 @@
+package scheduler
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/QubelyLabs/bedrock/pkg/db"
+	"github.com/QubelyLabs/bedrock/pkg/migration"
+	"gorm.io/gorm"
+)
+
+const (
+	HISTORY_LIMIT     = 100
+	HISTORY_TABLE     = "scheduler_runs"
+	HISTORY_RETENTION = 30 * 24 * time.Hour
+	CLEANUP_INTERVAL  = time.Hour
+
+	STATUS_OK      = "ok"
+	STATUS_FAILED  = "failed"
+	STATUS_PANIC   = "panic"
+	STATUS_TIMEOUT = "timeout"
+)
+
+// Run is a finished run of a job
+type Run struct {
+	ID         string    `gorm:"primaryKey;size:36" json:"id"`
+	Job        string    `gorm:"size:255;not null;index:idx_scheduler_runs_job_started,priority:1" json:"job"`
+	Host       string    `gorm:"size:255" json:"host"`
+	Status     string    `gorm:"size:16;not null" json:"status"`
+	Error      string    `gorm:"type:text" json:"error,omitempty"`
+	StartedAt  time.Time `gorm:"not null;index:idx_scheduler_runs_job_started,priority:2" json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	Duration   int64     `json:"duration_ms"`
+}
+
+func (Run) TableName() string {
+	return HISTORY_TABLE
+}
+
+// HistoryStore records the runs of the jobs
+type HistoryStore interface {
+	Record(ctx context.Context, run Run) error
+	// List returns the latest runs of the job first
+	List(ctx context.Context, job string, limit int) ([]Run, error)
+}
+
+// memoryHistory keeps the latest runs of each job in the process
+type memoryHistory struct {
+	mutex sync.RWMutex
+	limit int
+	runs  map[string][]Run
+}
+
+func (h *memoryHistory) Record(ctx context.Context, run Run) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	runs := append([]Run{run}, h.runs[run.Job]...)
+	if len(runs) > h.limit {
+		runs = runs[:h.limit]
+	}
+	h.runs[run.Job] = runs
+
+	return nil
+}
+
+func (h *memoryHistory) List(ctx context.Context, job string, limit int) ([]Run, error) {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	runs := h.runs[job]
+	if limit >= 0 && limit < len(runs) {
+		runs = runs[:limit]
+	}
+
+	return append([]Run{}, runs...), nil
+}
+
+// NewMemoryHistory creates an in process history keeping the last limit runs of each job
+func NewMemoryHistory(limit int) *memoryHistory {
+	if limit <= 0 {
+		limit = HISTORY_LIMIT
+	}
+
+	return &memoryHistory{limit: limit, runs: map[string][]Run{}}
+}
+
+// sqlHistory keeps the runs in the scheduler_runs table for the retention period, the scheduler cleans it up every CLEANUP_INTERVAL
+type sqlHistory struct {
+	db        *gorm.DB
+	retention time.Duration
+}
+
+func (h *sqlHistory) Record(ctx context.Context, run Run) error {
+	return h.sql(ctx).Create(&run).Error
+}
+
+func (h *sqlHistory) List(ctx context.Context, job string, limit int) ([]Run, error) {
+	var runs []Run
+	err := h.sql(ctx).Where("job = ?", job).Order("started_at desc").Limit(limit).Find(&runs).Error
+	return runs, err
+}
+
+// Prune deletes the runs started before the time
+func (h *sqlHistory) Prune(ctx context.Context, before time.Time) (int64, error) {
+	result := h.sql(ctx).Where("started_at < ?", before).Delete(&Run{})
+	return result.RowsAffected, result.Error
+}
+
+// Cleanup deletes the runs started before the retention period
+func (h *sqlHistory) Cleanup(ctx context.Context) (int64, error) {
+	return h.Prune(ctx, time.Now().Add(-h.retention))
+}
+
+func (h *sqlHistory) sql(ctx context.Context) *gorm.DB {
+	if h.db != nil {
+		return h.db.WithContext(ctx)
+	}
+
+	return db.SQL().WithContext(ctx)
+}
+
+// NewSQLHistory creates a history on the scheduler_runs table of the database keeping the runs for the retention period.
+// A nil database uses db.SQL() at run time, a zero retention HISTORY_RETENTION.
+func NewSQLHistory(d *gorm.DB, retention time.Duration) *sqlHistory {
+	if retention <= 0 {
+		retention = HISTORY_RETENTION
+	}
+
+	return &sqlHistory{d, retention}
+}
+
+// HistoryMigration creates the table of the sql history
+func HistoryMigration(version string) *migration.Migration {
+	return &migration.Migration{
+		Version: version,
+		Name:    "create scheduler runs",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&Run{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&Run{})
+		},
+	}
+}
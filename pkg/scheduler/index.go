@@ -0,0 +1,401 @@
+// Package scheduler runs periodic jobs from cron expressions or fixed intervals.
+// With redis initialized, each tick of a job runs on a single replica.
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/QubelyLabs/bedrock/pkg/config"
+	"github.com/QubelyLabs/bedrock/pkg/db"
+	"github.com/QubelyLabs/bedrock/pkg/integration"
+	"github.com/QubelyLabs/bedrock/pkg/logger"
+	"github.com/QubelyLabs/bedrock/pkg/metrics"
+	"github.com/QubelyLabs/bedrock/pkg/tracing"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/robfig/cron/v3"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+var (
+	ErrJobExists = errors.New("scheduler: job already added")
+
+	// parser accepts standard cron expressions with an optional leading seconds field, and descriptors like @hourly or @every 5m, which ticks like Job.Every
+	parser = cron.NewParser(cron.SecondOptional | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+)
+
+// Job is a periodic task
+type Job struct {
+	Name         string
+	Schedule     string        // Cron expression, e.g. "0 3 * * *" or "@every 10m"
+	Every        time.Duration // Fixed interval, used when Schedule is empty, ticks on the multiples of the interval since the unix epoch
+	Jitter       time.Duration // Random delay before each run, up to this duration, spreads the load of the replicas
+	Timeout      time.Duration // Cancels the context of a run taking longer, none when zero
+	AllowOverlap bool          // Starts a run while the previous one is still running, skipped otherwise
+	Local        bool          // Runs on every replica instead of one per tick
+	Run          func(ctx context.Context) error
+}
+
+// Status is a job and its next run
+type Status struct {
+	Name    string    `json:"name"`
+	Next    time.Time `json:"next"`
+	Prev    time.Time `json:"prev"`
+	Running bool      `json:"running"`
+}
+
+type job struct {
+	Job
+	schedule cron.Schedule
+	entry    cron.EntryID
+	running  int32
+}
+
+// interval ticks on the multiples of its delay since the unix epoch, so every replica schedules the same instants
+type interval struct {
+	delay time.Duration
+}
+
+func (i interval) Next(t time.Time) time.Time {
+	return t.Add(i.delay - time.Duration(t.UnixNano())%i.delay)
+}
+
+type scheduler struct {
+	cron    *cron.Cron
+	kv      redis.UniversalClient
+	history HistoryStore
+	host    string
+
+	lastCleanup atomic.Int64 // Unix nanoseconds of the last history cleanup
+
+	mutex sync.Mutex
+	jobs  map[string]*job
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// Add schedules the job, jobs can be added before or after Start
+func (s *scheduler) Add(j Job) error {
+	if j.Name == "" || j.Run == nil {
+		return fmt.Errorf("scheduler: a job needs a name and a run function")
+	}
+
+	var schedule cron.Schedule
+	if j.Schedule != "" {
+		var err error
+		if schedule, err = parser.Parse(j.Schedule); err != nil {
+			return fmt.Errorf("scheduler: invalid schedule of %v: %w", j.Name, err)
+		}
+	} else if j.Every > 0 {
+		schedule = cron.Every(j.Every)
+	} else {
+		return fmt.Errorf("scheduler: job %v needs a schedule or an interval", j.Name)
+	}
+
+	if every, ok := schedule.(cron.ConstantDelaySchedule); ok {
+		schedule = interval{every.Delay}
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.jobs[j.Name]; ok {
+		return fmt.Errorf("%w: %v", ErrJobExists, j.Name)
+	}
+
+	added := &job{Job: j, schedule: schedule}
+	added.entry = s.cron.Schedule(schedule, cron.FuncJob(func() { s.tick(added) }))
+	s.jobs[j.Name] = added
+
+	return nil
+}
+
+// Remove unschedules the job, a run in progress completes
+func (s *scheduler) Remove(name string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if j, ok := s.jobs[name]; ok {
+		s.cron.Remove(j.entry)
+		delete(s.jobs, name)
+	}
+}
+
+// Jobs returns the status of the jobs, by name
+func (s *scheduler) Jobs() []Status {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	statuses := make([]Status, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		entry := s.cron.Entry(j.entry)
+		statuses = append(statuses, Status{
+			Name:    j.Name,
+			Next:    entry.Next,
+			Prev:    entry.Prev,
+			Running: atomic.LoadInt32(&j.running) > 0,
+		})
+	}
+
+	sort.Slice(statuses, func(i, k int) bool {
+		return statuses[i].Name < statuses[k].Name
+	})
+
+	return statuses
+}
+
+// History returns the latest runs of the job first
+func (s *scheduler) History(ctx context.Context, name string, limit int) ([]Run, error) {
+	if s.history == nil {
+		return []Run{}, nil
+	}
+
+	return s.history.List(ctx, name, limit)
+}
+
+// SetHistory replaces the history store, nil stops recording runs
+func (s *scheduler) SetHistory(history HistoryStore) {
+	s.history = history
+}
+
+// Start runs the jobs on their schedule in the background
+func (s *scheduler) Start() {
+	s.cron.Start()
+}
+
+// Stop stops scheduling and waits for the runs in progress, their context is cancelled when ctx is done
+func (s *scheduler) Stop(ctx context.Context) error {
+	stopped := s.cron.Stop()
+
+	select {
+	case <-stopped.Done():
+		return nil
+	case <-ctx.Done():
+		s.cancel()
+		return fmt.Errorf("scheduler: jobs still running: %w", ctx.Err())
+	}
+}
+
+// tick runs the job unless it is still running, or another replica took the tick
+func (s *scheduler) tick(j *job) {
+	log := logger.For("scheduler")
+
+	if atomic.AddInt32(&j.running, 1) > 1 && !j.AllowOverlap {
+		atomic.AddInt32(&j.running, -1)
+		log.Warn("job still running, tick skipped", "job", j.Name)
+		metrics.SchedulerRuns.WithLabelValues(j.Name, "skipped").Inc()
+		return
+	}
+	defer atomic.AddInt32(&j.running, -1)
+
+	if kv := s.client(); kv != nil && !j.Local {
+		entry := s.cron.Entry(j.entry)
+		if entry.Prev.IsZero() {
+			return
+		}
+
+		claimed, err := claimTick(s.ctx, kv, j.Name, entry.Prev, entry.Next)
+		if err != nil {
+			log.Error("cannot claim job tick, tick skipped", "job", j.Name, "error", err)
+			return
+		}
+
+		if !claimed {
+			return
+		}
+
+		if !j.AllowOverlap {
+			running, err := lockRunning(s.ctx, kv, j.Name)
+			if err != nil {
+				log.Error("cannot lock job, tick skipped", "job", j.Name, "error", err)
+				return
+			}
+
+			if running == nil {
+				log.Info("job running on another replica, tick skipped", "job", j.Name)
+				metrics.SchedulerRuns.WithLabelValues(j.Name, "skipped").Inc()
+				return
+			}
+
+			// the run is cancelled when the lease is lost, as another replica may start the job
+			running.Hold(s.ctx, func(ctx context.Context) error {
+				s.start(ctx, j)
+				return nil
+			})
+			return
+		}
+	}
+
+	s.start(s.ctx, j)
+}
+
+// start runs the job after its jitter
+func (s *scheduler) start(ctx context.Context, j *job) {
+	if j.Jitter > 0 {
+		select {
+		case <-time.After(time.Duration(rand.Int63n(int64(j.Jitter)))):
+		case <-ctx.Done():
+			return
+		}
+	}
+
+	s.run(ctx, j)
+}
+
+// run calls the job in a span with its timeout, recovers its panics and records the run
+func (s *scheduler) run(ctx context.Context, j *job) {
+	log := logger.For("scheduler")
+
+	ctx, span := tracing.Tracer().Start(ctx, "job "+j.Name)
+	defer span.End()
+
+	if j.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, j.Timeout)
+		defer cancel()
+	}
+
+	run := Run{ID: uuid.New().String(), Job: j.Name, Host: s.host, StartedAt: time.Now()}
+
+	err := s.call(ctx, j)
+	run.FinishedAt = time.Now()
+	run.Duration = run.FinishedAt.Sub(run.StartedAt).Milliseconds()
+
+	var panicked *integration.PanicError
+	switch {
+	case err == nil:
+		run.Status = STATUS_OK
+	case errors.As(err, &panicked):
+		run.Status = STATUS_PANIC
+	case errors.Is(err, context.DeadlineExceeded) || errors.Is(ctx.Err(), context.DeadlineExceeded):
+		run.Status = STATUS_TIMEOUT
+	default:
+		run.Status = STATUS_FAILED
+	}
+
+	span.SetAttributes(attribute.String("job.status", run.Status))
+	metrics.SchedulerRuns.WithLabelValues(j.Name, run.Status).Inc()
+	metrics.SchedulerDuration.WithLabelValues(j.Name).Observe(run.FinishedAt.Sub(run.StartedAt).Seconds())
+
+	if err != nil {
+		run.Error = err.Error()
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		log.Error("job failed", "job", j.Name, "status", run.Status, "duration", run.Duration, "error", err)
+	} else {
+		log.Info("job completed", "job", j.Name, "duration", run.Duration)
+	}
+
+	if s.history != nil {
+		if err := s.history.Record(context.Background(), run); err != nil {
+			log.Error("cannot record job run", "job", j.Name, "error", err)
+		}
+
+		s.cleanup()
+	}
+}
+
+// cleanup deletes the runs past the retention of the history, at most once every CLEANUP_INTERVAL
+func (s *scheduler) cleanup() {
+	cleaner, ok := s.history.(interface {
+		Cleanup(ctx context.Context) (int64, error)
+	})
+	if !ok {
+		return
+	}
+
+	last := s.lastCleanup.Load()
+	now := time.Now()
+	if now.Sub(time.Unix(0, last)) < CLEANUP_INTERVAL || !s.lastCleanup.CompareAndSwap(last, now.UnixNano()) {
+		return
+	}
+
+	if _, err := cleaner.Cleanup(context.Background()); err != nil {
+		logger.For("scheduler").Error("cannot clean up job runs", "error", err)
+	}
+}
+
+// call runs the job, a panic is reported to sentry and returned as an error
+func (s *scheduler) call(ctx context.Context, j *job) error {
+	err := integration.Recover(map[string]string{"job": j.Name}, func() error {
+		return j.Run(ctx)
+	})
+
+	var panicked *integration.PanicError
+	if errors.As(err, &panicked) {
+		logger.For("scheduler").Error("job panicked", "job", j.Name, "panic", panicked.Value, "stack", string(panicked.Stack))
+	}
+
+	return err
+}
+
+func (s *scheduler) client() redis.UniversalClient {
+	if s.kv != nil {
+		return s.kv
+	}
+
+	return db.KV()
+}
+
+// NewScheduler creates a scheduler locking its jobs on the redis client, nil uses db.KV() at run time and
+// runs every job on every replica when redis is not initialized
+func NewScheduler(kv redis.UniversalClient, history HistoryStore) *scheduler {
+	hostname, _ := os.Hostname()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &scheduler{
+		cron:    cron.New(cron.WithParser(parser)),
+		kv:      kv,
+		history: history,
+		host:    fmt.Sprintf("%v-%v", hostname, os.Getpid()),
+		jobs:    map[string]*job{},
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+var (
+	Default = NewScheduler(nil, NewMemoryHistory(HISTORY_LIMIT))
+)
+
+// Configure sets the history store of SCHEDULER_HISTORY on the default scheduler, memory by default
+func Configure(cf *config.Config) error {
+	switch strings.ToLower(cf.SchedulerHistory) {
+	case "", "memory":
+		return nil
+	case "none":
+		Default.SetHistory(nil)
+		return nil
+	case "sql":
+		Default.SetHistory(NewSQLHistory(nil, time.Duration(cf.SchedulerHistoryRetention)*time.Second))
+		return nil
+	default:
+		return fmt.Errorf("scheduler: unsupported history store %v", cf.SchedulerHistory)
+	}
+}
+
+// Add schedules the job on the default scheduler
+func Add(j Job) error {
+	return Default.Add(j)
+}
+
+// Jobs returns the status of the jobs of the default scheduler
+func Jobs() []Status {
+	return Default.Jobs()
+}
+
+// History returns the latest runs of a job of the default scheduler
+func History(ctx context.Context, name string, limit int) ([]Run, error) {
+	return Default.History(ctx, name, limit)
+}
@@ -0,0 +1,86 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestIntervalNext(t *testing.T) {
+	base := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		delay time.Duration
+		now   time.Time
+		want  time.Time
+	}{
+		{time.Minute, base, base.Add(time.Minute)},
+		{time.Minute, base.Add(time.Second), base.Add(time.Minute)},
+		{time.Minute, base.Add(59*time.Second + 999*time.Millisecond), base.Add(time.Minute)},
+		{5 * time.Minute, base.Add(7 * time.Minute), base.Add(10 * time.Minute)},
+		{time.Hour, base.Add(30 * time.Minute), base.Add(time.Hour)},
+		{7 * time.Second, time.Unix(20, 0), time.Unix(21, 0)},
+		{7 * time.Second, time.Unix(21, 0), time.Unix(28, 0)},
+		{7 * time.Second, time.Unix(22, 500), time.Unix(28, 0)},
+	}
+
+	for _, tt := range tests {
+		if got := (interval{tt.delay}).Next(tt.now); !got.Equal(tt.want) {
+			t.Errorf("interval{%v}.Next(%v) = %v, want %v", tt.delay, tt.now, got, tt.want)
+		}
+	}
+}
+
+func TestAddAlignsIntervals(t *testing.T) {
+	s := NewScheduler(nil, nil)
+
+	jobs := []Job{
+		{Name: "every", Every: 90 * time.Second},
+		{Name: "descriptor", Schedule: "@every 90s"},
+	}
+
+	for _, j := range jobs {
+		j.Run = func(ctx context.Context) error { return nil }
+		if err := s.Add(j); err != nil {
+			t.Fatalf("Add(%v): %v", j.Name, err)
+		}
+
+		if _, ok := s.jobs[j.Name].schedule.(interval); !ok {
+			t.Errorf("Add(%v) scheduled %T, want an interval aligned on the epoch", j.Name, s.jobs[j.Name].schedule)
+		}
+	}
+}
+
+// cleanedHistory counts the cleanups of a memory history
+type cleanedHistory struct {
+	*memoryHistory
+	cleanups int
+}
+
+func (h *cleanedHistory) Cleanup(ctx context.Context) (int64, error) {
+	h.cleanups++
+	return 0, nil
+}
+
+func TestRunCleansUpHistory(t *testing.T) {
+	history := &cleanedHistory{memoryHistory: NewMemoryHistory(0)}
+	s := NewScheduler(nil, history)
+
+	j := &job{Job: Job{Name: "report", Run: func(ctx context.Context) error { return nil }}}
+	s.run(context.Background(), j)
+	s.run(context.Background(), j)
+
+	if history.cleanups != 1 {
+		t.Errorf("two runs cleaned up the history %v times, want once per interval", history.cleanups)
+	}
+
+	s.lastCleanup.Store(time.Now().Add(-CLEANUP_INTERVAL).UnixNano())
+	s.run(context.Background(), j)
+
+	if history.cleanups != 2 {
+		t.Errorf("a run after the interval cleaned up the history %v times in total, want 2", history.cleanups)
+	}
+	if runs, _ := s.History(context.Background(), "report", -1); len(runs) != 3 {
+		t.Errorf("History returned %v runs, want 3", len(runs))
+	}
+}
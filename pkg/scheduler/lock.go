@@ -0,0 +1,45 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/QubelyLabs/bedrock/pkg/lock"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	LOCK_PREFIX = "scheduler:"
+	MIN_TICK    = time.Second
+	RUNNING_TTL = 30 * time.Second
+)
+
+// claimTick takes the tick of the job scheduled at, the first replica to fire wins.
+// The claim is keyed by the scheduled instant, so the replicas share it however late they fire, and it is never released,
+// it lasts until the next tick, which absorbs the clock skew between replicas.
+func claimTick(ctx context.Context, client redis.UniversalClient, job string, at, next time.Time) (bool, error) {
+	ttl := next.Sub(at)
+	if ttl < MIN_TICK {
+		ttl = MIN_TICK
+	}
+
+	_, err := lock.NewLocker(lock.NewRedisStore(client, LOCK_PREFIX)).TryAcquire(ctx, fmt.Sprintf("%v:tick:%v", job, at.Unix()), ttl)
+	if errors.Is(err, lock.ErrNotAcquired) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+// lockRunning marks the job as running on this replica, it returns nil when it runs elsewhere.
+// The lease is short and kept alive while the job runs, so it expires soon after the replica dies while running the job.
+func lockRunning(ctx context.Context, client redis.UniversalClient, job string) (*lock.Lock, error) {
+	running, err := lock.NewLocker(lock.NewRedisStore(client, LOCK_PREFIX)).TryAcquire(ctx, job+":running", RUNNING_TTL)
+	if errors.Is(err, lock.ErrNotAcquired) {
+		return nil, nil
+	}
+
+	return running, err
+}
@@ -0,0 +1,184 @@
+package identity
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/QubelyLabs/bedrock/pkg/cache"
+	"github.com/QubelyLabs/bedrock/pkg/contract"
+	"github.com/QubelyLabs/bedrock/pkg/request"
+	"github.com/QubelyLabs/bedrock/pkg/util"
+)
+
+const (
+	CACHE_TTL        = 5 * time.Minute
+	CACHE_KEY_PREFIX = "identity:"
+)
+
+type client struct {
+	baseUrl   string
+	requester request.Requester
+	cacher    contract.Cacher
+	ttl       time.Duration
+}
+
+// Authenticate asks the identity service to authenticate an incoming request.
+// A rejected request is not an error, it returns an unauthenticated result.
+func (s *client) Authenticate(method string, url string, headers map[string]string) (*AuthResult, error) {
+	payload := util.Object{
+		"method":  method,
+		"url":     url,
+		"headers": headers,
+	}
+	response, err := s.requester.Post(fmt.Sprintf("%v/authenticate", s.baseUrl), payload, nil, headers, 0)
+	if response != nil && (response.StatusCode == http.StatusUnauthorized || response.StatusCode == http.StatusForbidden) {
+		return &AuthResult{}, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	status, ok := response.Data.Data["status"].(bool)
+	if !ok {
+		return nil, fmt.Errorf("%w: authenticate has no status", ErrMalformedResponse)
+	}
+
+	if !status {
+		return &AuthResult{}, nil
+	}
+
+	user, err := decode[User](response.Data.Data, "user")
+	if err != nil {
+		return nil, err
+	}
+
+	workspace, err := decode[Workspace](response.Data.Data, "workspace")
+	if err != nil {
+		return nil, err
+	}
+
+	return &AuthResult{true, user, workspace}, nil
+}
+
+// Authorize checks if a user has the permissions in a workspace.
+// Only granted permissions are cached, so a permission granted after a denial is seen on the next check.
+func (s *client) Authorize(userId, workspaceId, permissionType string, permissions []string) (bool, error) {
+	sorted := append([]string{}, permissions...)
+	sort.Strings(sorted)
+	key := fmt.Sprintf("%vauthorize:%v", CACHE_KEY_PREFIX, util.ConsistentHash(
+		fmt.Sprintf("%v|%v|%v|%v", userId, workspaceId, permissionType, strings.Join(sorted, ",")),
+	))
+
+	if s.cacher != nil {
+		if value, err := s.cacher.Get(key); err == nil && value == true {
+			return true, nil
+		}
+	}
+
+	allowed, err := s.authorize(userId, workspaceId, permissionType, permissions)
+	if err == nil && allowed && s.cacher != nil {
+		// a cache failure does not fail the check, the next one asks the service again
+		s.cacher.Set(key, true, s.ttl)
+	}
+
+	return allowed, err
+}
+
+func (s *client) authorize(userId, workspaceId, permissionType string, permissions []string) (bool, error) {
+	payload := util.Object{
+		"userId":      userId,
+		"workspaceId": workspaceId,
+		"permissions": permissions,
+		"type":        permissionType,
+	}
+	response, err := s.requester.Post(fmt.Sprintf("%v/authorize", s.baseUrl), payload, nil, nil, 0)
+	if response != nil && response.StatusCode == http.StatusForbidden {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	status, ok := response.Data.Data["status"].(bool)
+	if !ok {
+		return false, fmt.Errorf("%w: authorize has no status", ErrMalformedResponse)
+	}
+
+	return status, nil
+}
+
+// GetWorkspace resolves the workspace and owner of a source, results are cached
+func (s *client) GetWorkspace(sourceId string) (*Workspace, error) {
+	key := fmt.Sprintf("%vworkspace:%v", CACHE_KEY_PREFIX, sourceId)
+
+	return cached(s, key, func() (*Workspace, error) {
+		response, err := s.requester.Get(fmt.Sprintf("%v/workspace/%v/sourceId", s.baseUrl, sourceId), nil, nil, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		userId, ok := response.Data.Data["userId"].(string)
+		if !ok || userId == "" {
+			return nil, fmt.Errorf("%w: workspace has no userId", ErrMalformedResponse)
+		}
+
+		workspaceId, ok := response.Data.Data["workspaceId"].(string)
+		if !ok || workspaceId == "" {
+			return nil, fmt.Errorf("%w: workspace has no workspaceId", ErrMalformedResponse)
+		}
+
+		return &Workspace{ID: workspaceId, UserID: userId, SourceID: sourceId, Raw: response.Data.Data}, nil
+	})
+}
+
+// cached runs fn through the client cache, if any
+func cached[T any](s *client, key string, fn func() (T, error)) (T, error) {
+	if s.cacher == nil {
+		return fn()
+	}
+
+	return cache.CacheAs(s.cacher, key, fn, s.ttl)
+}
+
+// decode converts a nested object of the response into T
+func decode[T any](data util.Object, key string) (*T, error) {
+	object, ok := data[key].(util.Object)
+	if !ok {
+		return nil, fmt.Errorf("%w: %v is not an object", ErrMalformedResponse, key)
+	}
+
+	buf, err := json.Marshal(object)
+	if err != nil {
+		return nil, err
+	}
+
+	result := new(T)
+	if err := json.Unmarshal(buf, result); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedResponse, err)
+	}
+
+	switch v := any(result).(type) {
+	case *User:
+		v.Raw = object
+	case *Workspace:
+		v.Raw = object
+	}
+
+	return result, nil
+}
+
+// NewClient creates an identity client for the service at baseUrl.
+// cacher is optional, when nil Authorize and GetWorkspace are not cached.
+func NewClient(baseUrl string, requester request.Requester, cacher contract.Cacher, ttl time.Duration) *client {
+	if requester == nil {
+		requester = request.Default
+	}
+
+	return &client{strings.TrimRight(baseUrl, "/"), requester, cacher, ttl}
+}
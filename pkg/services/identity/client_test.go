@@ -0,0 +1,79 @@
+package identity
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/QubelyLabs/bedrock/pkg/request"
+	"github.com/QubelyLabs/bedrock/pkg/util"
+)
+
+// requester answers every request with the data and counts the calls
+type requester struct {
+	data  util.Object
+	calls int
+}
+
+func (r *requester) Get(url string, queries map[string]string, headers map[string]string, retryCount int) (*request.HttpResponse, error) {
+	r.calls++
+	return &request.HttpResponse{Status: true, StatusCode: 200, Data: request.DataResponse{Status: true, Data: r.data}}, nil
+}
+
+func (r *requester) Post(url string, body interface{}, queries map[string]string, headers map[string]string, retryCount int) (*request.HttpResponse, error) {
+	return r.Get(url, queries, headers, retryCount)
+}
+
+// jsonCache keeps the values as json like the redis cache
+type jsonCache map[string][]byte
+
+func (c jsonCache) Get(key string) (any, error) {
+	var value any
+	err := json.Unmarshal(c[key], &value)
+	return value, err
+}
+
+func (c jsonCache) Set(key string, value any, exp time.Duration) error {
+	buf, err := json.Marshal(value)
+	c[key] = buf
+	return err
+}
+
+func (c jsonCache) Cache(key string, fn func() (any, error), exp time.Duration) (any, error) {
+	if _, ok := c[key]; ok {
+		return c.Get(key)
+	}
+
+	value, err := fn()
+	if err != nil {
+		return nil, err
+	}
+
+	return value, c.Set(key, value, exp)
+}
+
+func TestGetWorkspaceCached(t *testing.T) {
+	r := &requester{data: util.Object{"userId": "u1", "workspaceId": "w1", "plan": "pro", "seats": float64(3)}}
+	c := NewClient("http://identity", r, jsonCache{}, time.Minute)
+
+	fresh, err := c.GetWorkspace("s1")
+	if err != nil {
+		t.Fatalf("GetWorkspace: %v", err)
+	}
+
+	cached, err := c.GetWorkspace("s1")
+	if err != nil {
+		t.Fatalf("cached GetWorkspace: %v", err)
+	}
+
+	if r.calls != 1 {
+		t.Errorf("the identity service was called %v times, want once", r.calls)
+	}
+	if !reflect.DeepEqual(fresh, cached) {
+		t.Errorf("cached GetWorkspace = %+v, want %+v", cached, fresh)
+	}
+	if cached.Raw["plan"] != "pro" {
+		t.Errorf("cached GetWorkspace lost the raw response: %v", cached.Raw)
+	}
+}
@@ -0,0 +1,103 @@
+package identity
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Fake is an in-process identity client for tests.
+// Requests are authenticated by their Authorization header against the registered tokens.
+type Fake struct {
+	mutex       sync.RWMutex
+	tokens      map[string]*AuthResult
+	permissions map[string]map[string]bool
+	workspaces  map[string]*Workspace
+}
+
+// AddToken makes requests carrying the token authenticate as the user and workspace
+func (f *Fake) AddToken(token string, user *User, workspace *Workspace) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.tokens[token] = &AuthResult{true, user, workspace}
+}
+
+// Grant gives a user permissions in a workspace
+func (f *Fake) Grant(userId, workspaceId, permissionType string, permissions ...string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	key := permissionKey(userId, workspaceId, permissionType)
+	if _, ok := f.permissions[key]; !ok {
+		f.permissions[key] = map[string]bool{}
+	}
+
+	for _, permission := range permissions {
+		f.permissions[key][permission] = true
+	}
+}
+
+// AddWorkspace registers the workspace a source belongs to
+func (f *Fake) AddWorkspace(sourceId string, workspace *Workspace) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.workspaces[sourceId] = workspace
+}
+
+func (f *Fake) Authenticate(method string, url string, headers map[string]string) (*AuthResult, error) {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+
+	for key, value := range headers {
+		if !strings.EqualFold(key, "Authorization") {
+			continue
+		}
+
+		token := strings.TrimSpace(strings.TrimPrefix(value, "Bearer "))
+		if result, ok := f.tokens[token]; ok {
+			return result, nil
+		}
+	}
+
+	return &AuthResult{}, nil
+}
+
+func (f *Fake) Authorize(userId, workspaceId, permissionType string, permissions []string) (bool, error) {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+
+	granted := f.permissions[permissionKey(userId, workspaceId, permissionType)]
+	for _, permission := range permissions {
+		if !granted[permission] {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+func (f *Fake) GetWorkspace(sourceId string) (*Workspace, error) {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+
+	workspace, ok := f.workspaces[sourceId]
+	if !ok {
+		return nil, fmt.Errorf("identity: workspace not found for source %v", sourceId)
+	}
+
+	return workspace, nil
+}
+
+func permissionKey(userId, workspaceId, permissionType string) string {
+	return fmt.Sprintf("%v|%v|%v", userId, workspaceId, permissionType)
+}
+
+func NewFake() *Fake {
+	return &Fake{
+		tokens:      map[string]*AuthResult{},
+		permissions: map[string]map[string]bool{},
+		workspaces:  map[string]*Workspace{},
+	}
+}
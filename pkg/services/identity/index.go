@@ -1,76 +1,60 @@
+// Package identity provides a client for the identity service,
+// which authenticates requests, checks permissions and resolves workspaces.
 package identity
 
 import (
-	"fmt"
-	"log"
-	"os"
+	"errors"
+	"time"
 
+	"github.com/QubelyLabs/bedrock/pkg/cache"
+	"github.com/QubelyLabs/bedrock/pkg/config"
 	"github.com/QubelyLabs/bedrock/pkg/request"
 	"github.com/QubelyLabs/bedrock/pkg/util"
 )
 
-func Authenticate(method string, url string, headers map[string]string) (bool, util.Object, util.Object, error) {
-	baseUrl := os.Getenv("IDENTITY_BASE_URL")
-	payload := util.Object{
-		"method":  method,
-		"url":     url,
-		"headers": headers,
-	}
-	response, err := request.Post(fmt.Sprintf("%v/authenticate", baseUrl), payload, nil, headers, 0)
-	if err != nil {
-		log.Println(err)
-		return false, nil, nil, err
-	}
-
-	if !response.Status {
-		return false, nil, nil, nil
-	}
-
-	status, _ := response.Data.Data["status"].(bool)
-	user, _ := response.Data.Data["user"].(util.Object)
-	workspace, _ := response.Data.Data["workspace"].(util.Object)
+var (
+	ErrMalformedResponse = errors.New("identity: malformed response")
+)
 
-	return status, user, workspace, nil
+type Client interface {
+	Authenticate(method string, url string, headers map[string]string) (*AuthResult, error)
+	Authorize(userId, workspaceId, permissionType string, permissions []string) (bool, error)
+	GetWorkspace(sourceId string) (*Workspace, error)
 }
 
-func Authorize(userId, workspaceId, permissionType string, permissions []string) (bool, error) {
-	baseUrl := os.Getenv("IDENTITY_BASE_URL")
-	payload := util.Object{
-		"userId":      userId,
-		"workspaceId": workspaceId,
-		"permissions": permissions,
-		"type":        permissionType,
-	}
-	response, err := request.Post(fmt.Sprintf("%v/authorize", baseUrl), payload, nil, nil, 0)
-	if err != nil {
-		log.Println(err)
-		return false, err
-	}
-
-	if !response.Status {
-		return false, nil
-	}
-
-	status := response.Data.Data["status"].(bool)
+type User struct {
+	ID    string      `json:"id"`
+	Name  string      `json:"name,omitempty"`
+	Email string      `json:"email,omitempty"`
+	Raw   util.Object `json:"raw,omitempty"` // Every field of the response
+}
 
-	return status, nil
+type Workspace struct {
+	ID       string      `json:"id"`
+	Name     string      `json:"name,omitempty"`
+	UserID   string      `json:"userId,omitempty"`
+	SourceID string      `json:"sourceId,omitempty"`
+	Raw      util.Object `json:"raw,omitempty"` // Every field of the response, kept when cached
 }
 
-func GetWorkspace(sourceId string) (string, string, error) {
-	baseUrl := os.Getenv("IDENTITY_BASE_URL")
-	url := fmt.Sprintf("%v/workspace/%v/sourceId", baseUrl, sourceId)
-	response, err := request.Get(url, nil, nil, 0)
-	if err != nil {
-		log.Println(err)
-		return "", "", err
-	}
+type AuthResult struct {
+	Authenticated bool       `json:"authenticated"`
+	User          *User      `json:"user,omitempty"`
+	Workspace     *Workspace `json:"workspace,omitempty"`
+}
 
-	if !response.Status {
-		return "", "", err
+// NewDefaultClient creates a client for the configured identity service,
+// using the default request client and caching responses in the default cache
+func NewDefaultClient(cf *config.Config) Client {
+	ttl := time.Duration(cf.IdentityCacheTTL) * time.Second
+	if ttl <= 0 {
+		ttl = CACHE_TTL
 	}
 
-	userId := response.Data.Data["userId"].(string)
-	workspaceId := response.Data.Data["workspaceId"].(string)
-
-	return userId, workspaceId, nil
+	return NewClient(cf.IdentityBaseUrl, request.Default, cache.NewDefaultCache(), ttl)
 }
+
+var (
+	_ Client = (*client)(nil)
+	_ Client = (*Fake)(nil)
+)
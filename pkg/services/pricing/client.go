@@ -0,0 +1,64 @@
+package pricing
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/QubelyLabs/bedrock/pkg/cache"
+	"github.com/QubelyLabs/bedrock/pkg/contract"
+	"github.com/QubelyLabs/bedrock/pkg/request"
+	"github.com/QubelyLabs/bedrock/pkg/util"
+)
+
+const (
+	CACHE_TTL        = 10 * time.Minute
+	CACHE_KEY_PREFIX = "pricing:"
+)
+
+type client struct {
+	baseUrl   string
+	requester request.Requester
+	cacher    contract.Cacher
+	ttl       time.Duration
+}
+
+// Entitlements retrieves the plan limits and features of a workspace, results are cached
+func (s *client) Entitlements(workspaceId string) (*Entitlements, error) {
+	fetch := func() (*Entitlements, error) {
+		response, err := s.requester.Get(fmt.Sprintf("%v/entitlements/%v", s.baseUrl, workspaceId), nil, nil, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		entitlements := new(Entitlements)
+		if err := util.Convert(response.Data.Data, entitlements); err != nil {
+			return nil, err
+		}
+		entitlements.WorkspaceID = workspaceId
+
+		return entitlements, nil
+	}
+
+	if s.cacher == nil {
+		return fetch()
+	}
+
+	return cache.CacheAs(s.cacher, fmt.Sprintf("%ventitlements:%v", CACHE_KEY_PREFIX, workspaceId), fetch, s.ttl)
+}
+
+// Report sends a batch of usage records to the pricing service
+func (s *client) Report(records []UsageRecord) error {
+	_, err := s.requester.Post(fmt.Sprintf("%v/usage", s.baseUrl), util.Object{"records": records}, nil, nil, 0)
+	return err
+}
+
+// NewClient creates a pricing client for the service at baseUrl.
+// cacher is optional, when nil entitlements are not cached.
+func NewClient(baseUrl string, requester request.Requester, cacher contract.Cacher, ttl time.Duration) *client {
+	if requester == nil {
+		requester = request.Default
+	}
+
+	return &client{strings.TrimRight(baseUrl, "/"), requester, cacher, ttl}
+}
@@ -1,51 +1,85 @@
+// Package pricing provides a client for the pricing service,
+// which resolves plan entitlements for workspaces and meters their usage.
 package pricing
 
 import (
-	"fmt"
-	"log"
+	"time"
 
+	"github.com/QubelyLabs/bedrock/pkg/cache"
+	"github.com/QubelyLabs/bedrock/pkg/config"
 	"github.com/QubelyLabs/bedrock/pkg/request"
-	"github.com/QubelyLabs/bedrock/pkg/util"
 )
 
-const (
-	baseUrl = "http://localhost:8089"
-)
+type Client interface {
+	Entitlements(workspaceId string) (*Entitlements, error)
+	Report(records []UsageRecord) error
+}
 
-// Enquiry makes an HTTP request to the pricing service to know the status of the users account
-// It also caches the request for 10mins
-func Enquiry(userId, workspaceId string) (bool, error) {
-	url := fmt.Sprintf("%v/enquiry", baseUrl)
-	payload := util.Object{
-		"userId":      userId,
-		"workspaceId": workspaceId,
-	}
+// Entitlements describes what the plan of a workspace allows and how much of it has been used
+type Entitlements struct {
+	WorkspaceID string           `json:"workspaceId"`
+	Plan        string           `json:"plan"`
+	Features    []string         `json:"features"`
+	Limits      map[string]int64 `json:"limits"`
+	Usage       map[string]int64 `json:"usage"`
+}
 
-	response, err := request.Post(url, payload, nil, nil, 0)
-	if err != nil {
-		log.Println(err)
-		return false, err
+// HasFeature reports whether the plan includes a feature
+func (e *Entitlements) HasFeature(feature string) bool {
+	for _, f := range e.Features {
+		if f == feature {
+			return true
+		}
 	}
 
-	if !response.Status {
-		return false, nil
+	return false
+}
+
+// Remaining returns how much of a metric can still be used, metrics without a limit are unlimited
+func (e *Entitlements) Remaining(metric string) (int64, bool) {
+	limit, ok := e.Limits[metric]
+	if !ok {
+		return 0, false
 	}
 
-	return true, nil
+	return limit - e.Usage[metric], true
+}
+
+// OverQuota reports whether the usage of a metric has reached its limit
+func (e *Entitlements) OverQuota(metric string) bool {
+	remaining, limited := e.Remaining(metric)
+	return limited && remaining <= 0
 }
 
-func Event(eventType string, payload any) {
+// UsageRecord is a single usage measurement, the ID lets the pricing service drop duplicate deliveries
+type UsageRecord struct {
+	ID          string    `json:"id"`
+	WorkspaceID string    `json:"workspaceId"`
+	UserID      string    `json:"userId,omitempty"`
+	Metric      string    `json:"metric"`
+	Quantity    int64     `json:"quantity"`
+	Timestamp   time.Time `json:"timestamp"`
+}
 
-	// if eventName == "" { // its an identify event
-	// 	go actor.ProcessIdentityEvent(sourceId, userId, workspaceId, payload)
-	// } else { // its a track event
-	// 	go pricing.Event(rudderstack.TrackEvent, payload)
-	// }
-	// switch type {
-	// case Iden:
+// NewDefaultClient creates a client for the configured pricing service,
+// using the default request client and caching entitlements in the default cache
+func NewDefaultClient(cf *config.Config) Client {
+	ttl := time.Duration(cf.PricingCacheTTL) * time.Second
+	if ttl <= 0 {
+		ttl = CACHE_TTL
+	}
 
-	// }
-	// err := rudderstack.Identity(analytics.Identify{
+	return NewClient(cf.PricingBaseUrl, request.Default, cache.NewDefaultCache(), ttl)
+}
 
-	// })
+// NewDefaultMeter creates a started meter reporting to the client with the configured batching
+func NewDefaultMeter(cf *config.Config, client Client) *meter {
+	m := NewMeter(client, cf.UsageBatchSize, time.Duration(cf.UsageFlushInterval)*time.Second)
+	m.Start()
+
+	return m
 }
+
+var (
+	_ Client = (*client)(nil)
+)
@@ -0,0 +1,186 @@
+package pricing
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/QubelyLabs/bedrock/pkg/logger"
+	"github.com/google/uuid"
+)
+
+const (
+	BATCH_SIZE     = 100
+	FLUSH_INTERVAL = 10 * time.Second
+	MAX_BUFFER     = 10000           // Records held while the pricing service is unreachable
+	MAX_BACK_OFF   = 5 * time.Minute // Longest wait between failed flushes
+)
+
+var (
+	ErrMeterFull   = errors.New("pricing: usage buffer full, record dropped")
+	ErrMeterClosed = errors.New("pricing: meter closed")
+)
+
+// meter batches usage records and reports them asynchronously.
+// Records are only removed from the buffer once the pricing service accepted them,
+// so every accepted record is delivered at least once.
+type meter struct {
+	client    Client
+	batchSize int
+	interval  time.Duration
+
+	flushing sync.Mutex // Serializes the flushes, so a batch is never reported twice at once
+	mutex    sync.Mutex
+	buffer   []UsageRecord
+	failures int
+	retryAt  time.Time
+	closed   bool
+
+	notify chan struct{}
+	done   chan struct{}
+	exited chan struct{}
+}
+
+// Record adds a usage record to the buffer, it is reported with the next batch
+func (m *meter) Record(record UsageRecord) error {
+	if record.ID == "" {
+		record.ID = uuid.New().String()
+	}
+
+	if record.Timestamp.IsZero() {
+		record.Timestamp = time.Now()
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.closed {
+		return ErrMeterClosed
+	}
+
+	if len(m.buffer) >= MAX_BUFFER {
+		return ErrMeterFull
+	}
+
+	m.buffer = append(m.buffer, record)
+	if len(m.buffer) >= m.batchSize {
+		select {
+		case m.notify <- struct{}{}:
+		default:
+		}
+	}
+
+	return nil
+}
+
+// Start runs the flush loop in the background
+func (m *meter) Start() {
+	go m.run()
+}
+
+func (m *meter) run() {
+	defer close(m.exited)
+
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.done:
+			return
+		case <-ticker.C:
+		case <-m.notify:
+		}
+
+		m.mutex.Lock()
+		wait := time.Now().Before(m.retryAt)
+		m.mutex.Unlock()
+
+		if !wait {
+			m.Flush()
+		}
+	}
+}
+
+// Flush reports all buffered records in batches, it stops at the first failed batch
+func (m *meter) Flush() error {
+	m.flushing.Lock()
+	defer m.flushing.Unlock()
+
+	for {
+		m.mutex.Lock()
+		size := min(len(m.buffer), m.batchSize)
+		batch := append([]UsageRecord{}, m.buffer[:size]...)
+		m.mutex.Unlock()
+
+		if len(batch) == 0 {
+			return nil
+		}
+
+		err := m.client.Report(batch)
+
+		m.mutex.Lock()
+		if err != nil {
+			m.failures++
+			m.retryAt = time.Now().Add(min(m.interval<<min(m.failures, 10), MAX_BACK_OFF))
+			m.mutex.Unlock()
+
+			logger.For("pricing").Warn("cannot report usage records", "records", len(batch), "attempt", m.failures, "error", err)
+			return err
+		}
+
+		// records appended while reporting are behind the batch, drop only what was sent
+		m.buffer = m.buffer[len(batch):]
+		m.failures = 0
+		m.retryAt = time.Time{}
+		m.mutex.Unlock()
+	}
+}
+
+// Close stops accepting records and keeps flushing until the buffer is empty or the context is done
+func (m *meter) Close(ctx context.Context) error {
+	m.mutex.Lock()
+	if m.closed {
+		m.mutex.Unlock()
+		return nil
+	}
+	m.closed = true
+	m.mutex.Unlock()
+
+	close(m.done)
+	<-m.exited
+
+	for {
+		err := m.Flush()
+		if err == nil {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// NewMeter creates a meter reporting to the client, Start must be called to flush in the background
+func NewMeter(client Client, batchSize int, interval time.Duration) *meter {
+	if batchSize <= 0 {
+		batchSize = BATCH_SIZE
+	}
+
+	if interval <= 0 {
+		interval = FLUSH_INTERVAL
+	}
+
+	return &meter{
+		client:    client,
+		batchSize: batchSize,
+		interval:  interval,
+		notify:    make(chan struct{}, 1),
+		done:      make(chan struct{}),
+		exited:    make(chan struct{}),
+	}
+}
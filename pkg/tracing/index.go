@@ -0,0 +1,95 @@
+// Package tracing provides OpenTelemetry tracing for http handlers, sql queries, redis commands,
+// outbound requests and events. Spans are exported over OTLP or to stdout, as configured.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/QubelyLabs/bedrock/pkg/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	InstrumentationName = "github.com/QubelyLabs/bedrock"
+
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+)
+
+// Init installs a tracer provider exporting to the configured exporter.
+// With no exporter configured tracing stays a no-op, the returned shutdown flushes pending spans.
+func Init(cf *config.Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch strings.ToLower(cf.TracingExporter) {
+	case ExporterOTLP:
+		options := []otlptracehttp.Option{}
+		if cf.TracingEndpoint != "" {
+			options = append(options, otlptracehttp.WithEndpoint(cf.TracingEndpoint))
+		}
+		if cf.TracingInsecure {
+			options = append(options, otlptracehttp.WithInsecure())
+		}
+		exporter, err = otlptracehttp.New(context.Background(), options...)
+	case ExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case "", "none":
+		return func(context.Context) error { return nil }, nil
+	default:
+		return nil, fmt.Errorf("tracing: unknown exporter %v", cf.TracingExporter)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	rate := cf.TracingSampleRate
+	if rate <= 0 {
+		rate = 1
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(rate))),
+		sdktrace.WithResource(resource.NewSchemaless(
+			semconv.ServiceName(cf.ServiceName),
+			semconv.DeploymentEnvironment(cf.GoEnv),
+		)),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+// Tracer returns the tracer used by bedrock instrumentation
+func Tracer() trace.Tracer {
+	return otel.Tracer(InstrumentationName)
+}
+
+// Inject writes the trace context of ctx into a carrier, so it can travel with a message
+func Inject(ctx context.Context, carrier map[string]string) {
+	otel.GetTextMapPropagator().Inject(ctx, propagation.MapCarrier(carrier))
+}
+
+// Extract reads a trace context written by Inject
+func Extract(ctx context.Context, carrier map[string]string) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(carrier))
+}
+
+// InjectHeader writes the trace context of ctx into outgoing http headers
+func InjectHeader(ctx context.Context, header http.Header) {
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(header))
+}
@@ -0,0 +1,244 @@
+// Package uow runs units of work in a database transaction outside of the http middleware,
+// e.g. in services, event listeners and jobs.
+// The transaction travels in the context, so nested units become savepoints and callbacks can wait for the commit.
+package uow
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math/rand"
+	"sync/atomic"
+	"time"
+
+	"github.com/QubelyLabs/bedrock/pkg/db"
+	"github.com/QubelyLabs/bedrock/pkg/logger"
+	"gorm.io/gorm"
+)
+
+const (
+	RETRIES       = 3
+	RETRY_BACKOFF = 20 * time.Millisecond
+)
+
+var (
+	Default = NewUnitOfWork(nil)
+)
+
+type unitContextKey struct{}
+
+// Options of the outermost transaction, nested units run in the savepoints of their parent and ignore them
+type Options struct {
+	ReadOnly  bool
+	Isolation sql.IsolationLevel
+
+	// Retries on deadlocks and serialization failures, zero uses RETRIES and a negative value disables retrying.
+	// The whole function runs again, so it must not have side effects outside the transaction, use AfterCommit for those.
+	Retries int
+}
+
+// unit is a transaction or savepoint in progress, it is not safe for concurrent use
+type unit struct {
+	tx         *gorm.DB
+	parent     *unit
+	savepoints int
+	callbacks  []func()
+
+	// set once committed or rolled back, a context outliving its unit, e.g. in a goroutine, no longer joins it
+	finished atomic.Bool
+}
+
+// active returns the unit of ctx unless it or one of its parents finished
+func active(ctx context.Context) (*unit, bool) {
+	current, ok := ctx.Value(unitContextKey{}).(*unit)
+	if !ok {
+		return nil, false
+	}
+
+	for u := current; u != nil; u = u.parent {
+		if u.finished.Load() {
+			return nil, false
+		}
+	}
+
+	return current, true
+}
+
+func (u *unit) root() *unit {
+	for u.parent != nil {
+		u = u.parent
+	}
+
+	return u
+}
+
+type unitOfWork struct {
+	db *gorm.DB
+}
+
+// WithTransaction runs fn in a transaction, committed when fn returns nil and rolled back when it fails or panics.
+// Called with the context of a running unit, e.g. tx.Statement.Context, fn runs in a savepoint of that transaction instead.
+func (u *unitOfWork) WithTransaction(ctx context.Context, fn func(tx *gorm.DB) error, opts ...*Options) error {
+	if current, ok := active(ctx); ok {
+		return current.nested(ctx, fn)
+	}
+
+	options := &Options{}
+	if len(opts) > 0 && opts[0] != nil {
+		options = opts[0]
+	}
+
+	retries := options.Retries
+	if retries == 0 {
+		retries = RETRIES
+	}
+
+	backoff := RETRY_BACKOFF
+	for attempt := 0; ; attempt++ {
+		err := u.run(ctx, fn, options)
+		if err == nil || attempt >= retries || !db.IsRetryable(err) {
+			return err
+		}
+
+		logger.For("uow").Warn("transaction conflict, retrying", "attempt", attempt+1, "error", err)
+
+		// jitter spreads the retries of the conflicting transactions
+		select {
+		case <-time.After(backoff + time.Duration(rand.Int63n(int64(backoff)))):
+		case <-ctx.Done():
+			return err
+		}
+		backoff *= 2
+	}
+}
+
+func (u *unitOfWork) run(ctx context.Context, fn func(tx *gorm.DB) error, options *Options) (err error) {
+	tx := u.sql().WithContext(ctx).Begin(&sql.TxOptions{ReadOnly: options.ReadOnly, Isolation: options.Isolation})
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	current := &unit{}
+	current.tx = tx.WithContext(context.WithValue(ctx, unitContextKey{}, current))
+
+	committed := false
+	defer func() {
+		current.finished.Store(true)
+		if committed {
+			return
+		}
+
+		if e := tx.Rollback().Error; e != nil {
+			logger.For("uow").Error("cannot rollback transaction", "error", e)
+		}
+	}()
+
+	if err = fn(current.tx); err != nil {
+		return err
+	}
+
+	if err = tx.Commit().Error; err != nil {
+		return err
+	}
+	committed = true
+	current.finished.Store(true)
+
+	for _, callback := range current.callbacks {
+		run(callback)
+	}
+
+	return nil
+}
+
+// nested runs fn in a savepoint, its after commit callbacks are kept only when it succeeds
+func (u *unit) nested(ctx context.Context, fn func(tx *gorm.DB) error) (err error) {
+	root := u.root()
+	root.savepoints++
+	name := fmt.Sprintf("bedrock_sp_%d", root.savepoints)
+
+	if err = u.tx.SavePoint(name).Error; err != nil {
+		return err
+	}
+
+	child := &unit{parent: u}
+	child.tx = u.tx.WithContext(context.WithValue(ctx, unitContextKey{}, child))
+
+	succeeded := false
+	defer func() {
+		child.finished.Store(true)
+		if succeeded {
+			return
+		}
+
+		if e := u.tx.RollbackTo(name).Error; e != nil {
+			logger.For("uow").Error("cannot rollback to savepoint", "savepoint", name, "error", e)
+		}
+	}()
+
+	if err = fn(child.tx); err != nil {
+		return err
+	}
+	succeeded = true
+
+	u.callbacks = append(u.callbacks, child.callbacks...)
+	return nil
+}
+
+func (u *unitOfWork) sql() *gorm.DB {
+	if u.db != nil {
+		return u.db
+	}
+
+	return db.SQL()
+}
+
+// NewUnitOfWork creates a unit of work on the database, nil uses db.SQL() at run time
+func NewUnitOfWork(d *gorm.DB) *unitOfWork {
+	return &unitOfWork{d}
+}
+
+// WithTransaction runs fn in a transaction of the default database
+func WithTransaction(ctx context.Context, fn func(tx *gorm.DB) error, opts ...*Options) error {
+	return Default.WithTransaction(ctx, fn, opts...)
+}
+
+// AfterCommit runs fn once the transaction of ctx commits, it is dropped on rollback.
+// Without a transaction in ctx, or once it finished, fn runs right away.
+func AfterCommit(ctx context.Context, fn func()) {
+	if current, ok := active(ctx); ok {
+		current.callbacks = append(current.callbacks, fn)
+		return
+	}
+
+	run(fn)
+}
+
+// From returns the transaction of ctx, false once it committed or rolled back
+func From(ctx context.Context) (*gorm.DB, bool) {
+	current, ok := active(ctx)
+	if !ok {
+		return nil, false
+	}
+
+	return current.tx, true
+}
+
+// DB returns the transaction of ctx, or the default database outside a unit of work
+func DB(ctx context.Context) *gorm.DB {
+	if tx, ok := From(ctx); ok {
+		return tx
+	}
+
+	return db.SQL().WithContext(ctx)
+}
+
+// run calls an after commit callback, the data is already durable so a panic is logged instead of propagated
+func run(fn func()) {
+	defer func() {
+		if err := recover(); err != nil {
+			logger.For("uow").Error("panic in after commit callback", "panic", fmt.Sprint(err))
+		}
+	}()
+
+	fn()
+}
@@ -0,0 +1,268 @@
+package uow
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/QubelyLabs/bedrock/pkg/config"
+	"github.com/QubelyLabs/bedrock/pkg/db"
+	"gorm.io/gorm"
+)
+
+// units of work run against sqlite, so they need no database server
+
+type record struct {
+	ID   uint   `gorm:"primaryKey"`
+	Name string `gorm:"size:64"`
+}
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "bedrock-uow")
+	if err != nil {
+		panic(err)
+	}
+
+	code := func() int {
+		defer os.RemoveAll(dir)
+
+		err := db.InitSQL(&config.Config{
+			DBDialect:  "sqlite",
+			DBName:     filepath.Join(dir, "test.db"),
+			DBLogLevel: "silent",
+		})
+		if err != nil {
+			panic(err)
+		}
+		defer db.CloseSQL()
+
+		if err := db.SQL().AutoMigrate(&record{}); err != nil {
+			panic(err)
+		}
+
+		return m.Run()
+	}()
+
+	os.Exit(code)
+}
+
+func reset(t *testing.T) {
+	t.Helper()
+
+	if err := db.SQL().Session(&gorm.Session{AllowGlobalUpdate: true}).Delete(&record{}).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func insert(tx *gorm.DB, name string) error {
+	return tx.Create(&record{Name: name}).Error
+}
+
+func names(t *testing.T) []string {
+	t.Helper()
+
+	var records []record
+	if err := db.SQL().Find(&records).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	found := []string{}
+	for _, r := range records {
+		found = append(found, r.Name)
+	}
+	sort.Strings(found)
+
+	return found
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func TestSavepointRollback(t *testing.T) {
+	reset(t)
+
+	err := WithTransaction(context.Background(), func(tx *gorm.DB) error {
+		if err := insert(tx, "outer"); err != nil {
+			return err
+		}
+
+		nestedErr := WithTransaction(tx.Statement.Context, func(tx *gorm.DB) error {
+			if err := insert(tx, "nested"); err != nil {
+				return err
+			}
+
+			return errors.New("nested failed")
+		})
+		if nestedErr == nil {
+			t.Error("the failing nested unit returned no error")
+		}
+
+		return insert(tx, "after")
+	})
+	if err != nil {
+		t.Fatalf("WithTransaction: %v", err)
+	}
+
+	if got, want := names(t), []string{"after", "outer"}; !equal(got, want) {
+		t.Errorf("records = %v, want %v", got, want)
+	}
+}
+
+func TestRollback(t *testing.T) {
+	reset(t)
+
+	called := false
+	err := WithTransaction(context.Background(), func(tx *gorm.DB) error {
+		AfterCommit(tx.Statement.Context, func() { called = true })
+		if err := insert(tx, "outer"); err != nil {
+			return err
+		}
+
+		return errors.New("failed")
+	})
+	if err == nil {
+		t.Fatal("WithTransaction returned no error")
+	}
+
+	if called {
+		t.Error("the callback ran on rollback")
+	}
+	if got := names(t); len(got) != 0 {
+		t.Errorf("records = %v, want none", got)
+	}
+}
+
+func TestNestedCallbacks(t *testing.T) {
+	reset(t)
+
+	calls := []string{}
+	err := WithTransaction(context.Background(), func(tx *gorm.DB) error {
+		AfterCommit(tx.Statement.Context, func() { calls = append(calls, "outer") })
+
+		err := WithTransaction(tx.Statement.Context, func(tx *gorm.DB) error {
+			AfterCommit(tx.Statement.Context, func() { calls = append(calls, "nested") })
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		_ = WithTransaction(tx.Statement.Context, func(tx *gorm.DB) error {
+			AfterCommit(tx.Statement.Context, func() { calls = append(calls, "rolled back") })
+			return errors.New("nested failed")
+		})
+
+		if len(calls) != 0 {
+			t.Errorf("callbacks ran before the commit: %v", calls)
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WithTransaction: %v", err)
+	}
+
+	if want := []string{"outer", "nested"}; !equal(calls, want) {
+		t.Errorf("callbacks = %v, want %v", calls, want)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	locked := errors.New("database is locked")
+
+	tests := []struct {
+		name     string
+		failures int
+		err      error
+		retries  int
+		calls    int
+		wantErr  bool
+	}{
+		{"retryable", 2, locked, 0, 3, false},
+		{"out of retries", 5, locked, 2, 3, true},
+		{"retries disabled", 1, locked, -1, 1, true},
+		{"not retryable", 1, errors.New("constraint failed"), 0, 1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reset(t)
+
+			calls := 0
+			err := WithTransaction(context.Background(), func(tx *gorm.DB) error {
+				calls++
+				if err := insert(tx, "attempt"); err != nil {
+					return err
+				}
+
+				if calls <= tt.failures {
+					return tt.err
+				}
+
+				return nil
+			}, &Options{Retries: tt.retries})
+
+			if (err != nil) != tt.wantErr {
+				t.Errorf("WithTransaction = %v, want an error %v", err, tt.wantErr)
+			}
+			if calls != tt.calls {
+				t.Errorf("fn ran %v times, want %v", calls, tt.calls)
+			}
+
+			want := 1
+			if tt.wantErr {
+				want = 0
+			}
+			if got := names(t); len(got) != want {
+				t.Errorf("records = %v, want %v, the failed attempts must roll back", got, want)
+			}
+		})
+	}
+}
+
+func TestFinishedUnit(t *testing.T) {
+	reset(t)
+
+	var stale context.Context
+	err := WithTransaction(context.Background(), func(tx *gorm.DB) error {
+		stale = tx.Statement.Context
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WithTransaction: %v", err)
+	}
+
+	if _, ok := From(stale); ok {
+		t.Error("From returned the committed transaction")
+	}
+
+	called := false
+	AfterCommit(stale, func() { called = true })
+	if !called {
+		t.Error("AfterCommit did not run right away once the unit finished")
+	}
+
+	err = WithTransaction(stale, func(tx *gorm.DB) error {
+		return insert(tx, "fresh")
+	})
+	if err != nil {
+		t.Fatalf("WithTransaction with a finished unit: %v", err)
+	}
+	if got, want := names(t), []string{"fresh"}; !equal(got, want) {
+		t.Errorf("records = %v, want %v", got, want)
+	}
+}
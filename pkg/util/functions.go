@@ -92,6 +92,20 @@ func ToTime(value any) (time.Time, bool) {
 	}
 }
 
+// Backoff returns the delay before the next attempt, base after the first one and doubling after each other up to max
+func Backoff(attempts int, base, max time.Duration) time.Duration {
+	delay := base
+	for i := 1; i < attempts && delay < max; i++ {
+		delay *= 2
+	}
+
+	if delay > max {
+		return max
+	}
+
+	return delay
+}
+
 func ConsistentHash(str string) string {
 	hasher := sha256.New()
 	hasher.Write([]byte(str))
@@ -144,6 +158,16 @@ func FromBase64(str string) Object {
 	return data
 }
 
+// Convert copies a value into another type through a json round trip
+func Convert(from any, to any) error {
+	buf, err := json.Marshal(from)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(buf, to)
+}
+
 func ToBase64String(value string) string {
 	return base64.StdEncoding.EncodeToString([]byte(value))
 }
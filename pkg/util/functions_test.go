@@ -0,0 +1,30 @@
+package util
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	base, max := 5*time.Second, time.Hour
+
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{0, base},
+		{1, base},
+		{2, 2 * base},
+		{3, 4 * base},
+		{5, 16 * base},
+		{10, 512 * base},
+		{11, max},
+		{100, max},
+	}
+
+	for _, tt := range tests {
+		if got := Backoff(tt.attempts, base, max); got != tt.want {
+			t.Errorf("Backoff(%v, %v, %v) = %v, want %v", tt.attempts, base, max, got, tt.want)
+		}
+	}
+}